@@ -0,0 +1,263 @@
+// Package apierror defines the errors returned by the API. Every error carries
+// a stable, machine-readable code; the Errors middleware renders them as RFC
+// 7807 problem details so clients never need to match on messages.
+package apierror
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"strings"
+
+	"github.com/go-playground/validator/v10"
+)
+
+// Code identifies a kind of error. Codes are part of the API contract.
+type Code string
+
+// Error codes.
+const (
+	CodeBadRequest       Code = "bad_request"
+	CodeUnauthorized     Code = "unauthorized"
+	CodeForbidden        Code = "forbidden"
+	CodeNotFound         Code = "not_found"
+	CodeValidationFailed Code = "validation_failed"
+	CodeConflict         Code = "conflict"
+	// CodeConstraintViolation reports input the database rejected, such as
+	// a reference to a missing row.
+	CodeConstraintViolation  Code = "constraint_violation"
+	CodeUnsupportedMediaType Code = "unsupported_media_type"
+	CodePreconditionFailed   Code = "precondition_failed"
+	// CodeIdempotencyKeyReused reports an idempotency key sent again with a
+	// different request.
+	CodeIdempotencyKeyReused Code = "idempotency_key_reused"
+	CodeInternal             Code = "internal"
+	// CodeUnavailable reports a dependency, such as the database, that cannot
+	// serve the request right now. Retrying later may succeed.
+	CodeUnavailable Code = "unavailable"
+	// CodeTimeout reports a request the database did not complete in time.
+	CodeTimeout Code = "timeout"
+)
+
+// statuses maps each code to the HTTP status it is reported with.
+var statuses = map[Code]int{
+	CodeBadRequest:           http.StatusBadRequest,
+	CodeUnauthorized:         http.StatusUnauthorized,
+	CodeForbidden:            http.StatusForbidden,
+	CodeNotFound:             http.StatusNotFound,
+	CodeValidationFailed:     http.StatusUnprocessableEntity,
+	CodeConflict:             http.StatusConflict,
+	CodeConstraintViolation:  http.StatusUnprocessableEntity,
+	CodeUnsupportedMediaType: http.StatusUnsupportedMediaType,
+	CodePreconditionFailed:   http.StatusPreconditionFailed,
+	CodeIdempotencyKeyReused: http.StatusUnprocessableEntity,
+	CodeInternal:             http.StatusInternalServerError,
+	CodeUnavailable:          http.StatusServiceUnavailable,
+	CodeTimeout:              http.StatusGatewayTimeout,
+}
+
+// Status returns the HTTP status for code. Unknown codes are internal errors.
+func Status(code Code) int {
+	if status, ok := statuses[code]; ok {
+		return status
+	}
+	return http.StatusInternalServerError
+}
+
+// FieldError describes why a single request field was rejected.
+type FieldError struct {
+	Field   string `json:"field"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// Error is an error with a stable code, a human-readable message and, for
+// validation failures, the fields at fault.
+type Error struct {
+	Code    Code
+	Message string
+	Fields  []FieldError
+	// Extensions are additional members of the problem details.
+	Extensions map[string]interface{}
+	// Cause is the underlying error. It is logged but never sent to clients.
+	Cause error
+}
+
+func (e *Error) Error() string {
+	if e.Cause != nil {
+		return fmt.Sprintf("%s: %s: %v", e.Code, e.Message, e.Cause)
+	}
+	return fmt.Sprintf("%s: %s", e.Code, e.Message)
+}
+
+func (e *Error) Unwrap() error {
+	return e.Cause
+}
+
+// New returns an error with code and message.
+func New(code Code, message string) *Error {
+	return &Error{Code: code, Message: message}
+}
+
+// BadRequest reports a malformed request, such as an invalid path parameter.
+func BadRequest(message string) *Error {
+	return New(CodeBadRequest, message)
+}
+
+// Unauthorized reports a missing or invalid identity.
+func Unauthorized(message string) *Error {
+	return New(CodeUnauthorized, message)
+}
+
+// Forbidden reports an identified caller lacking permission.
+func Forbidden(message string) *Error {
+	return New(CodeForbidden, message)
+}
+
+// NotFound reports a missing resource.
+func NotFound(message string) *Error {
+	return New(CodeNotFound, message)
+}
+
+// Conflict reports a request conflicting with the current state of a resource.
+func Conflict(message string) *Error {
+	return New(CodeConflict, message)
+}
+
+// Constraint reports input that violates a database constraint.
+func Constraint(message string) *Error {
+	return New(CodeConstraintViolation, message)
+}
+
+// UnsupportedMediaType reports a request body of a media type the endpoint does not accept.
+func UnsupportedMediaType(message string) *Error {
+	return New(CodeUnsupportedMediaType, message)
+}
+
+// PreconditionFailed reports a conditional request whose condition no longer holds,
+// such as an If-Match header naming an outdated version.
+func PreconditionFailed(message string) *Error {
+	return New(CodePreconditionFailed, message)
+}
+
+// IdempotencyKeyReused reports an idempotency key reused for a request other
+// than the one it was first sent with.
+func IdempotencyKeyReused(message string) *Error {
+	return New(CodeIdempotencyKeyReused, message)
+}
+
+// Internal reports a server-side failure. The message must not reveal internals.
+func Internal(message string) *Error {
+	return New(CodeInternal, message)
+}
+
+// Unavailable reports a dependency that cannot serve the request right now.
+func Unavailable(message string) *Error {
+	return New(CodeUnavailable, message)
+}
+
+// Timeout reports work that did not complete within its deadline.
+func Timeout(message string) *Error {
+	return New(CodeTimeout, message)
+}
+
+// Validation reports request fields that failed validation.
+func Validation(message string, fields ...FieldError) *Error {
+	return &Error{Code: CodeValidationFailed, Message: message, Fields: fields}
+}
+
+// WithCause attaches the underlying error.
+func (e *Error) WithCause(err error) *Error {
+	e.Cause = err
+	return e
+}
+
+// With adds an extension member to the problem details.
+func (e *Error) With(key string, value interface{}) *Error {
+	if e.Extensions == nil {
+		e.Extensions = map[string]interface{}{}
+	}
+	e.Extensions[key] = value
+	return e
+}
+
+// FromBinding converts an error from binding a request body into an API error,
+// reporting each failing field instead of the validator's raw message.
+func FromBinding(err error) *Error {
+	var validationErrs validator.ValidationErrors
+	if errors.As(err, &validationErrs) {
+		fields := make([]FieldError, 0, len(validationErrs))
+		for _, fe := range validationErrs {
+			fields = append(fields, FieldError{Field: fieldName(fe), Code: fe.Tag(), Message: validationMessage(fe)})
+		}
+		return Validation("Request validation failed", fields...).WithCause(err)
+	}
+
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &typeErr) {
+		field := FieldError{Field: typeErr.Field, Code: "type", Message: "must be a " + typeErr.Type.String()}
+		return Validation("Request validation failed", field).WithCause(err)
+	}
+
+	if errors.Is(err, io.EOF) {
+		return BadRequest("Request body is required").WithCause(err)
+	}
+	return BadRequest("Request body is not valid JSON").WithCause(err)
+}
+
+// fieldName returns the path of the failing field without the top-level struct.
+func fieldName(fe validator.FieldError) string {
+	if _, path, ok := strings.Cut(fe.Namespace(), "."); ok {
+		return path
+	}
+	return fe.Field()
+}
+
+// messages describes custom validation rules by their tag.
+var messages = map[string]string{}
+
+// RegisterMessage sets the message reported when the rule tag fails.
+func RegisterMessage(tag, message string) {
+	messages[tag] = message
+}
+
+// validationMessage describes a failed validation rule.
+func validationMessage(fe validator.FieldError) string {
+	if message, ok := messages[fe.Tag()]; ok {
+		return message
+	}
+
+	// Size rules count characters of strings and items of lists.
+	bound := fe.Param()
+	switch fe.Kind() {
+	case reflect.String:
+		bound += " characters"
+	case reflect.Slice, reflect.Array, reflect.Map:
+		bound += " items"
+	}
+
+	switch fe.Tag() {
+	case "required":
+		return "is required"
+	case "oneof":
+		return "must be one of " + fe.Param()
+	case "min", "gte":
+		return "must be at least " + bound
+	case "max", "lte":
+		return "must be at most " + bound
+	case "gt":
+		return "must be greater than " + fe.Param()
+	case "lt":
+		return "must be less than " + fe.Param()
+	case "len":
+		return "must be exactly " + bound
+	case "ne":
+		return "must not be " + fe.Param()
+	case "hexadecimal":
+		return "must be hexadecimal"
+	}
+	return "failed the " + fe.Tag() + " rule"
+}
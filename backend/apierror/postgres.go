@@ -0,0 +1,139 @@
+package apierror
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"net"
+	"regexp"
+	"strings"
+
+	"github.com/lib/pq"
+)
+
+// Postgres error codes reported as client errors.
+const (
+	pqNotNullViolation    = "23502"
+	pqForeignKeyViolation = "23503"
+	pqUniqueViolation     = "23505"
+	pqCheckViolation      = "23514"
+	pqInvalidText         = "22P02"
+	pqStringTooLong       = "22001"
+	pqNumericOutOfRange   = "22003"
+)
+
+// Postgres error codes reported as the database being unavailable or slow.
+const (
+	pqQueryCanceled       = "57014"
+	pqTooManyConnections  = "53300"
+	pqAdminShutdown       = "57P01"
+	pqCrashShutdown       = "57P02"
+	pqCannotConnectNow    = "57P03"
+	pqConnectionException = "08"
+)
+
+// keyColumn extracts the column from details like "Key (recipe_id)=(3) ...".
+var keyColumn = regexp.MustCompile(`^Key \(([^)]+)\)=`)
+
+// FromDB converts a database error into an API error. Constraint violations
+// caused by the request are reported with the offending constraint and field;
+// anything else is an internal error with message.
+func FromDB(err error, message string) *Error {
+	if apiErr := Transient(err); apiErr != nil {
+		return apiErr
+	}
+
+	var pqErr *pq.Error
+	if !errors.As(err, &pqErr) {
+		return Internal(message).WithCause(err)
+	}
+
+	field := string(pqErr.Column)
+	if m := keyColumn.FindStringSubmatch(pqErr.Detail); m != nil {
+		field = m[1]
+	}
+
+	var apiErr *Error
+	switch pqErr.Code {
+	case pqForeignKeyViolation:
+		// Deleting a row that is still referenced conflicts with the
+		// referencing rows; referencing a missing row is invalid input.
+		if strings.Contains(pqErr.Detail, "is still referenced") {
+			apiErr = Conflict("The " + singular(pqErr.Table) + " is still used by " + referencingTable(pqErr.Detail))
+		} else {
+			apiErr = Constraint("The referenced " + strings.TrimSuffix(field, "_id") + " does not exist")
+		}
+	case pqUniqueViolation:
+		apiErr = Conflict("A " + singular(pqErr.Table) + " with this " + field + " already exists")
+	case pqNotNullViolation:
+		apiErr = Constraint("A value for " + field + " is required")
+	case pqCheckViolation:
+		apiErr = Constraint("The value for " + field + " is not allowed")
+	case pqInvalidText, pqStringTooLong, pqNumericOutOfRange:
+		apiErr = Constraint("A value has the wrong format or size")
+	default:
+		return Internal(message).WithCause(err)
+	}
+
+	if field != "" {
+		apiErr.Fields = []FieldError{{Field: field, Code: pqErr.Code.Name(), Message: apiErr.Message}}
+	}
+	if pqErr.Constraint != "" {
+		apiErr.With("constraint", pqErr.Constraint)
+	}
+	return apiErr.WithCause(err)
+}
+
+// Transient converts errors caused by the database being slow or out of
+// reach, rather than by the request, into timeouts and unavailability, and
+// returns nil for other errors. Statements stopped by their deadline are
+// timeouts; those stopped because the client went away are reported as
+// unavailable, although no one is left to read the response.
+func Transient(err error) *Error {
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, context.DeadlineExceeded):
+		return Timeout("The database did not answer in time").WithCause(err)
+	case errors.Is(err, context.Canceled):
+		return Unavailable("The request was canceled").WithCause(err)
+	case errors.Is(err, driver.ErrBadConn), errors.Is(err, sql.ErrConnDone):
+		return Unavailable("The database is unavailable").WithCause(err)
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return Unavailable("The database is unavailable").WithCause(err)
+	}
+
+	var pqErr *pq.Error
+	if !errors.As(err, &pqErr) {
+		return nil
+	}
+	switch {
+	case pqErr.Code == pqQueryCanceled:
+		// Postgres' statement_timeout, or a cancellation the driver requested.
+		return Timeout("The database did not answer in time").WithCause(err)
+	case pqErr.Code == pqTooManyConnections, pqErr.Code == pqAdminShutdown,
+		pqErr.Code == pqCrashShutdown, pqErr.Code == pqCannotConnectNow,
+		pqErr.Code.Class() == pqConnectionException:
+		return Unavailable("The database is unavailable").WithCause(err)
+	}
+	return nil
+}
+
+// singular names one row of table, e.g. "recipe" for recipes.
+func singular(table string) string {
+	return strings.ReplaceAll(strings.TrimSuffix(table, "s"), "_", " ")
+}
+
+// referencingTable extracts the table from details like
+// `Key (recipe_id)=(3) is still referenced from table "recipe_steps".`
+func referencingTable(detail string) string {
+	_, table, ok := strings.Cut(detail, `from table "`)
+	if !ok {
+		return "other records"
+	}
+	return strings.ReplaceAll(strings.TrimSuffix(table, `".`), "_", " ")
+}
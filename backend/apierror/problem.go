@@ -0,0 +1,60 @@
+package apierror
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// ContentType is the media type of problem details.
+const ContentType = "application/problem+json"
+
+// Problem is an RFC 7807 problem details document. Problems use the
+// "about:blank" type, so Title is the HTTP status text and Code tells
+// problems apart.
+type Problem struct {
+	Type      string       `json:"type"`
+	Title     string       `json:"title"`
+	Status    int          `json:"status"`
+	Detail    string       `json:"detail,omitempty"`
+	Instance  string       `json:"instance,omitempty"`
+	Code      Code         `json:"code"`
+	RequestID string       `json:"request_id,omitempty"`
+	Errors    []FieldError `json:"errors,omitempty"`
+	// Extensions are additional members rendered alongside the standard ones.
+	Extensions map[string]interface{} `json:"-"`
+}
+
+// NewProblem describes err as problem details for the request to instance.
+func NewProblem(err *Error, instance, requestID string) Problem {
+	status := Status(err.Code)
+	return Problem{
+		Type:       "about:blank",
+		Title:      http.StatusText(status),
+		Status:     status,
+		Detail:     err.Message,
+		Instance:   instance,
+		Code:       err.Code,
+		RequestID:  requestID,
+		Errors:     err.Fields,
+		Extensions: err.Extensions,
+	}
+}
+
+// MarshalJSON renders the extension members at the top level, as RFC 7807
+// requires. Extensions never override standard members.
+func (p Problem) MarshalJSON() ([]byte, error) {
+	type problem Problem
+	data, err := json.Marshal(problem(p))
+	if err != nil || len(p.Extensions) == 0 {
+		return data, err
+	}
+
+	members := map[string]interface{}{}
+	for key, value := range p.Extensions {
+		members[key] = value
+	}
+	if err := json.Unmarshal(data, &members); err != nil {
+		return nil, err
+	}
+	return json.Marshal(members)
+}
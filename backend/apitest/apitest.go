@@ -0,0 +1,121 @@
+// Package apitest serves the API against a test database, for the tests and
+// benchmarks of other packages. The database is named by TEST_DATABASE_URL;
+// without it, tests using the database are skipped.
+package apitest
+
+import (
+	"backend/db"
+	"backend/middleware"
+	"backend/policy"
+	"backend/routes"
+	"backend/validation"
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"net/http/httptest"
+	"net/netip"
+	"os"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	_ "github.com/lib/pq" // Import the PostgreSQL driver
+)
+
+// DB connects to the test database and applies the schema, skipping tb when
+// no database is configured.
+func DB(tb testing.TB) *sql.DB {
+	tb.Helper()
+	url := os.Getenv("TEST_DATABASE_URL")
+	if url == "" {
+		tb.Skip("TEST_DATABASE_URL not set")
+	}
+	database, err := sql.Open("postgres", url)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	tb.Cleanup(func() { database.Close() })
+	if err := db.ApplySchema(database); err != nil {
+		tb.Fatal(err)
+	}
+	return database
+}
+
+// Router serves the API on database behind the middleware main uses to
+// identify callers. Requests sent by Caller come from a trusted proxy, so
+// that they can name their user with X-User-ID.
+func Router(tb testing.TB, database *sql.DB) *gin.Engine {
+	tb.Helper()
+	gin.SetMode(gin.TestMode)
+	if err := validation.Register(); err != nil {
+		tb.Fatal(err)
+	}
+	middleware.TrustedProxies = []netip.Prefix{netip.MustParsePrefix("192.0.2.0/24")}
+	tb.Cleanup(func() { middleware.TrustedProxies = nil })
+
+	router := gin.New()
+	router.Use(middleware.Errors(), middleware.Identity(database), middleware.Household(database), middleware.Policy(policy.Default()))
+	routes.SetupIngredientsRoutes(router, database)
+	routes.SetupRecipeRoutes(router, database)
+	routes.SetupRecipeIngredientsRoutes(router, database)
+	routes.SetupRecipeStepsRoutes(router, database)
+	routes.SetupHouseholdRoutes(router, database)
+	routes.SetupTrashRoutes(router, database)
+	return router
+}
+
+// lastUserID makes the user IDs of a run unique; starting from the clock
+// keeps them apart from earlier runs on the same database.
+var lastUserID atomic.Int64
+
+func init() {
+	lastUserID.Store(time.Now().UnixNano()%1_000_000_000 + 1_000_000_000)
+}
+
+// Caller sends requests as a user, in a household unless HouseholdID is zero.
+type Caller struct {
+	UserID      int
+	HouseholdID int
+}
+
+// NewCaller returns a caller acting as a user no other caller uses.
+func NewCaller() Caller {
+	return Caller{UserID: int(lastUserID.Add(1))}
+}
+
+// Do sends a request with body encoded as JSON, unless it is nil.
+func (caller Caller) Do(tb testing.TB, router *gin.Engine, method, path string, body interface{}) *httptest.ResponseRecorder {
+	tb.Helper()
+	var reader bytes.Buffer
+	if body != nil {
+		if err := json.NewEncoder(&reader).Encode(body); err != nil {
+			tb.Fatal(err)
+		}
+	}
+	req := httptest.NewRequest(method, path, &reader)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(middleware.UserIDHeader, strconv.Itoa(caller.UserID))
+	if caller.HouseholdID != 0 {
+		req.Header.Set(middleware.HouseholdIDHeader, strconv.Itoa(caller.HouseholdID))
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+// Must sends a request that must answer with status want, decoding the
+// response into out unless it is nil.
+func (caller Caller) Must(tb testing.TB, router *gin.Engine, method, path string, body interface{}, want int, out interface{}) {
+	tb.Helper()
+	w := caller.Do(tb, router, method, path, body)
+	if w.Code != want {
+		tb.Fatalf("%s %s as user %d: status %d, want %d: %s", method, path, caller.UserID, w.Code, want, w.Body.String())
+	}
+	if out != nil {
+		if err := json.Unmarshal(w.Body.Bytes(), out); err != nil {
+			tb.Fatalf("%s %s: decoding %q: %v", method, path, w.Body.String(), err)
+		}
+	}
+}
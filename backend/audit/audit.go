@@ -0,0 +1,86 @@
+// Package audit writes the append-only log of changes to recipes, ingredients,
+// recipe ingredients and recipe steps. Entries are written in the transaction
+// making the change, so a change and its audit entry commit or fail together.
+package audit
+
+import (
+	"backend/models"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+)
+
+// Audited actions.
+const (
+	ActionCreate = "create"
+	ActionUpdate = "update"
+	ActionDelete = "delete"
+	// ActionRestore takes an entity out of the trash.
+	ActionRestore = "restore"
+	// ActionPurge removes an entity from the trash for good.
+	ActionPurge = "purge"
+)
+
+// SystemActorID is the actor of changes the service makes on its own, such as
+// purging the trash. Users have positive IDs.
+const SystemActorID = 0
+
+// Audited entity types.
+const (
+	EntityRecipe           = "recipe"
+	EntityIngredient       = "ingredient"
+	EntityRecipeIngredient = "recipe_ingredient"
+	EntityRecipeStep       = "recipe_step"
+)
+
+// entityTables maps entity types to their table and primary key column.
+var entityTables = map[string][2]string{
+	EntityRecipe:           {"recipes", "recipe_id"},
+	EntityIngredient:       {"ingredients", "ingredient_id"},
+	EntityRecipeIngredient: {"recipe_ingredients", "recipe_ingredient_id"},
+	EntityRecipeStep:       {"recipe_steps", "recipe_step_id"},
+}
+
+// Snapshot returns the current row of an entity as JSON, locking it for the
+// rest of the transaction. It returns nil if the entity does not exist.
+func Snapshot(ctx context.Context, tx *sql.Tx, entityType string, entityID int) (json.RawMessage, error) {
+	table, ok := entityTables[entityType]
+	if !ok {
+		return nil, fmt.Errorf("unknown entity type %q", entityType)
+	}
+
+	sqlQuery := fmt.Sprintf(`SELECT row_to_json(t) FROM %s t WHERE t.%s = $1 FOR UPDATE`, table[0], table[1])
+
+	var snapshot []byte
+	err := tx.QueryRowContext(ctx, sqlQuery, entityID).Scan(&snapshot)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error snapshotting %s %d: %v", entityType, entityID, err)
+	}
+	return snapshot, nil
+}
+
+// Record appends an entry to the audit log.
+func Record(ctx context.Context, tx *sql.Tx, entry models.AuditEntry) error {
+	sqlQuery := `
+		INSERT INTO audit_log (actor_id, action, entity_type, entity_id, before, after, request_id)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)`
+
+	_, err := tx.ExecContext(ctx, sqlQuery, entry.ActorID, entry.Action, entry.EntityType, entry.EntityID,
+		nullJSON(entry.Before), nullJSON(entry.After), entry.RequestID)
+	if err != nil {
+		return fmt.Errorf("error writing audit log: %v", err)
+	}
+	return nil
+}
+
+// nullJSON stores missing snapshots as SQL NULL rather than the JSON literal null.
+func nullJSON(data json.RawMessage) interface{} {
+	if len(data) == 0 {
+		return nil
+	}
+	return string(data)
+}
@@ -0,0 +1,22 @@
+// Package cache stores rendered responses of read-heavy endpoints so that
+// repeated reads need not reach the database. Backends implement Cache: the
+// in-process LRU is the default, and a shared store such as Redis can take its
+// place by implementing the same interface.
+package cache
+
+import (
+	"context"
+	"time"
+)
+
+// Cache stores values under string keys for a limited time. Implementations
+// are safe for concurrent use. They treat their own failures as misses, so
+// that an unavailable cache only costs the reads it would have saved.
+type Cache interface {
+	// Get returns the value stored under key, if any and not expired.
+	Get(ctx context.Context, key string) ([]byte, bool)
+	// Set stores value under key for ttl.
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration)
+	// DeletePrefix removes every value whose key starts with prefix.
+	DeletePrefix(ctx context.Context, prefix string)
+}
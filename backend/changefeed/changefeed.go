@@ -0,0 +1,164 @@
+// Package changefeed listens for the change notifications the database sends
+// on db.ChangesChannel and fans them out to subscribers in the process, so
+// that every instance learns about writes made by the others.
+package changefeed
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// OperationReset announces that changes may have been missed, e.g. while the
+// listener was disconnected: subscribers should drop everything derived from
+// the database.
+const OperationReset = "reset"
+
+// Reconnection backoff of the listener.
+const (
+	minReconnectInterval = time.Second
+	maxReconnectInterval = time.Minute
+)
+
+// pingInterval is how long the listener waits for a notification before
+// checking that its connection is still alive.
+const pingInterval = 90 * time.Second
+
+// Change describes a committed change to an entity.
+type Change struct {
+	Entity    string `json:"entity"` // "recipe", "ingredient", "recipe_ingredient" or "recipe_step"; empty on reset
+	ID        int    `json:"id"`
+	Operation string `json:"op"` // "insert", "update", "delete" or OperationReset
+}
+
+// Feed delivers changes to its subscribers.
+type Feed struct {
+	mu          sync.Mutex
+	subscribers map[*subscriber]bool
+}
+
+// subscriber receives changes on a buffered channel. missed records that a
+// change was dropped because the channel was full, so that a reset is sent
+// once there is room again.
+type subscriber struct {
+	changes chan Change
+	missed  bool
+}
+
+// New returns a feed without subscribers.
+func New() *Feed {
+	return &Feed{subscribers: map[*subscriber]bool{}}
+}
+
+// Subscribe returns a channel receiving every change published from now on,
+// and a function ending the subscription. Changes that find the channel full
+// are dropped and replaced by a reset, so that slow subscribers never hold up
+// the others.
+func (f *Feed) Subscribe(buffer int) (<-chan Change, func()) {
+	s := &subscriber{changes: make(chan Change, buffer)}
+	f.mu.Lock()
+	f.subscribers[s] = true
+	f.mu.Unlock()
+
+	var once sync.Once
+	return s.changes, func() {
+		once.Do(func() {
+			f.mu.Lock()
+			delete(f.subscribers, s)
+			f.mu.Unlock()
+			close(s.changes)
+		})
+	}
+}
+
+// Publish delivers change to every subscriber without blocking.
+func (f *Feed) Publish(change Change) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for s := range f.subscribers {
+		if s.missed {
+			if !trySend(s.changes, Change{Operation: OperationReset}) {
+				continue
+			}
+			s.missed = false
+			if change.Operation == OperationReset {
+				continue
+			}
+		}
+		if !trySend(s.changes, change) {
+			s.missed = true
+		}
+	}
+}
+
+func trySend(changes chan Change, change Change) bool {
+	select {
+	case changes <- change:
+		return true
+	default:
+		return false
+	}
+}
+
+// Run listens on channel of the database at connStr and publishes the changes
+// announced there until ctx is done. The listener reconnects by itself,
+// publishing a reset once reconnected since changes may have been missed
+// meanwhile.
+func (f *Feed) Run(ctx context.Context, connStr, channel string) {
+	listener := pq.NewListener(connStr, minReconnectInterval, maxReconnectInterval, func(event pq.ListenerEventType, err error) {
+		switch event {
+		case pq.ListenerEventConnected:
+			slog.Info("change feed connected", "channel", channel)
+		case pq.ListenerEventDisconnected:
+			slog.Warn("change feed disconnected", "channel", channel, "error", err)
+		case pq.ListenerEventReconnected:
+			slog.Info("change feed reconnected", "channel", channel)
+		case pq.ListenerEventConnectionAttemptFailed:
+			slog.Warn("change feed connection attempt failed", "channel", channel, "error", err)
+		}
+	})
+	// Closing the listener also ends a Listen still waiting for a connection.
+	go func() {
+		<-ctx.Done()
+		listener.Close()
+	}()
+
+	if err := listener.Listen(channel); err != nil {
+		if ctx.Err() == nil {
+			slog.Error("change feed could not listen", "channel", channel, "error", err)
+		}
+		return
+	}
+
+	ticker := time.NewTicker(pingInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case notification, ok := <-listener.Notify:
+			if !ok {
+				return
+			}
+			ticker.Reset(pingInterval)
+			if notification == nil {
+				// The connection was lost and re-established.
+				f.Publish(Change{Operation: OperationReset})
+				continue
+			}
+			var change Change
+			if err := json.Unmarshal([]byte(notification.Extra), &change); err != nil {
+				slog.Error("change feed received an invalid notification", "payload", notification.Extra, "error", err)
+				continue
+			}
+			f.Publish(change)
+		case <-ticker.C:
+			go listener.Ping()
+		}
+	}
+}
@@ -0,0 +1,210 @@
+package controllers
+
+import (
+	"backend/apierror"
+	"backend/middleware"
+	"backend/models"
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/gin-gonic/gin"
+)
+
+// caller describes who is making a request and which tenant it is scoped to.
+type caller struct {
+	userID      int    // 0 if anonymous
+	householdID *int   // nil for personal data
+	role        string // role in the household, if any
+}
+
+// callerOf returns the caller of the current request.
+func callerOf(c *gin.Context) caller {
+	var who caller
+	who.userID, _ = middleware.CurrentUserID(c)
+	if householdID, role, ok := middleware.CurrentHousehold(c); ok {
+		who.householdID = &householdID
+		who.role = role
+	}
+	return who
+}
+
+// canWrite reports whether the caller's household role allows modifying data.
+func (who caller) canWrite() bool {
+	return who.householdID == nil || who.role != models.HouseholdRoleViewer
+}
+
+// requireWriter checks that the caller may modify data in its tenant.
+// It writes the error response and returns false when the handler should stop.
+func requireWriter(c *gin.Context, who caller) bool {
+	if !who.canWrite() {
+		c.Error(apierror.Forbidden("Household viewers cannot modify household data"))
+		return false
+	}
+	return true
+}
+
+// inTenant restricts a query on a table aliased as alias to the rows of the
+// tenant bound to the given placeholder.
+func inTenant(alias, placeholder string) string {
+	return fmt.Sprintf("%s.household_id IS NOT DISTINCT FROM %s::int", alias, placeholder)
+}
+
+// recipeViewableCondition restricts a query on recipes aliased as r to the rows
+// the caller may read. The placeholders are the caller's user ID (0 if
+// anonymous) and household ID (NULL for personal data).
+// Recipes without an owner predate ownership and stay visible to everyone.
+// Recipes in the trash are never visible.
+const recipeViewableCondition = `r.deleted_at IS NULL AND r.household_id IS NOT DISTINCT FROM %[2]s::int
+		AND (r.visibility = 'public' OR r.owner_id IS NULL OR r.owner_id = %[1]s
+		OR (%[2]s::int IS NOT NULL AND r.visibility = 'shared')
+		OR (r.visibility = 'shared' AND EXISTS (
+			SELECT 1 FROM recipe_collaborators rc WHERE rc.recipe_id = r.recipe_id AND rc.user_id = %[1]s)))`
+
+// recipeViewable returns recipeViewableCondition bound to the given placeholders, e.g. "$1", "$2".
+func recipeViewable(userPlaceholder, householdPlaceholder string) string {
+	return "(" + fmt.Sprintf(recipeViewableCondition, userPlaceholder, householdPlaceholder) + ")"
+}
+
+// recipeAccess describes what a caller may do with a recipe.
+type recipeAccess struct {
+	found      bool
+	isOwner    bool
+	canView    bool
+	canEdit    bool
+	visibility string
+}
+
+// recipeFacts is what decides a caller's access to a recipe.
+type recipeFacts struct {
+	ownerID        sql.NullInt64
+	visibility     string
+	sameTenant     bool // the recipe belongs to the caller's tenant
+	isCollaborator bool // the caller collaborates on the recipe
+}
+
+// loadRecipeAccess computes the access the given caller has to a recipe.
+// Recipes outside the caller's tenant are reported as not found.
+func loadRecipeAccess(ctx context.Context, db *sql.DB, recipeID int, who caller) (recipeAccess, error) {
+	sqlQuery := `
+		SELECT owner_id, visibility, ` + inTenant("recipes", "$3") + `,
+			EXISTS (SELECT 1 FROM recipe_collaborators WHERE recipe_id = $1 AND user_id = $2)
+		FROM recipes
+		WHERE recipe_id = $1 AND deleted_at IS NULL`
+
+	var facts recipeFacts
+	err := db.QueryRowContext(ctx, sqlQuery, recipeID, who.userID, who.householdID).Scan(&facts.ownerID, &facts.visibility, &facts.sameTenant, &facts.isCollaborator)
+	if err == sql.ErrNoRows {
+		return recipeAccess{}, nil
+	}
+	if err != nil {
+		return recipeAccess{}, err
+	}
+	return facts.accessFor(who), nil
+}
+
+// accessFor computes the access who has to the recipe. Recipes outside the
+// caller's tenant are reported as not found.
+func (r recipeFacts) accessFor(who caller) recipeAccess {
+	if !r.sameTenant {
+		return recipeAccess{}
+	}
+	access := recipeAccess{found: true, visibility: r.visibility}
+
+	// Unowned recipes predate ownership: anyone may read them, but since no
+	// one can be shown to own them, no one may change them.
+	if !r.ownerID.Valid {
+		access.canView = true
+		return access
+	}
+
+	// Household members share every recipe that is not private to its owner.
+	access.isOwner = who.userID != 0 && int(r.ownerID.Int64) == who.userID
+	collaborates := r.isCollaborator && r.visibility != models.VisibilityPrivate
+	member := who.householdID != nil && r.visibility != models.VisibilityPrivate
+	access.canView = r.visibility == models.VisibilityPublic || access.isOwner || collaborates || member
+	access.canEdit = (access.isOwner || collaborates || member) && who.canWrite()
+	return access
+}
+
+// authorizeRecipe checks that the caller may read (or, with edit set, modify) a recipe.
+// It writes the error response and returns false when the handler should stop.
+// Recipes the caller may not see are reported as not found.
+func authorizeRecipe(c *gin.Context, db *sql.DB, recipeID int, edit bool) (recipeAccess, bool) {
+	who := callerOf(c)
+	access, err := loadRecipeAccess(c.Request.Context(), db, recipeID, who)
+	if err != nil {
+		c.Error(apierror.Internal("Error checking recipe access").WithCause(err))
+		return access, false
+	}
+
+	if !access.found || !access.canView {
+		c.Error(apierror.NotFound("Recipe not found"))
+		return access, false
+	}
+
+	if edit && !access.canEdit {
+		if who.userID == 0 {
+			c.Error(apierror.Unauthorized("Authentication required"))
+		} else {
+			c.Error(apierror.Forbidden("You do not have permission to modify this recipe"))
+		}
+		return access, false
+	}
+
+	return access, true
+}
+
+// authorizeRecipeOwner checks that the caller owns a recipe.
+// It writes the error response and returns false when the handler should stop.
+func authorizeRecipeOwner(c *gin.Context, db *sql.DB, recipeID int) (recipeAccess, bool) {
+	access, ok := authorizeRecipe(c, db, recipeID, true)
+	if !ok {
+		return access, false
+	}
+
+	if !access.isOwner {
+		c.Error(apierror.Forbidden("Only the recipe owner can perform this action"))
+		return access, false
+	}
+
+	return access, true
+}
+
+// parentRecipeID returns the recipe a recipe ingredient or recipe step belongs
+// to. Rows in the trash are reported as sql.ErrNoRows.
+func parentRecipeID(ctx context.Context, db *sql.DB, table, idColumn string, id int) (int, error) {
+	sqlQuery := fmt.Sprintf(`SELECT recipe_id FROM %s WHERE %s = $1 AND deleted_at IS NULL`, table, idColumn)
+
+	var recipeID int
+	err := db.QueryRowContext(ctx, sqlQuery, id).Scan(&recipeID)
+	return recipeID, err
+}
+
+// ingredientVisibleCondition restricts a query on ingredients aliased as i to the
+// shared catalog and the ingredients of the tenant bound to the placeholder,
+// leaving out ingredients in the trash.
+const ingredientVisibleCondition = `(i.deleted_at IS NULL AND (i.household_id IS NULL OR i.household_id = %s::int))`
+
+// ingredientVisible returns ingredientVisibleCondition bound to the given placeholder.
+func ingredientVisible(householdPlaceholder string) string {
+	return fmt.Sprintf(ingredientVisibleCondition, householdPlaceholder)
+}
+
+// authorizeIngredient checks that the caller's tenant may use an ingredient.
+// It writes the error response and returns false when the handler should stop.
+func authorizeIngredient(c *gin.Context, db *sql.DB, ingredientID int) bool {
+	who := callerOf(c)
+	sqlQuery := `SELECT EXISTS (SELECT 1 FROM ingredients i WHERE i.ingredient_id = $1 AND ` + ingredientVisible("$2") + `)`
+
+	var exists bool
+	if err := db.QueryRowContext(c.Request.Context(), sqlQuery, ingredientID, who.householdID).Scan(&exists); err != nil {
+		c.Error(apierror.Internal("Error checking ingredient").WithCause(err))
+		return false
+	}
+	if !exists {
+		c.Error(apierror.NotFound("Ingredient not found"))
+		return false
+	}
+	return true
+}
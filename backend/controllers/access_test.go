@@ -0,0 +1,86 @@
+package controllers
+
+import (
+	"backend/models"
+	"database/sql"
+	"strings"
+	"testing"
+)
+
+func TestInTenant(t *testing.T) {
+	// NULL-safe comparison keeps personal data (no household) apart from
+	// every household's data.
+	if got, want := inTenant("r", "$2"), "r.household_id IS NOT DISTINCT FROM $2::int"; got != want {
+		t.Errorf("inTenant() = %q, want %q", got, want)
+	}
+}
+
+func TestRecipeViewable(t *testing.T) {
+	got := recipeViewable("$2", "$3")
+	for _, want := range []string{
+		"r.deleted_at IS NULL",
+		"r.household_id IS NOT DISTINCT FROM $3::int",
+		"r.owner_id = $2",
+		"rc.user_id = $2",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("recipeViewable() = %q, missing %q", got, want)
+		}
+	}
+	if strings.Contains(got, "%") {
+		t.Errorf("recipeViewable() = %q has unbound placeholders", got)
+	}
+	if !strings.HasPrefix(got, "(") || !strings.HasSuffix(got, ")") {
+		t.Errorf("recipeViewable() = %q is not parenthesized", got)
+	}
+}
+
+func TestIngredientVisible(t *testing.T) {
+	got := ingredientVisible("$2")
+	for _, want := range []string{"i.deleted_at IS NULL", "i.household_id IS NULL OR i.household_id = $2::int"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("ingredientVisible() = %q, missing %q", got, want)
+		}
+	}
+}
+
+func TestRecipeAccess(t *testing.T) {
+	household := 10
+	owner := sql.NullInt64{Int64: 1, Valid: true}
+	anonymous := caller{}
+	personal := caller{userID: 2}
+	editor := caller{userID: 2, householdID: &household, role: models.HouseholdRoleEditor}
+	viewer := caller{userID: 2, householdID: &household, role: models.HouseholdRoleViewer}
+
+	tests := []struct {
+		name     string
+		facts    recipeFacts
+		who      caller
+		wantView bool
+		wantEdit bool
+	}{
+		{"other tenant's public recipe", recipeFacts{ownerID: owner, visibility: models.VisibilityPublic}, personal, false, false},
+		{"other tenant's unowned recipe", recipeFacts{visibility: models.VisibilityPublic}, editor, false, false},
+		{"own private recipe", recipeFacts{ownerID: sql.NullInt64{Int64: 2, Valid: true}, visibility: models.VisibilityPrivate, sameTenant: true}, personal, true, true},
+		{"someone else's public recipe", recipeFacts{ownerID: owner, visibility: models.VisibilityPublic, sameTenant: true}, personal, true, false},
+		{"someone else's private recipe", recipeFacts{ownerID: owner, visibility: models.VisibilityPrivate, sameTenant: true}, editor, false, false},
+		{"shared recipe of the household", recipeFacts{ownerID: owner, visibility: models.VisibilityShared, sameTenant: true}, editor, true, true},
+		{"shared recipe of the household as viewer", recipeFacts{ownerID: owner, visibility: models.VisibilityShared, sameTenant: true}, viewer, true, false},
+		{"shared recipe as collaborator", recipeFacts{ownerID: owner, visibility: models.VisibilityShared, sameTenant: true, isCollaborator: true}, personal, true, true},
+		{"shared recipe without collaborating", recipeFacts{ownerID: owner, visibility: models.VisibilityShared, sameTenant: true}, personal, false, false},
+		{"private recipe as collaborator", recipeFacts{ownerID: owner, visibility: models.VisibilityPrivate, sameTenant: true, isCollaborator: true}, personal, false, false},
+		{"unowned recipe", recipeFacts{visibility: models.VisibilityPublic, sameTenant: true}, personal, true, false},
+		{"public recipe as anonymous caller", recipeFacts{ownerID: owner, visibility: models.VisibilityPublic, sameTenant: true}, anonymous, true, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			access := tt.facts.accessFor(tt.who)
+			if access.found != tt.facts.sameTenant {
+				t.Errorf("found = %v, want %v", access.found, tt.facts.sameTenant)
+			}
+			if access.canView != tt.wantView || access.canEdit != tt.wantEdit {
+				t.Errorf("canView, canEdit = %v, %v, want %v, %v", access.canView, access.canEdit, tt.wantView, tt.wantEdit)
+			}
+		})
+	}
+}
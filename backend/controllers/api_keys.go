@@ -0,0 +1,165 @@
+package controllers
+
+import (
+	"backend/apierror"
+	"backend/auth"
+	"backend/middleware"
+	"backend/models"
+	"database/sql"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lib/pq"
+)
+
+// CreateAPIKey creates an API key for the caller.
+// CreateAPIKey godoc
+// @Summary Create an API key
+// @Description Create an API key with the given scopes; the key is only shown once
+// @Tags api_keys
+// @Accept json
+// @Produce json
+// @Param api_key body models.APIKeyRequest true "Add API key"
+// @Param Idempotency-Key header string false "Unique key making retries of this request replay its first response"
+// @Success 201 {object} models.APIKey
+// @Failure 400 {object} apierror.Problem
+// @Failure 401 {object} apierror.Problem
+// @Failure 409 {object} apierror.Problem "Request with this idempotency key in progress"
+// @Failure 422 {object} apierror.Problem "Validation failed"
+// @Failure 500 {object} apierror.Problem
+// @Router /api-keys [post]
+func CreateAPIKey(c *gin.Context, db *sql.DB) {
+	// 1. The key acts on behalf of the caller.
+	userID, ok := middleware.RequireIdentity(c)
+	if !ok {
+		return
+	}
+
+	// 2. Bind the request JSON to the API key struct.
+	var apiKeyReq models.APIKeyRequest
+	if err := c.ShouldBindJSON(&apiKeyReq); err != nil {
+		c.Error(apierror.FromBinding(err))
+		return
+	}
+
+	// 3. Generate the key; only its hash is stored.
+	token, err := auth.NewToken()
+	if err != nil {
+		c.Error(apierror.Internal("Error generating API key").WithCause(err))
+		return
+	}
+	key := middleware.APIKeyPrefix + token
+
+	apiKey := models.APIKey{
+		Name:      apiKeyReq.Name,
+		KeyPrefix: key[:len(middleware.APIKeyPrefix)+8],
+		Key:       key,
+		Scopes:    apiKeyReq.Scopes,
+	}
+	sqlQuery := `
+		INSERT INTO api_keys (user_id, name, key_prefix, key_hash, scopes)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING api_key_id, created_at`
+	err = db.QueryRowContext(c.Request.Context(), sqlQuery, userID, apiKey.Name, apiKey.KeyPrefix, auth.HashToken(key), pq.Array(apiKey.Scopes)).Scan(&apiKey.APIKeyID, &apiKey.CreatedAt)
+	if err != nil {
+		c.Error(apierror.FromDB(err, "Error creating API key"))
+		return
+	}
+
+	// 4. Return a JSON response with the API key, including the key itself.
+	c.JSON(http.StatusCreated, apiKey)
+}
+
+// GetAPIKeys returns the caller's API keys.
+// GetAPIKeys godoc
+// @Summary Get the caller's API keys
+// @Description Get the caller's API keys, including revoked ones; keys themselves are never returned
+// @Tags api_keys
+// @Accept json
+// @Produce json
+// @Success 200 {array} models.APIKey
+// @Failure 401 {object} apierror.Problem
+// @Failure 500 {object} apierror.Problem
+// @Router /api-keys [get]
+func GetAPIKeys(c *gin.Context, db *sql.DB) {
+	userID, ok := middleware.RequireIdentity(c)
+	if !ok {
+		return
+	}
+
+	// 1. Query the database for the caller's API keys.
+	sqlQuery := `
+		SELECT api_key_id, name, key_prefix, scopes, created_at, last_used_at, revoked_at
+		FROM api_keys
+		WHERE user_id = $1
+		ORDER BY api_key_id`
+	rows, err := db.QueryContext(c.Request.Context(), sqlQuery, userID)
+	if err != nil {
+		c.Error(apierror.Internal("Error querying the database").WithCause(err))
+		return
+	}
+	defer rows.Close()
+
+	// 2. Iterate over the rows and add each API key to the slice.
+	apiKeys := []models.APIKey{}
+	for rows.Next() {
+		var apiKey models.APIKey
+		err := rows.Scan(&apiKey.APIKeyID, &apiKey.Name, &apiKey.KeyPrefix, pq.Array(&apiKey.Scopes), &apiKey.CreatedAt, &apiKey.LastUsedAt, &apiKey.RevokedAt)
+		if err != nil {
+			c.Error(apierror.Internal("Error scanning rows").WithCause(err))
+			return
+		}
+		apiKeys = append(apiKeys, apiKey)
+	}
+
+	// 3. Return a JSON response with the API keys.
+	c.JSON(http.StatusOK, apiKeys)
+}
+
+// RevokeAPIKey revokes one of the caller's API keys.
+// RevokeAPIKey godoc
+// @Summary Revoke an API key
+// @Description Revoke an API key so it can no longer be used
+// @Tags api_keys
+// @Accept json
+// @Produce json
+// @Param id path int true "API Key ID"
+// @Success 204 "API key revoked"
+// @Failure 400 {object} apierror.Problem
+// @Failure 401 {object} apierror.Problem
+// @Failure 404 {object} apierror.Problem
+// @Failure 500 {object} apierror.Problem
+// @Router /api-keys/{id} [delete]
+func RevokeAPIKey(c *gin.Context, db *sql.DB) {
+	userID, ok := middleware.RequireIdentity(c)
+	if !ok {
+		return
+	}
+
+	// 1. Extract the API key ID from the URL parameter.
+	apiKeyID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.Error(apierror.BadRequest("Invalid API key ID"))
+		return
+	}
+
+	// 2. Revoke the key; revoking twice keeps the original timestamp.
+	sqlQuery := `
+		UPDATE api_keys
+		SET revoked_at = COALESCE(revoked_at, $1)
+		WHERE api_key_id = $2 AND user_id = $3`
+	result, err := db.ExecContext(c.Request.Context(), sqlQuery, time.Now().UTC(), apiKeyID, userID)
+	if err != nil {
+		c.Error(apierror.FromDB(err, "Error revoking API key"))
+		return
+	}
+	if rows, err := result.RowsAffected(); err == nil && rows == 0 {
+		c.Error(apierror.NotFound("API key not found"))
+		return
+	}
+
+	// 3. Return a 204 No Content response.
+	c.Status(http.StatusNoContent)
+}
@@ -0,0 +1,222 @@
+package controllers
+
+import (
+	"backend/apierror"
+	"backend/audit"
+	"backend/middleware"
+	"backend/models"
+	"backend/revisions"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Bounds on the number of audit entries returned at once.
+const (
+	defaultAuditLimit = 100
+	maxAuditLimit     = 1000
+)
+
+// snapshotForAudit captures an entity's state before a change in tx.
+// It writes the error response and returns false when the handler should stop.
+func snapshotForAudit(c *gin.Context, tx *sql.Tx, entityType string, entityID int) (json.RawMessage, bool) {
+	before, err := audit.Snapshot(c.Request.Context(), tx, entityType, entityID)
+	if err != nil {
+		c.Error(apierror.Internal("Error reading current state for audit log").WithCause(err))
+		return nil, false
+	}
+	return before, true
+}
+
+// commitAudited records a change made in tx in the audit log and commits tx.
+// The entity's state after the change is captured from tx. It writes the
+// error response and returns false when the handler should stop.
+func commitAudited(c *gin.Context, tx *sql.Tx, action, entityType string, entityID int, before json.RawMessage) bool {
+	entry := auditEntry(c, action, entityType, entityID, before)
+	if action != audit.ActionDelete {
+		after, err := audit.Snapshot(c.Request.Context(), tx, entityType, entityID)
+		if err != nil {
+			c.Error(apierror.Internal("Error reading new state for audit log").WithCause(err))
+			return false
+		}
+		entry.After = after
+	}
+
+	if err := audit.Record(c.Request.Context(), tx, entry); err != nil {
+		c.Error(apierror.Internal("Error writing audit log").WithCause(err))
+		return false
+	}
+	if !recordRevisions(c, tx, entry) {
+		return false
+	}
+
+	if err := tx.Commit(); err != nil {
+		c.Error(apierror.FromDB(err, "Error committing transaction"))
+		return false
+	}
+	invalidateCachedEntity(c.Request.Context(), entityType)
+	return true
+}
+
+// recordRevisions stores a new revision of every recipe affected by the audited
+// changes in tx. It writes the error response and returns false when the
+// handler should stop.
+func recordRevisions(c *gin.Context, tx *sql.Tx, entries ...models.AuditEntry) bool {
+	var recipeIDs []int
+	seen := map[int]bool{}
+	for _, entry := range entries {
+		for _, recipeID := range affectedRecipes(entry) {
+			if !seen[recipeID] {
+				seen[recipeID] = true
+				recipeIDs = append(recipeIDs, recipeID)
+			}
+		}
+	}
+
+	for _, recipeID := range recipeIDs {
+		if err := revisions.Record(c.Request.Context(), tx, recipeID, entries[0].ActorID, entries[0].RequestID); err != nil {
+			c.Error(apierror.Internal("Error recording recipe revision").WithCause(err))
+			return false
+		}
+	}
+	return true
+}
+
+// affectedRecipes returns the recipes whose document an audited change
+// touches. Recipe ingredients and steps moved to another recipe change both.
+func affectedRecipes(entry models.AuditEntry) []int {
+	switch entry.EntityType {
+	case audit.EntityRecipe:
+		return []int{entry.EntityID}
+	case audit.EntityRecipeIngredient, audit.EntityRecipeStep:
+		var recipeIDs []int
+		for _, snapshot := range []json.RawMessage{entry.Before, entry.After} {
+			var row struct {
+				RecipeID int `json:"recipe_id"`
+			}
+			if len(snapshot) > 0 && json.Unmarshal(snapshot, &row) == nil && row.RecipeID != 0 {
+				recipeIDs = append(recipeIDs, row.RecipeID)
+			}
+		}
+		return recipeIDs
+	}
+	return nil
+}
+
+// auditEntry describes a change made by the current request.
+func auditEntry(c *gin.Context, action, entityType string, entityID int, before json.RawMessage) models.AuditEntry {
+	entry := models.AuditEntry{
+		Action:     action,
+		EntityType: entityType,
+		EntityID:   entityID,
+		Before:     before,
+		RequestID:  middleware.CurrentRequestID(c),
+	}
+	if userID, ok := middleware.CurrentUserID(c); ok {
+		entry.ActorID = &userID
+	}
+	return entry
+}
+
+// GetAuditLog lists audit log entries, newest first.
+// GetAuditLog godoc
+// @Summary Get audit log entries
+// @Description Get recorded changes, newest first, optionally filtered
+// @Tags audit
+// @Accept json
+// @Produce json
+// @Param actor_id query int false "Filter by acting user"
+// @Param action query string false "Filter by action (create, update, delete)"
+// @Param entity_type query string false "Filter by entity type (recipe, ingredient, recipe_ingredient, recipe_step)"
+// @Param entity_id query int false "Filter by entity ID"
+// @Param request_id query string false "Filter by request ID"
+// @Param since query string false "Only entries at or after this RFC 3339 time"
+// @Param until query string false "Only entries before this RFC 3339 time"
+// @Param limit query int false "Maximum number of entries (default 100, max 1000)"
+// @Success 200 {array} models.AuditEntry
+// @Failure 400 {object} apierror.Problem
+// @Failure 403 {object} apierror.Problem
+// @Failure 500 {object} apierror.Problem
+// @Router /audit [get]
+func GetAuditLog(c *gin.Context, db *sql.DB) {
+	// 1. Parse the filters; absent filters are passed as NULL.
+	var actorID, entityID *int
+	var action, entityType, requestID *string
+	var since, until *time.Time
+	limit := defaultAuditLimit
+
+	for name, target := range map[string]**int{"actor_id": &actorID, "entity_id": &entityID} {
+		if value := c.Query(name); value != "" {
+			n, err := strconv.Atoi(value)
+			if err != nil {
+				c.Error(apierror.BadRequest("Invalid " + name))
+				return
+			}
+			*target = &n
+		}
+	}
+	for name, target := range map[string]**string{"action": &action, "entity_type": &entityType, "request_id": &requestID} {
+		if value := c.Query(name); value != "" {
+			*target = &value
+		}
+	}
+	for name, target := range map[string]**time.Time{"since": &since, "until": &until} {
+		if value := c.Query(name); value != "" {
+			t, err := time.Parse(time.RFC3339, value)
+			if err != nil {
+				c.Error(apierror.BadRequest("Invalid " + name + ", expected RFC 3339 time"))
+				return
+			}
+			*target = &t
+		}
+	}
+	if value := c.Query("limit"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n <= 0 || n > maxAuditLimit {
+			c.Error(apierror.BadRequest("Limit must be between 1 and 1000"))
+			return
+		}
+		limit = n
+	}
+
+	// 2. Query the database for matching entries.
+	sqlQuery := `
+		SELECT audit_id, actor_id, action, entity_type, entity_id, before, after, request_id, created_at
+		FROM audit_log
+		WHERE ($1::int IS NULL OR actor_id = $1)
+			AND ($2::text IS NULL OR action = $2)
+			AND ($3::text IS NULL OR entity_type = $3)
+			AND ($4::int IS NULL OR entity_id = $4)
+			AND ($5::text IS NULL OR request_id = $5)
+			AND ($6::timestamptz IS NULL OR created_at >= $6)
+			AND ($7::timestamptz IS NULL OR created_at < $7)
+		ORDER BY audit_id DESC
+		LIMIT $8`
+	rows, err := db.QueryContext(c.Request.Context(), sqlQuery, actorID, action, entityType, entityID, requestID, since, until, limit)
+	if err != nil {
+		c.Error(apierror.Internal("Error querying the database").WithCause(err))
+		return
+	}
+	defer rows.Close()
+
+	// 3. Iterate over the rows and add each entry to the slice.
+	entries := []models.AuditEntry{}
+	for rows.Next() {
+		var entry models.AuditEntry
+		var before, after []byte
+		err := rows.Scan(&entry.AuditID, &entry.ActorID, &entry.Action, &entry.EntityType, &entry.EntityID, &before, &after, &entry.RequestID, &entry.CreatedAt)
+		if err != nil {
+			c.Error(apierror.Internal("Error scanning rows").WithCause(err))
+			return
+		}
+		entry.Before, entry.After = before, after
+		entries = append(entries, entry)
+	}
+
+	// 4. Return a JSON response with the entries.
+	c.JSON(http.StatusOK, entries)
+}
@@ -0,0 +1,172 @@
+package controllers
+
+import (
+	"backend/apierror"
+	"backend/audit"
+	"backend/cache"
+	"backend/changefeed"
+	"backend/metrics"
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ResponseCache stores the responses of recipe and ingredient reads. Nil
+// disables caching.
+var ResponseCache cache.Cache
+
+// ResponseCacheTTL bounds how long a cached response is served. Writes through
+// the controllers invalidate responses earlier; the TTL bounds how stale
+// responses get after changes made elsewhere.
+var ResponseCacheTTL = 30 * time.Second
+
+// CacheMaxAge is how long clients may reuse a response without revalidating
+// it. With zero, clients revalidate every time, using the response's ETag.
+var CacheMaxAge time.Duration
+
+// Response cache namespaces, invalidated as a whole on writes to their entities.
+const (
+	cacheRecipes     = "recipes"
+	cacheIngredients = "ingredients"
+)
+
+// cachedEntities maps the audited entity types to the cache namespace
+// holding responses that show them.
+var cachedEntities = map[string]string{
+	audit.EntityRecipe:     cacheRecipes,
+	audit.EntityIngredient: cacheIngredients,
+}
+
+// cacheGenerations are bumped on invalidation and are part of every key, so
+// that reads which began before a write cannot store their stale response
+// where later reads look.
+var cacheGenerations = map[string]*atomic.Uint64{
+	cacheRecipes:     new(atomic.Uint64),
+	cacheIngredients: new(atomic.Uint64),
+}
+
+// cacheKey identifies a response by namespace, caller and request URI, since
+// what callers may see depends on who they are and the household they act in.
+func cacheKey(c *gin.Context, namespace string) string {
+	who := callerOf(c)
+	household := ""
+	if who.householdID != nil {
+		household = strconv.Itoa(*who.householdID)
+	}
+	generation := strconv.FormatUint(cacheGenerations[namespace].Load(), 10)
+	return namespace + ":" + generation + ":" + strconv.Itoa(who.userID) + ":" + household + ":" + c.Request.URL.RequestURI()
+}
+
+// serveCached sets the Cache-Control header of a cacheable read and answers it
+// from namespace of the cache if possible. It returns the key to store the
+// response under, taken before the read so that a write in the meantime
+// leaves it where no later read looks, and true once a response has been
+// written.
+func serveCached(c *gin.Context, namespace string) (string, bool) {
+	c.Header("Cache-Control", cacheControl())
+	key := cacheKey(c, namespace)
+	if ResponseCache == nil {
+		return key, false
+	}
+
+	entry, ok := ResponseCache.Get(c.Request.Context(), key)
+	metrics.ObserveCacheLookup(namespace, ok)
+	if !ok {
+		return key, false
+	}
+	tag, body, ok := bytes.Cut(entry, []byte("\n"))
+	if !ok {
+		return key, false
+	}
+	writeWithETag(c, string(tag), body)
+	return key, true
+}
+
+// respondCached writes body with its entity tag, as respondWithETag does, and
+// stores the response under key.
+func respondCached(c *gin.Context, key, tag string, body interface{}) {
+	data, err := json.Marshal(body)
+	if err != nil {
+		c.Error(apierror.Internal("Error encoding response").WithCause(err))
+		return
+	}
+	if ResponseCache != nil {
+		ResponseCache.Set(c.Request.Context(), key, append([]byte(tag+"\n"), data...), ResponseCacheTTL)
+	}
+	writeWithETag(c, tag, data)
+}
+
+// respondCachedList writes a list with an entity tag derived from its content,
+// storing the response under key.
+func respondCachedList(c *gin.Context, key string, body interface{}) {
+	tag, err := listETag(body)
+	if err != nil {
+		c.Error(apierror.Internal("Error encoding response").WithCause(err))
+		return
+	}
+	respondCached(c, key, tag, body)
+}
+
+// writeWithETag writes an encoded JSON body with its entity tag, or 304 Not
+// Modified when the client's If-None-Match shows it already has it.
+func writeWithETag(c *gin.Context, tag string, body []byte) {
+	c.Header("ETag", tag)
+	if header := c.GetHeader("If-None-Match"); header != "" && matchesETag(header, tag, true) {
+		c.Status(http.StatusNotModified)
+		return
+	}
+	c.Data(http.StatusOK, "application/json; charset=utf-8", body)
+}
+
+// cacheControl returns the Cache-Control header of cacheable reads. Responses
+// depend on the caller, so shared caches must not store them.
+func cacheControl() string {
+	if CacheMaxAge <= 0 {
+		return "private, no-cache"
+	}
+	return "private, max-age=" + strconv.Itoa(int(CacheMaxAge.Seconds()))
+}
+
+// invalidateCache drops the cached responses of namespaces.
+func invalidateCache(ctx context.Context, namespaces ...string) {
+	for _, namespace := range namespaces {
+		cacheGenerations[namespace].Add(1)
+		if ResponseCache != nil {
+			ResponseCache.DeletePrefix(ctx, namespace+":")
+		}
+	}
+}
+
+// invalidateCachedEntity drops the cached responses showing entities of entityType.
+func invalidateCachedEntity(ctx context.Context, entityType string) {
+	if namespace, ok := cachedEntities[entityType]; ok {
+		invalidateCache(ctx, namespace)
+	}
+}
+
+// RunCacheInvalidation drops the cached responses showing the entities changes
+// announces, including those changed by other instances, until ctx is done or
+// changes is closed.
+func RunCacheInvalidation(ctx context.Context, changes <-chan changefeed.Change) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case change, ok := <-changes:
+			if !ok {
+				return
+			}
+			if change.Operation == changefeed.OperationReset {
+				invalidateCache(ctx, cacheRecipes, cacheIngredients)
+				continue
+			}
+			invalidateCachedEntity(ctx, change.Entity)
+		}
+	}
+}
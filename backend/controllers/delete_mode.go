@@ -0,0 +1,121 @@
+package controllers
+
+import (
+	"backend/apierror"
+	"backend/audit"
+	"backend/models"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// DeleteMode decides what happens to the rows using a recipe or ingredient
+// when it is moved to the trash.
+type DeleteMode string
+
+// Delete modes.
+const (
+	// DeleteRestrict refuses to delete rows that are still in use.
+	DeleteRestrict DeleteMode = "restrict"
+	// DeleteCascade moves the rows using a deleted row to the trash along
+	// with it, and restores them with it.
+	DeleteCascade DeleteMode = "cascade"
+)
+
+// Delete modes for recipes and ingredients, configured at startup.
+var (
+	RecipeDeleteMode     = DeleteRestrict
+	IngredientDeleteMode = DeleteRestrict
+)
+
+// ParseDeleteMode parses a configured delete mode. An empty value selects
+// DeleteRestrict.
+func ParseDeleteMode(value string) (DeleteMode, error) {
+	switch mode := DeleteMode(value); mode {
+	case "":
+		return DeleteRestrict, nil
+	case DeleteRestrict, DeleteCascade:
+		return mode, nil
+	}
+	return "", fmt.Errorf("invalid delete mode %q, expected %q or %q", value, DeleteRestrict, DeleteCascade)
+}
+
+// refuseIfUsed checks that no live row of table references id through column.
+// Deleting a row that is still in use conflicts with the rows using it. It
+// writes the error response and returns false when the handler should stop.
+func refuseIfUsed(c *gin.Context, tx *sql.Tx, entity, table, column string, id int) bool {
+	sqlQuery := fmt.Sprintf(`SELECT EXISTS (SELECT 1 FROM %s WHERE %s = $1 AND deleted_at IS NULL)`, table, column)
+	var used bool
+	if err := tx.QueryRowContext(c.Request.Context(), sqlQuery, id).Scan(&used); err != nil {
+		c.Error(apierror.Internal("Error checking dependent records").WithCause(err))
+		return false
+	}
+	if used {
+		c.Error(apierror.Conflict("The " + entity + " is still used by " + strings.ReplaceAll(table, "_", " ")))
+		return false
+	}
+	return true
+}
+
+// trashDependents moves the live rows of table whose column references
+// parentID to the trash, stamped with the transaction time like their parent,
+// and records each of them in the audit log as deleted. It writes the error
+// response and returns false when the handler should stop.
+func trashDependents(c *gin.Context, tx *sql.Tx, entityType, table, idColumn, column string, parentID int) bool {
+	return moveDependents(c, tx, audit.ActionDelete, entityType, table, idColumn,
+		fmt.Sprintf(`t.%s = $1 AND t.deleted_at IS NULL`, column), `NOW()`, parentID)
+}
+
+// restoreDependents takes the rows of table whose column references parentID
+// and that were trashed along with it at deletedAt out of the trash, and
+// records each of them in the audit log as restored. It writes the error
+// response and returns false when the handler should stop.
+func restoreDependents(c *gin.Context, tx *sql.Tx, entityType, table, idColumn, column string, parentID int, deletedAt time.Time) bool {
+	return moveDependents(c, tx, audit.ActionRestore, entityType, table, idColumn,
+		fmt.Sprintf(`t.%s = $1 AND t.deleted_at = $2`, column), `NULL`, parentID, deletedAt)
+}
+
+// moveDependents sets deleted_at of the rows of table matching condition and
+// audits each change as action.
+func moveDependents(c *gin.Context, tx *sql.Tx, action, entityType, table, idColumn, condition, deletedAt string, args ...interface{}) bool {
+	sqlQuery := fmt.Sprintf(`
+		WITH old AS (SELECT t.%[2]s, row_to_json(t) AS snapshot FROM %[1]s t WHERE %[3]s FOR UPDATE)
+		UPDATE %[1]s t SET deleted_at = %[4]s
+		FROM old WHERE t.%[2]s = old.%[2]s
+		RETURNING t.%[2]s, old.snapshot, row_to_json(t)`, table, idColumn, condition, deletedAt)
+	rows, err := tx.QueryContext(c.Request.Context(), sqlQuery, args...)
+	if err != nil {
+		c.Error(apierror.FromDB(err, "Error updating dependent records"))
+		return false
+	}
+
+	var entries []models.AuditEntry
+	for rows.Next() {
+		var entityID int
+		var before, after []byte
+		if err := rows.Scan(&entityID, &before, &after); err != nil {
+			rows.Close()
+			c.Error(apierror.Internal("Error scanning rows").WithCause(err))
+			return false
+		}
+		entry := auditEntry(c, action, entityType, entityID, before)
+		entry.After = after
+		entries = append(entries, entry)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		c.Error(apierror.FromDB(err, "Error updating dependent records"))
+		return false
+	}
+
+	for _, entry := range entries {
+		if err := audit.Record(c.Request.Context(), tx, entry); err != nil {
+			c.Error(apierror.Internal("Error writing audit log").WithCause(err))
+			return false
+		}
+	}
+	return len(entries) == 0 || recordRevisions(c, tx, entries...)
+}
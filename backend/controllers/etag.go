@@ -0,0 +1,99 @@
+package controllers
+
+import (
+	"backend/apierror"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// etag formats a row version as a strong entity tag.
+func etag(version int) string {
+	return `"` + strconv.Itoa(version) + `"`
+}
+
+// listETag derives a weak entity tag for a list from its JSON encoding.
+func listETag(body interface{}) (string, error) {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return `W/"` + hex.EncodeToString(sum[:16]) + `"`, nil
+}
+
+// matchesETag reports whether an If-Match or If-None-Match header lists tag.
+// If-None-Match compares weakly; If-Match only matches strong tags.
+func matchesETag(header, tag string, weak bool) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" {
+			return true
+		}
+		if weak {
+			if strings.TrimPrefix(candidate, "W/") == strings.TrimPrefix(tag, "W/") {
+				return true
+			}
+		} else if candidate == tag && !strings.HasPrefix(tag, "W/") {
+			return true
+		}
+	}
+	return false
+}
+
+// respondWithETag writes body with its entity tag, or 304 Not Modified when
+// the client's If-None-Match shows it already has this representation.
+func respondWithETag(c *gin.Context, tag string, body interface{}) {
+	c.Header("ETag", tag)
+	if header := c.GetHeader("If-None-Match"); header != "" && matchesETag(header, tag, true) {
+		c.Status(http.StatusNotModified)
+		return
+	}
+	c.JSON(http.StatusOK, body)
+}
+
+// respondWithListETag writes a list with an entity tag derived from its content.
+func respondWithListETag(c *gin.Context, body interface{}) {
+	tag, err := listETag(body)
+	if err != nil {
+		c.Error(apierror.Internal("Error encoding response").WithCause(err))
+		return
+	}
+	respondWithETag(c, tag, body)
+}
+
+// checkIfMatch enforces the request's If-Match header against the stored
+// version of a row, locking the row for the rest of tx. Requests without
+// If-Match are unconditional. It writes the error response and returns false
+// when the handler should stop.
+func checkIfMatch(c *gin.Context, tx *sql.Tx, table, idColumn string, id int) bool {
+	header := c.GetHeader("If-Match")
+	if header == "" {
+		return true
+	}
+
+	sqlQuery := fmt.Sprintf(`SELECT version FROM %s WHERE %s = $1 FOR UPDATE`, table, idColumn)
+	var version int
+	err := tx.QueryRowContext(c.Request.Context(), sqlQuery, id).Scan(&version)
+	if err == sql.ErrNoRows {
+		c.Error(apierror.PreconditionFailed("The resource no longer exists"))
+		return false
+	}
+	if err != nil {
+		c.Error(apierror.Internal("Error checking resource version").WithCause(err))
+		return false
+	}
+
+	if current := etag(version); !matchesETag(header, current, false) {
+		c.Error(apierror.PreconditionFailed("The resource has been modified; fetch it again and retry").With("etag", current))
+		return false
+	}
+	return true
+}
@@ -0,0 +1,113 @@
+package controllers
+
+import (
+	"backend/db"
+	"backend/logging"
+	"backend/models"
+	"context"
+	"database/sql"
+	"fmt"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ReadinessTimeout bounds each dependency check of the readiness probe.
+var ReadinessTimeout = 2 * time.Second
+
+// shuttingDown is set once the server starts shutting down.
+var shuttingDown atomic.Bool
+
+// SetShuttingDown makes the readiness probe fail, so that load balancers stop
+// sending traffic before the server stops accepting it.
+func SetShuttingDown() {
+	shuttingDown.Store(true)
+}
+
+// GetLiveness reports that the process is up.
+// GetLiveness godoc
+// @Summary Liveness probe
+// @Description Report that the process is running. Dependencies are not checked.
+// @Tags health
+// @Produce json
+// @Success 200 {object} models.Health
+// @Router /healthz [get]
+func GetLiveness(c *gin.Context) {
+	c.JSON(http.StatusOK, models.Health{Status: models.HealthOK})
+}
+
+// GetReadiness reports whether the service can handle requests.
+// GetReadiness godoc
+// @Summary Readiness probe
+// @Description Check that the database is reachable with its schema applied, and report connection pool usage. A saturated pool degrades the service without failing the probe. The probe fails while the server shuts down.
+// @Tags health
+// @Produce json
+// @Success 200 {object} models.Health
+// @Failure 503 {object} models.Health "Not ready"
+// @Router /readyz [get]
+func GetReadiness(c *gin.Context, database *sql.DB) {
+	health := models.Health{Status: models.HealthOK, Components: map[string]models.ComponentHealth{}}
+
+	// 1. Refuse traffic while shutting down.
+	if shuttingDown.Load() {
+		health.Components["server"] = models.ComponentHealth{Status: models.HealthFailing, Detail: "shutting down"}
+	} else {
+		health.Components["server"] = models.ComponentHealth{Status: models.HealthOK}
+	}
+
+	// 2. Check that the database answers, and that the schema is applied.
+	health.Components["database"] = checkComponent(c.Request.Context(), "database", func(ctx context.Context) error {
+		return database.PingContext(ctx)
+	})
+	health.Components["schema"] = checkComponent(c.Request.Context(), "schema", func(ctx context.Context) error {
+		return db.CheckSchema(ctx, database)
+	})
+
+	// 3. Report how busy the connection pool is.
+	stats := database.Stats()
+	pool := models.ComponentHealth{
+		Status: models.HealthOK,
+		Detail: fmt.Sprintf("%d of %d connections in use, %d waits", stats.InUse, stats.MaxOpenConnections, stats.WaitCount),
+	}
+	if stats.MaxOpenConnections > 0 && stats.InUse >= stats.MaxOpenConnections {
+		pool.Status = models.HealthDegraded
+	}
+	health.Components["pool"] = pool
+
+	// 4. The service is as healthy as its least healthy component.
+	for _, component := range health.Components {
+		if component.Status == models.HealthFailing {
+			health.Status = models.HealthFailing
+		} else if component.Status == models.HealthDegraded && health.Status == models.HealthOK {
+			health.Status = models.HealthDegraded
+		}
+	}
+
+	status := http.StatusOK
+	if health.Status == models.HealthFailing {
+		status = http.StatusServiceUnavailable
+	}
+	c.JSON(status, health)
+}
+
+// checkComponent runs a dependency check within ReadinessTimeout and reports
+// its outcome. Probes are public, so failures are logged rather than reported.
+func checkComponent(ctx context.Context, name string, check func(context.Context) error) models.ComponentHealth {
+	ctx, cancel := context.WithTimeout(ctx, ReadinessTimeout)
+	defer cancel()
+
+	start := time.Now()
+	err := check(ctx)
+	component := models.ComponentHealth{Status: models.HealthOK, DurationMS: time.Since(start).Milliseconds()}
+	if err != nil {
+		logging.FromContext(ctx).Warn("readiness check failed", "component", name, "error", err)
+		component.Status = models.HealthFailing
+		component.Detail = "check failed"
+		if ctx.Err() == context.DeadlineExceeded {
+			component.Detail = "check timed out"
+		}
+	}
+	return component
+}
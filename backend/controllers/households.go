@@ -0,0 +1,495 @@
+package controllers
+
+import (
+	"backend/apierror"
+	"backend/auth"
+	"backend/middleware"
+	"backend/models"
+	"database/sql"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// invitationTTL is how long a household invitation can be accepted.
+const invitationTTL = 7 * 24 * time.Hour
+
+// CreateHousehold creates a new household owned by the caller.
+// CreateHousehold godoc
+// @Summary Create a new household
+// @Description Create a household; the caller becomes its owner
+// @Tags households
+// @Accept json
+// @Produce json
+// @Param household body models.HouseholdRequest true "Add household"
+// @Param Idempotency-Key header string false "Unique key making retries of this request replay its first response"
+// @Success 201 {object} models.Household
+// @Failure 400 {object} apierror.Problem
+// @Failure 401 {object} apierror.Problem
+// @Failure 409 {object} apierror.Problem "Request with this idempotency key in progress"
+// @Failure 422 {object} apierror.Problem "Validation failed"
+// @Failure 500 {object} apierror.Problem
+// @Router /households [post]
+func CreateHousehold(c *gin.Context, db *sql.DB) {
+	// 1. The caller becomes the owner of the household.
+	userID, ok := middleware.RequireIdentity(c)
+	if !ok {
+		return
+	}
+
+	// 2. Bind the request JSON to the household struct.
+	var householdReq models.HouseholdRequest
+	if err := c.ShouldBindJSON(&householdReq); err != nil {
+		c.Error(apierror.FromBinding(err))
+		return
+	}
+
+	// 3. Save the household and its owner in one transaction.
+	tx, err := db.BeginTx(c.Request.Context(), nil)
+	if err != nil {
+		c.Error(apierror.Internal("Error starting transaction").WithCause(err))
+		return
+	}
+	defer tx.Rollback()
+
+	var householdID int
+	sqlQuery := `INSERT INTO households (household_name) VALUES ($1) RETURNING household_id`
+	if err := tx.QueryRowContext(c.Request.Context(), sqlQuery, householdReq.HouseholdName).Scan(&householdID); err != nil {
+		c.Error(apierror.FromDB(err, "Error creating household"))
+		return
+	}
+
+	sqlQuery = `INSERT INTO household_members (household_id, user_id, role) VALUES ($1, $2, $3)`
+	if _, err := tx.ExecContext(c.Request.Context(), sqlQuery, householdID, userID, models.HouseholdRoleOwner); err != nil {
+		c.Error(apierror.FromDB(err, "Error adding household owner"))
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		c.Error(apierror.FromDB(err, "Error committing transaction"))
+		return
+	}
+
+	// 4. Return a JSON response with the created household.
+	c.JSON(http.StatusCreated, models.Household{
+		HouseholdID:   householdID,
+		HouseholdName: householdReq.HouseholdName,
+		Role:          models.HouseholdRoleOwner,
+	})
+}
+
+// GetHouseholds returns the households the caller belongs to.
+// GetHouseholds godoc
+// @Summary Get the caller's households
+// @Description Get the households the caller is a member of, with the caller's role
+// @Tags households
+// @Accept json
+// @Produce json
+// @Success 200 {array} models.Household
+// @Failure 401 {object} apierror.Problem
+// @Failure 500 {object} apierror.Problem
+// @Router /households [get]
+func GetHouseholds(c *gin.Context, db *sql.DB) {
+	userID, ok := middleware.RequireIdentity(c)
+	if !ok {
+		return
+	}
+
+	// 1. Query the database for the caller's households.
+	sqlQuery := `
+		SELECT h.household_id, h.household_name, m.role
+		FROM households h
+		JOIN household_members m ON m.household_id = h.household_id
+		WHERE m.user_id = $1
+		ORDER BY h.household_id`
+	rows, err := db.QueryContext(c.Request.Context(), sqlQuery, userID)
+	if err != nil {
+		c.Error(apierror.Internal("Error querying the database").WithCause(err))
+		return
+	}
+	defer rows.Close()
+
+	// 2. Iterate over the rows and add each household to the slice.
+	households := []models.Household{}
+	for rows.Next() {
+		var household models.Household
+		if err := rows.Scan(&household.HouseholdID, &household.HouseholdName, &household.Role); err != nil {
+			c.Error(apierror.Internal("Error scanning rows").WithCause(err))
+			return
+		}
+		households = append(households, household)
+	}
+
+	// 3. Return a JSON response with the households.
+	c.JSON(http.StatusOK, households)
+}
+
+// GetHouseholdMembers returns the members of a household.
+// GetHouseholdMembers godoc
+// @Summary Get the members of a household
+// @Description Get the members of a household the caller belongs to
+// @Tags households
+// @Accept json
+// @Produce json
+// @Param id path int true "Household ID"
+// @Success 200 {array} models.HouseholdMember
+// @Failure 400 {object} apierror.Problem
+// @Failure 401 {object} apierror.Problem
+// @Failure 404 {object} apierror.Problem
+// @Failure 500 {object} apierror.Problem
+// @Router /households/{id}/members [get]
+func GetHouseholdMembers(c *gin.Context, db *sql.DB) {
+	// 1. Check that the caller belongs to the household.
+	householdID, _, ok := authorizeHousehold(c, db, false)
+	if !ok {
+		return
+	}
+
+	// 2. Query the database for the members.
+	sqlQuery := `SELECT household_id, user_id, role FROM household_members WHERE household_id = $1 ORDER BY user_id`
+	rows, err := db.QueryContext(c.Request.Context(), sqlQuery, householdID)
+	if err != nil {
+		c.Error(apierror.Internal("Error querying the database").WithCause(err))
+		return
+	}
+	defer rows.Close()
+
+	// 3. Iterate over the rows and add each member to the slice.
+	members := []models.HouseholdMember{}
+	for rows.Next() {
+		var member models.HouseholdMember
+		if err := rows.Scan(&member.HouseholdID, &member.UserID, &member.Role); err != nil {
+			c.Error(apierror.Internal("Error scanning rows").WithCause(err))
+			return
+		}
+		members = append(members, member)
+	}
+
+	// 4. Return a JSON response with the members.
+	c.JSON(http.StatusOK, members)
+}
+
+// UpdateHouseholdMember changes the role of a household member.
+// UpdateHouseholdMember godoc
+// @Summary Change a member's role
+// @Description Change the role of a household member; owners only
+// @Tags households
+// @Accept json
+// @Produce json
+// @Param id path int true "Household ID"
+// @Param user_id path int true "User ID"
+// @Param member body models.HouseholdMemberRequest true "Member role"
+// @Success 200 {object} models.HouseholdMember
+// @Failure 400 {object} apierror.Problem
+// @Failure 401 {object} apierror.Problem
+// @Failure 403 {object} apierror.Problem
+// @Failure 404 {object} apierror.Problem
+// @Failure 409 {object} apierror.Problem
+// @Failure 422 {object} apierror.Problem "Validation failed"
+// @Failure 500 {object} apierror.Problem
+// @Router /households/{id}/members/{user_id} [put]
+func UpdateHouseholdMember(c *gin.Context, db *sql.DB) {
+	// 1. Only owners may change roles.
+	householdID, _, ok := authorizeHousehold(c, db, true)
+	if !ok {
+		return
+	}
+
+	// 2. Extract the member and bind the new role.
+	memberID, err := strconv.Atoi(c.Param("user_id"))
+	if err != nil {
+		c.Error(apierror.BadRequest("Invalid user ID"))
+		return
+	}
+	var memberReq models.HouseholdMemberRequest
+	if err := c.ShouldBindJSON(&memberReq); err != nil {
+		c.Error(apierror.FromBinding(err))
+		return
+	}
+
+	// 3. Update the member in the database; a household must keep at least one owner.
+	tx, err := db.BeginTx(c.Request.Context(), nil)
+	if err != nil {
+		c.Error(apierror.Internal("Error starting transaction").WithCause(err))
+		return
+	}
+	defer tx.Rollback()
+	if memberReq.Role != models.HouseholdRoleOwner && !keepsHouseholdOwner(c, tx, householdID, memberID) {
+		return
+	}
+
+	sqlQuery := `UPDATE household_members SET role = $1 WHERE household_id = $2 AND user_id = $3`
+	result, err := tx.ExecContext(c.Request.Context(), sqlQuery, memberReq.Role, householdID, memberID)
+	if err != nil {
+		c.Error(apierror.FromDB(err, "Error updating household member"))
+		return
+	}
+	if rows, err := result.RowsAffected(); err == nil && rows == 0 {
+		c.Error(apierror.NotFound("Household member not found"))
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		c.Error(apierror.FromDB(err, "Error committing transaction"))
+		return
+	}
+
+	// 4. Return a JSON response with the updated member.
+	c.JSON(http.StatusOK, models.HouseholdMember{HouseholdID: householdID, UserID: memberID, Role: memberReq.Role})
+}
+
+// RemoveHouseholdMember removes a member from a household.
+// RemoveHouseholdMember godoc
+// @Summary Remove a household member
+// @Description Remove a member from a household; owners may remove anyone, members may leave
+// @Tags households
+// @Accept json
+// @Produce json
+// @Param id path int true "Household ID"
+// @Param user_id path int true "User ID"
+// @Success 204 "Member removed"
+// @Failure 400 {object} apierror.Problem
+// @Failure 401 {object} apierror.Problem
+// @Failure 403 {object} apierror.Problem
+// @Failure 404 {object} apierror.Problem
+// @Failure 409 {object} apierror.Problem
+// @Failure 500 {object} apierror.Problem
+// @Router /households/{id}/members/{user_id} [delete]
+func RemoveHouseholdMember(c *gin.Context, db *sql.DB) {
+	// 1. Check that the caller belongs to the household.
+	householdID, role, ok := authorizeHousehold(c, db, false)
+	if !ok {
+		return
+	}
+
+	// 2. Extract the member; anyone may leave, only owners may remove others.
+	memberID, err := strconv.Atoi(c.Param("user_id"))
+	if err != nil {
+		c.Error(apierror.BadRequest("Invalid user ID"))
+		return
+	}
+	userID, _ := middleware.CurrentUserID(c)
+	if memberID != userID && role != models.HouseholdRoleOwner {
+		c.Error(apierror.Forbidden("Only household owners can remove other members"))
+		return
+	}
+
+	// 3. Delete the member from the database; a household must keep at least one owner.
+	tx, err := db.BeginTx(c.Request.Context(), nil)
+	if err != nil {
+		c.Error(apierror.Internal("Error starting transaction").WithCause(err))
+		return
+	}
+	defer tx.Rollback()
+	if !keepsHouseholdOwner(c, tx, householdID, memberID) {
+		return
+	}
+
+	sqlQuery := `DELETE FROM household_members WHERE household_id = $1 AND user_id = $2`
+	if _, err := tx.ExecContext(c.Request.Context(), sqlQuery, householdID, memberID); err != nil {
+		c.Error(apierror.FromDB(err, "Error removing household member"))
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		c.Error(apierror.FromDB(err, "Error committing transaction"))
+		return
+	}
+
+	// 4. Return a 204 No Content response.
+	c.Status(http.StatusNoContent)
+}
+
+// CreateHouseholdInvitation creates an invitation token for a household.
+// CreateHouseholdInvitation godoc
+// @Summary Invite someone to a household
+// @Description Create an invitation token with a role; the token is only shown once
+// @Tags households
+// @Accept json
+// @Produce json
+// @Param id path int true "Household ID"
+// @Param invitation body models.HouseholdInvitationRequest true "Invitation"
+// @Param Idempotency-Key header string false "Unique key making retries of this request replay its first response"
+// @Success 201 {object} models.HouseholdInvitation
+// @Failure 400 {object} apierror.Problem
+// @Failure 401 {object} apierror.Problem
+// @Failure 403 {object} apierror.Problem
+// @Failure 404 {object} apierror.Problem
+// @Failure 409 {object} apierror.Problem "Request with this idempotency key in progress"
+// @Failure 422 {object} apierror.Problem "Validation failed"
+// @Failure 500 {object} apierror.Problem
+// @Router /households/{id}/invitations [post]
+func CreateHouseholdInvitation(c *gin.Context, db *sql.DB) {
+	// 1. Only owners may invite.
+	householdID, _, ok := authorizeHousehold(c, db, true)
+	if !ok {
+		return
+	}
+
+	// 2. Bind the request JSON to the invitation struct.
+	var invitationReq models.HouseholdInvitationRequest
+	if err := c.ShouldBindJSON(&invitationReq); err != nil {
+		c.Error(apierror.FromBinding(err))
+		return
+	}
+
+	// 3. Generate a token; only its hash is stored.
+	token, err := auth.NewToken()
+	if err != nil {
+		c.Error(apierror.Internal("Error generating invitation token").WithCause(err))
+		return
+	}
+
+	userID, _ := middleware.CurrentUserID(c)
+	invitation := models.HouseholdInvitation{
+		HouseholdID: householdID,
+		Role:        invitationReq.Role,
+		Token:       token,
+		ExpiresAt:   time.Now().Add(invitationTTL).UTC(),
+	}
+	sqlQuery := `
+		INSERT INTO household_invitations (household_id, token_hash, role, created_by, expires_at)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING invitation_id`
+	err = db.QueryRowContext(c.Request.Context(), sqlQuery, householdID, auth.HashToken(token), invitation.Role, userID, invitation.ExpiresAt).Scan(&invitation.InvitationID)
+	if err != nil {
+		c.Error(apierror.FromDB(err, "Error creating invitation"))
+		return
+	}
+
+	// 4. Return a JSON response with the invitation, including the token.
+	c.JSON(http.StatusCreated, invitation)
+}
+
+// AcceptHouseholdInvitation joins the caller to the household of an invitation.
+// AcceptHouseholdInvitation godoc
+// @Summary Accept a household invitation
+// @Description Join a household using an invitation token
+// @Tags households
+// @Accept json
+// @Produce json
+// @Param invitation body models.AcceptInvitationRequest true "Invitation token"
+// @Param Idempotency-Key header string false "Unique key making retries of this request replay its first response"
+// @Success 200 {object} models.HouseholdMember
+// @Failure 400 {object} apierror.Problem
+// @Failure 401 {object} apierror.Problem
+// @Failure 404 {object} apierror.Problem
+// @Failure 409 {object} apierror.Problem "Request with this idempotency key in progress"
+// @Failure 422 {object} apierror.Problem "Validation failed"
+// @Failure 500 {object} apierror.Problem
+// @Router /household-invitations/accept [post]
+func AcceptHouseholdInvitation(c *gin.Context, db *sql.DB) {
+	userID, ok := middleware.RequireIdentity(c)
+	if !ok {
+		return
+	}
+
+	// 1. Bind the request JSON to the token struct.
+	var acceptReq models.AcceptInvitationRequest
+	if err := c.ShouldBindJSON(&acceptReq); err != nil {
+		c.Error(apierror.FromBinding(err))
+		return
+	}
+
+	// 2. Claim the invitation and add the member in one transaction.
+	tx, err := db.BeginTx(c.Request.Context(), nil)
+	if err != nil {
+		c.Error(apierror.Internal("Error starting transaction").WithCause(err))
+		return
+	}
+	defer tx.Rollback()
+
+	var member models.HouseholdMember
+	sqlQuery := `
+		UPDATE household_invitations
+		SET accepted_by = $1, accepted_at = NOW()
+		WHERE token_hash = $2 AND accepted_at IS NULL AND expires_at > NOW()
+		RETURNING household_id, role`
+	err = tx.QueryRowContext(c.Request.Context(), sqlQuery, userID, auth.HashToken(acceptReq.Token)).Scan(&member.HouseholdID, &member.Role)
+	if err == sql.ErrNoRows {
+		c.Error(apierror.NotFound("Invitation not found or expired"))
+		return
+	}
+	if err != nil {
+		c.Error(apierror.FromDB(err, "Error accepting invitation"))
+		return
+	}
+
+	// Existing members keep their current role.
+	member.UserID = userID
+	sqlQuery = `
+		INSERT INTO household_members (household_id, user_id, role)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (household_id, user_id) DO UPDATE SET role = household_members.role
+		RETURNING role`
+	if err := tx.QueryRowContext(c.Request.Context(), sqlQuery, member.HouseholdID, member.UserID, member.Role).Scan(&member.Role); err != nil {
+		c.Error(apierror.FromDB(err, "Error adding household member"))
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		c.Error(apierror.FromDB(err, "Error committing transaction"))
+		return
+	}
+
+	// 3. Return a JSON response with the membership.
+	c.JSON(http.StatusOK, member)
+}
+
+// authorizeHousehold checks that the caller is a member (or, with ownerOnly set,
+// an owner) of the household in the URL. It returns the household and the
+// caller's role, and false once an error response has been written.
+func authorizeHousehold(c *gin.Context, db *sql.DB, ownerOnly bool) (int, string, bool) {
+	userID, ok := middleware.RequireIdentity(c)
+	if !ok {
+		return 0, "", false
+	}
+
+	householdID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.Error(apierror.BadRequest("Invalid household ID"))
+		return 0, "", false
+	}
+
+	// Households the caller does not belong to are reported as not found.
+	var role string
+	sqlQuery := `SELECT role FROM household_members WHERE household_id = $1 AND user_id = $2`
+	err = db.QueryRowContext(c.Request.Context(), sqlQuery, householdID, userID).Scan(&role)
+	if err == sql.ErrNoRows {
+		c.Error(apierror.NotFound("Household not found"))
+		return 0, "", false
+	}
+	if err != nil {
+		c.Error(apierror.Internal("Error checking household membership").WithCause(err))
+		return 0, "", false
+	}
+
+	if ownerOnly && role != models.HouseholdRoleOwner {
+		c.Error(apierror.Forbidden("Only household owners can perform this action"))
+		return 0, "", false
+	}
+
+	return householdID, role, true
+}
+
+// keepsHouseholdOwner checks that a household still has an owner once userID
+// stops being one. It locks the household's members for the rest of tx, so
+// that concurrent demotions and removals cannot all pass the check. It returns
+// false once an error response has been written.
+func keepsHouseholdOwner(c *gin.Context, tx *sql.Tx, householdID, userID int) bool {
+	sqlQuery := `
+		SELECT COUNT(*) FILTER (WHERE role = $3), COALESCE(BOOL_OR(user_id = $2 AND role = $3), FALSE)
+		FROM (SELECT user_id, role FROM household_members WHERE household_id = $1 FOR UPDATE) members`
+
+	var owners int
+	var isOwner bool
+	if err := tx.QueryRowContext(c.Request.Context(), sqlQuery, householdID, userID, models.HouseholdRoleOwner).Scan(&owners, &isOwner); err != nil {
+		c.Error(apierror.Internal("Error checking household owners").WithCause(err))
+		return false
+	}
+	if isOwner && owners == 1 {
+		c.Error(apierror.Conflict("A household must keep at least one owner"))
+		return false
+	}
+	return true
+}
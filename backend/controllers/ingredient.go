@@ -1,16 +1,26 @@
 package controllers
 
 import (
+	"backend/apierror"
+	"backend/audit"
+	"backend/db"
 	"backend/models" // Import your models package where you have your struct definitions
+	"context"
 	"database/sql"
-	"log"
 	"net/http"
 	"strconv"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	_ "github.com/lib/pq" // Import the PostgreSQL driver
 )
 
+// insertIngredient creates an ingredient, returning its ID and creation time.
+var insertIngredient = db.Register(`
+	INSERT INTO ingredients (ingredient_name, ingredient_description, owner_id, household_id)
+	VALUES ($1, $2, $3, $4)
+	RETURNING ingredient_id, created_at`)
+
 // CreateIngredient creates a new ingredient.
 // CreateIngredient godoc
 // @Summary Create a new ingredient
@@ -19,9 +29,13 @@ import (
 // @Accept json
 // @Produce json
 // @Param ingredient body models.IngredientRequest true "Add ingredient"
+// @Param Idempotency-Key header string false "Unique key making retries of this request replay its first response"
 // @Success 201 {object} models.Ingredient
-// @Failure 400 {object} map[string]interface{}
-// @Failure 500 {object} map[string]interface{}
+// @Failure 400 {object} apierror.Problem
+// @Failure 403 {object} apierror.Problem
+// @Failure 409 {object} apierror.Problem "Request with this idempotency key in progress"
+// @Failure 422 {object} apierror.Problem "Validation failed"
+// @Failure 500 {object} apierror.Problem
 // @Router /ingredients [post]
 func CreateIngredient(c *gin.Context, db *sql.DB) {
 	// 1. Define a variable to hold the ingredient request data.
@@ -29,26 +43,43 @@ func CreateIngredient(c *gin.Context, db *sql.DB) {
 
 	// 2. Bind the request JSON to the ingredient request struct.
 	if err := c.ShouldBindJSON(&ingredientReq); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		c.Error(apierror.FromBinding(err))
 		return
 	}
 
+	// Ingredients created by an identified caller are owned by them and belong to their household.
+	who := callerOf(c)
+	if !requireWriter(c, who) {
+		return
+	}
+	var ownerID *int
+	if who.userID != 0 {
+		ownerID = &who.userID
+	}
+
 	// 3. Perform validation and save the ingredient to the database.
-	sqlQuery := `
-        INSERT INTO ingredients (ingredient_name, ingredient_description)
-        VALUES ($1, $2)
-        RETURNING ingredient_id`
+	// Apply the change and its audit entry in one transaction.
+	tx, err := db.BeginTx(c.Request.Context(), nil)
+	if err != nil {
+		c.Error(apierror.Internal("Error starting transaction").WithCause(err))
+		return
+	}
+	defer tx.Rollback()
 
-	stmt, err := db.Prepare(sqlQuery)
+	stmt, err := insertIngredient.In(c.Request.Context(), tx)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error preparing SQL statement"})
+		c.Error(apierror.Internal("Error preparing SQL statement").WithCause(err))
 		return
 	}
-	defer stmt.Close()
 	var ingredientID int
-	err = stmt.QueryRow(ingredientReq.IngredientName, ingredientReq.IngredientDescription).Scan(&ingredientID)
+	var createdAt time.Time
+	err = stmt.QueryRowContext(c.Request.Context(), ingredientReq.IngredientName, ingredientReq.IngredientDescription, ownerID, who.householdID).Scan(&ingredientID, &createdAt)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error executing SQL statement"})
+		c.Error(apierror.FromDB(err, "Error executing SQL statement"))
+		return
+	}
+
+	if !commitAudited(c, tx, audit.ActionCreate, audit.EntityIngredient, ingredientID, nil) {
 		return
 	}
 
@@ -57,6 +88,11 @@ func CreateIngredient(c *gin.Context, db *sql.DB) {
 		IngredientID:          ingredientID,
 		IngredientName:        ingredientReq.IngredientName,
 		IngredientDescription: ingredientReq.IngredientDescription,
+		OwnerID:               ownerID,
+		HouseholdID:           who.householdID,
+		Version:               1,
+		CreatedAt:             createdAt,
+		UpdatedAt:             createdAt,
 	}
 
 	c.JSON(http.StatusCreated, createdIngredient)
@@ -70,9 +106,11 @@ func CreateIngredient(c *gin.Context, db *sql.DB) {
 // @Accept json
 // @Produce json
 // @Param id path int true "Ingredient ID"
+// @Param If-None-Match header string false "ETag held by the client; unchanged resources return 304"
 // @Success 200 {object} models.Ingredient "Ingredient retrieved"
-// @Failure 400 {object} map[string]interface{} "Invalid ID"
-// @Failure 404 {object} map[string]interface{} "Ingredient not found"
+// @Success 304 "Not Modified"
+// @Failure 400 {object} apierror.Problem "Invalid ID"
+// @Failure 404 {object} apierror.Problem "Ingredient not found"
 // @Router /ingredients/{id} [get]
 func GetIngredient(c *gin.Context, db *sql.DB) {
 	// 1. Extract the ingredient ID from the URL parameter.
@@ -80,64 +118,90 @@ func GetIngredient(c *gin.Context, db *sql.DB) {
 	//If the ingredientID is not a valid integer, return a 400 Bad Request response.
 	ingredientID, err := strconv.Atoi(ingredientIDStr)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ingredient ID"})
+		c.Error(apierror.BadRequest("Invalid ingredient ID"))
+		return
+	}
+	// Answer from the response cache when possible.
+	key, served := serveCached(c, cacheIngredients)
+	if served {
 		return
 	}
 
-	// 2. Query the database for the ingredient.
-	sqlQuery := `SELECT ingredient_id, ingredient_name, ingredient_description FROM ingredients WHERE ingredient_id = $1`
+	// 2. Query the database for the ingredient, from the shared catalog or the caller's household.
+	who := callerOf(c)
+	sqlQuery := `
+		SELECT i.ingredient_id, i.ingredient_name, i.ingredient_description, i.owner_id, i.household_id, i.version, i.created_at, i.updated_at
+		FROM ingredients i
+		WHERE i.ingredient_id = $1 AND ` + ingredientVisible("$2")
 	var ingredient models.Ingredient
-	if err := db.QueryRow(sqlQuery, ingredientID).Scan(&ingredient.IngredientID, &ingredient.IngredientName, &ingredient.IngredientDescription); err != nil {
+	if err := db.QueryRowContext(c.Request.Context(), sqlQuery, ingredientID, who.householdID).Scan(&ingredient.IngredientID, &ingredient.IngredientName, &ingredient.IngredientDescription, &ingredient.OwnerID, &ingredient.HouseholdID, &ingredient.Version, &ingredient.CreatedAt, &ingredient.UpdatedAt); err != nil {
 		if err == sql.ErrNoRows {
-			c.JSON(http.StatusNotFound, gin.H{"error": "Ingredient not found"})
+			c.Error(apierror.NotFound("Ingredient not found"))
 		} else {
-			log.Printf("Error scanning ingredient: %v", err)
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error retrieving ingredient"})
+			c.Error(apierror.Internal("Error retrieving ingredient").WithCause(err))
 		}
 		return
 	}
 
 	// 3. Return a JSON response with the retrieved ingredient.
-	log.Printf("Retrieved ingredient: %#v", ingredient)
-	c.JSON(http.StatusOK, ingredient)
+	respondCached(c, key, etag(ingredient.Version), ingredient)
 }
 
 // GetAllIngredients retrieves all ingredients from the database.
 // GetAllIngredients godoc
 // @Summary Get all ingredients
-// @Description Get all ingredients from the database
+// @Description Get the shared ingredient catalog and the ingredients of the current household
 // @Tags ingredients
 // @Accept json
 // @Produce json
+// @Param updated_since query string false "Only rows changed at or after this RFC 3339 time"
+// @Param sort query string false "Sort by created_at or updated_at; prefix with - for newest first" Enums(created_at, -created_at, updated_at, -updated_at)
+// @Param If-None-Match header string false "ETag held by the client; unchanged resources return 304"
 // @Success 200 {array} models.Ingredient "List of ingredients"
-// @Failure 500 {object} map[string]interface{} "Internal server error"
+// @Success 304 "Not Modified"
+// @Failure 400 {object} apierror.Problem "Invalid filter or sort"
+// @Failure 500 {object} apierror.Problem "Internal server error"
 // @Router /ingredients [get]
 func GetAllIngredients(c *gin.Context, db *sql.DB) {
 	// 1. Define a variable to hold the ingredient data.
 	var ingredient models.Ingredient
 	var ingredients []models.Ingredient
 
-	// 2. Query the database for all ingredients.
-	sqlQuery := `SELECT ingredient_id, ingredient_name, ingredient_description FROM ingredients`
-	rows, err := db.Query(sqlQuery)
+	// 2. Query the database for all ingredients visible to the caller's household.
+	opts, ok := parseListOptions(c, "i", "ingredient_id", "i.ingredient_id")
+	if !ok {
+		return
+	}
+	// Answer from the response cache when possible.
+	key, served := serveCached(c, cacheIngredients)
+	if served {
+		return
+	}
+	who := callerOf(c)
+	sqlQuery := `
+		SELECT i.ingredient_id, i.ingredient_name, i.ingredient_description, i.owner_id, i.household_id, i.version, i.created_at, i.updated_at
+		FROM ingredients i
+		WHERE ` + ingredientVisible("$1") + ` AND ` + updatedSince("i", "$2") + `
+		ORDER BY ` + opts.orderBy
+	rows, err := db.QueryContext(c.Request.Context(), sqlQuery, who.householdID, opts.updatedSince)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error querying the database"})
+		c.Error(apierror.Internal("Error querying the database").WithCause(err))
 		return
 	}
 	defer rows.Close()
 
 	// 3. Iterate over the rows and add each ingredient to the slice.
 	for rows.Next() {
-		err := rows.Scan(&ingredient.IngredientID, &ingredient.IngredientName, &ingredient.IngredientDescription)
+		err := rows.Scan(&ingredient.IngredientID, &ingredient.IngredientName, &ingredient.IngredientDescription, &ingredient.OwnerID, &ingredient.HouseholdID, &ingredient.Version, &ingredient.CreatedAt, &ingredient.UpdatedAt)
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error scanning rows"})
+			c.Error(apierror.Internal("Error scanning rows").WithCause(err))
 			return
 		}
 		ingredients = append(ingredients, ingredient)
 	}
 
 	// 4. Return a JSON response with the ingredients.
-	c.JSON(http.StatusOK, ingredients)
+	respondCachedList(c, key, ingredients)
 }
 
 // UpdateIngredient updates an existing ingredient by ID.
@@ -148,52 +212,160 @@ func GetAllIngredients(c *gin.Context, db *sql.DB) {
 // @Accept json
 // @Produce json
 // @Param id path int true "Ingredient ID"
-// @Param ingredient body models.Ingredient true "Ingredient content"
+// @Param ingredient body models.IngredientRequest true "Ingredient content"
+// @Param If-Match header string false "ETag of the version being changed; the request fails with 412 if it is outdated"
 // @Success 204 "Ingredient updated"
-// @Failure 400 {object} map[string]interface{} "Invalid input"
-// @Failure 500 {object} map[string]interface{} "Internal server error"
+// @Failure 400 {object} apierror.Problem "Invalid input"
+// @Failure 403 {object} apierror.Problem "Household viewers cannot modify ingredients"
+// @Failure 404 {object} apierror.Problem "Ingredient not found"
+// @Failure 422 {object} apierror.Problem "Validation failed"
+// @Failure 412 {object} apierror.Problem "Resource was modified"
+// @Failure 500 {object} apierror.Problem "Internal server error"
 // @Router /ingredients/{id} [put]
 func UpdateIngredient(c *gin.Context, db *sql.DB) {
 	// 1. Extract the ingredient ID from the URL parameter.
 	ingredientIDStr := c.Param("id")
 	ingredientID, err := strconv.Atoi(ingredientIDStr)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ingredient ID"})
+		c.Error(apierror.BadRequest("Invalid ingredient ID"))
+		return
+	}
+
+	// Only ingredients of the caller's own tenant can be changed.
+	who := callerOf(c)
+	if !requireWriter(c, who) {
 		return
 	}
 
 	// 2. Define a variable to hold the ingredient data.
-	var ingredient models.Ingredient
+	var ingredient models.IngredientRequest
+
+	// 3. Bind the request JSON to the ingredient struct; patches apply to the stored ingredient.
+	if !bindUpdate(c, &ingredient, func() error { return loadIngredientRequest(c.Request.Context(), db, ingredientID, who, &ingredient) }) {
+		return
+	}
 
-	// 3. Bind the request JSON to the ingredient struct.
-	if err := c.ShouldBindJSON(&ingredient); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	// 4. Update the ingredient and record it in the audit log in one transaction.
+	tx, err := db.BeginTx(c.Request.Context(), nil)
+	if err != nil {
+		c.Error(apierror.Internal("Error starting transaction").WithCause(err))
+		return
+	}
+	defer tx.Rollback()
+	if !lockTenantIngredient(c, tx, ingredientID, who) {
+		return
+	}
+	before, ok := snapshotForAudit(c, tx, audit.EntityIngredient, ingredientID)
+	if !ok {
+		return
+	}
+	if !checkIfMatch(c, tx, "ingredients", "ingredient_id", ingredientID) {
 		return
 	}
 
-	// 4. Update the ingredient in the database.
-	sqlQuery := `UPDATE ingredients SET ingredient_name = $1, ingredient_description = $2 WHERE ingredient_id = $3`
-	_, err = db.Exec(sqlQuery, ingredient.IngredientName, ingredient.IngredientDescription, ingredientID)
+	sqlQuery := `
+		UPDATE ingredients i SET ingredient_name = $1, ingredient_description = $2
+		WHERE i.ingredient_id = $3 AND i.deleted_at IS NULL AND ` + inTenant("i", "$4") + `
+		RETURNING i.ingredient_id, i.ingredient_name, COALESCE(i.ingredient_description, ''), i.owner_id, i.household_id, i.version, i.created_at, i.updated_at`
+	var updated models.Ingredient
+	err = tx.QueryRowContext(c.Request.Context(), sqlQuery, ingredient.IngredientName, ingredient.IngredientDescription, ingredientID, who.householdID).
+		Scan(&updated.IngredientID, &updated.IngredientName, &updated.IngredientDescription, &updated.OwnerID, &updated.HouseholdID, &updated.Version, &updated.CreatedAt, &updated.UpdatedAt)
+	if err == sql.ErrNoRows {
+		c.Error(apierror.NotFound("Ingredient not found"))
+		return
+	}
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error updating ingredient"})
+		c.Error(apierror.FromDB(err, "Error updating ingredient"))
+		return
+	}
+
+	if !commitAudited(c, tx, audit.ActionUpdate, audit.EntityIngredient, ingredientID, before) {
 		return
 	}
 
-	// 5. Return a 204 No Content response.
+	// 5. Return a 204 No Content response, or the updated ingredient for patches.
+	c.Header("ETag", etag(updated.Version))
+	if c.Request.Method == http.MethodPatch {
+		c.JSON(http.StatusOK, updated)
+		return
+	}
 	c.Status(http.StatusNoContent)
 }
 
-// DeleteIngredient deletes an existing ingredient by ID.
+// PatchIngredient partially updates an ingredient by ID.
+// PatchIngredient godoc
+// @Summary Partially update an ingredient
+// @Description Apply a JSON Merge Patch (RFC 7396) or JSON Patch (RFC 6902) to an ingredient; only the supplied fields change
+// @Tags ingredients
+// @Accept application/merge-patch+json,application/json-patch+json
+// @Produce json
+// @Param id path int true "Ingredient ID"
+// @Param patch body models.IngredientRequest true "Fields to change"
+// @Param If-Match header string false "ETag of the version being changed; the request fails with 412 if it is outdated"
+// @Success 200 {object} models.Ingredient
+// @Failure 400 {object} apierror.Problem "Invalid ingredient ID or patch document"
+// @Failure 403 {object} apierror.Problem "Household viewers cannot modify ingredients"
+// @Failure 404 {object} apierror.Problem "Ingredient not found"
+// @Failure 409 {object} apierror.Problem "JSON Patch test failed"
+// @Failure 415 {object} apierror.Problem "Unsupported patch format"
+// @Failure 422 {object} apierror.Problem "Validation failed"
+// @Failure 412 {object} apierror.Problem "Resource was modified"
+// @Failure 500 {object} apierror.Problem "Internal server error"
+// @Router /ingredients/{id} [patch]
+func PatchIngredient(c *gin.Context, db *sql.DB) {
+	UpdateIngredient(c, db)
+}
+
+// loadIngredientRequest fills req with the stored values of an ingredient of
+// the caller's tenant.
+func loadIngredientRequest(ctx context.Context, db *sql.DB, ingredientID int, who caller, req *models.IngredientRequest) error {
+	sqlQuery := `
+		SELECT i.ingredient_name, COALESCE(i.ingredient_description, '')
+		FROM ingredients i
+		WHERE i.ingredient_id = $1 AND i.deleted_at IS NULL AND ` + inTenant("i", "$2")
+	err := db.QueryRowContext(ctx, sqlQuery, ingredientID, who.householdID).Scan(&req.IngredientName, &req.IngredientDescription)
+	if err == sql.ErrNoRows {
+		return apierror.NotFound("Ingredient not found")
+	}
+	return err
+}
+
+// lockTenantIngredient locks a live ingredient of the caller's tenant for the
+// rest of tx, before anything else reads or locks it, so that other tenants'
+// ingredients are reported as not found without revealing anything about
+// them. It writes the error response and returns false when the handler
+// should stop.
+func lockTenantIngredient(c *gin.Context, tx *sql.Tx, ingredientID int, who caller) bool {
+	sqlQuery := `SELECT 1 FROM ingredients i WHERE i.ingredient_id = $1 AND i.deleted_at IS NULL AND ` + inTenant("i", "$2") + ` FOR UPDATE`
+	var found int
+	err := tx.QueryRowContext(c.Request.Context(), sqlQuery, ingredientID, who.householdID).Scan(&found)
+	if err == sql.ErrNoRows {
+		c.Error(apierror.NotFound("Ingredient not found"))
+		return false
+	}
+	if err != nil {
+		c.Error(apierror.FromDB(err, "Error fetching ingredient"))
+		return false
+	}
+	return true
+}
+
+// DeleteIngredient moves an existing ingredient to the trash by ID.
 // DeleteIngredient godoc
 // @Summary Delete an ingredient
-// @Description Remove an ingredient by ID from the database
+// @Description Move an ingredient to the trash by ID; it can be restored until it is purged. Unless deletes cascade, ingredients used by recipes cannot be deleted
 // @Tags ingredients
 // @Accept json
 // @Produce json
 // @Param id path int true "Ingredient ID"
+// @Param If-Match header string false "ETag of the version being changed; the request fails with 412 if it is outdated"
 // @Success 204 "Ingredient deleted"
-// @Failure 400 {object} map[string]interface{} "Invalid ID"
-// @Failure 500 {object} map[string]interface{} "Internal server error"
+// @Failure 400 {object} apierror.Problem "Invalid ID"
+// @Failure 403 {object} apierror.Problem "Household viewers cannot modify ingredients"
+// @Failure 404 {object} apierror.Problem "Ingredient not found"
+// @Failure 409 {object} apierror.Problem "Ingredient is still used by recipes"
+// @Failure 412 {object} apierror.Problem "Resource was modified"
+// @Failure 500 {object} apierror.Problem "Internal server error"
 // @Router /ingredients/{id} [delete]
 func DeleteIngredient(c *gin.Context, db *sql.DB) {
 
@@ -201,15 +373,55 @@ func DeleteIngredient(c *gin.Context, db *sql.DB) {
 	ingredientIDStr := c.Param("id")
 	ingredientID, err := strconv.Atoi(ingredientIDStr)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ingredient ID"})
+		c.Error(apierror.BadRequest("Invalid ingredient ID"))
 		return
 	}
 
-	// 2. Delete the ingredient from the database.
-	sqlQuery := `DELETE FROM ingredients WHERE ingredient_id = $1`
-	_, err = db.Exec(sqlQuery, ingredientID)
+	// Only ingredients of the caller's own tenant can be deleted.
+	who := callerOf(c)
+	if !requireWriter(c, who) {
+		return
+	}
+
+	// 2. Move the ingredient to the trash and record it in the audit log in one transaction.
+	tx, err := db.BeginTx(c.Request.Context(), nil)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error deleting ingredient"})
+		c.Error(apierror.Internal("Error starting transaction").WithCause(err))
+		return
+	}
+	defer tx.Rollback()
+	if !lockTenantIngredient(c, tx, ingredientID, who) {
+		return
+	}
+	before, ok := snapshotForAudit(c, tx, audit.EntityIngredient, ingredientID)
+	if !ok {
+		return
+	}
+	if !checkIfMatch(c, tx, "ingredients", "ingredient_id", ingredientID) {
+		return
+	}
+
+	// When deletes cascade, recipes lose the ingredient until it is restored.
+	if IngredientDeleteMode == DeleteCascade {
+		if !trashDependents(c, tx, audit.EntityRecipeIngredient, "recipe_ingredients", "recipe_ingredient_id", "ingredient_id", ingredientID) {
+			return
+		}
+	} else if !refuseIfUsed(c, tx, "ingredient", "recipe_ingredients", "ingredient_id", ingredientID) {
+		return
+	}
+
+	sqlQuery := `UPDATE ingredients i SET deleted_at = NOW() WHERE i.ingredient_id = $1 AND i.deleted_at IS NULL AND ` + inTenant("i", "$2")
+	result, err := tx.ExecContext(c.Request.Context(), sqlQuery, ingredientID, who.householdID)
+	if err != nil {
+		c.Error(apierror.FromDB(err, "Error deleting ingredient"))
+		return
+	}
+	if rows, err := result.RowsAffected(); err == nil && rows == 0 {
+		c.Error(apierror.NotFound("Ingredient not found"))
+		return
+	}
+
+	if !commitAudited(c, tx, audit.ActionDelete, audit.EntityIngredient, ingredientID, before) {
 		return
 	}
 
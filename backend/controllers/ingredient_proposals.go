@@ -0,0 +1,251 @@
+package controllers
+
+import (
+	"backend/apierror"
+	"backend/audit"
+	"backend/middleware"
+	"backend/models"
+	"backend/policy"
+	"database/sql"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CreateIngredientProposal proposes an addition to the shared ingredient catalog.
+// CreateIngredientProposal godoc
+// @Summary Propose a new catalog ingredient
+// @Description Suggest an ingredient for the shared catalog; an admin reviews it
+// @Tags ingredient_proposals
+// @Accept json
+// @Produce json
+// @Param proposal body models.IngredientProposalRequest true "Add proposal"
+// @Param Idempotency-Key header string false "Unique key making retries of this request replay its first response"
+// @Success 201 {object} models.IngredientProposal
+// @Failure 400 {object} apierror.Problem
+// @Failure 401 {object} apierror.Problem
+// @Failure 403 {object} apierror.Problem
+// @Failure 409 {object} apierror.Problem "Request with this idempotency key in progress"
+// @Failure 422 {object} apierror.Problem "Validation failed"
+// @Failure 500 {object} apierror.Problem
+// @Router /ingredient-proposals [post]
+func CreateIngredientProposal(c *gin.Context, db *sql.DB) {
+	userID, ok := middleware.RequireIdentity(c)
+	if !ok {
+		return
+	}
+
+	// 1. Bind the request JSON to the proposal struct.
+	var proposalReq models.IngredientProposalRequest
+	if err := c.ShouldBindJSON(&proposalReq); err != nil {
+		c.Error(apierror.FromBinding(err))
+		return
+	}
+
+	// 2. Save the proposal to the database.
+	proposal := models.IngredientProposal{
+		IngredientName:        proposalReq.IngredientName,
+		IngredientDescription: proposalReq.IngredientDescription,
+		ProposedBy:            userID,
+		Status:                models.ProposalPending,
+	}
+	sqlQuery := `
+		INSERT INTO ingredient_proposals (ingredient_name, ingredient_description, proposed_by, status)
+		VALUES ($1, $2, $3, $4)
+		RETURNING proposal_id, created_at`
+	err := db.QueryRowContext(c.Request.Context(), sqlQuery, proposal.IngredientName, proposal.IngredientDescription, proposal.ProposedBy, proposal.Status).Scan(&proposal.ProposalID, &proposal.CreatedAt)
+	if err != nil {
+		c.Error(apierror.FromDB(err, "Error creating proposal"))
+		return
+	}
+
+	// 3. Return a JSON response with the created proposal.
+	c.JSON(http.StatusCreated, proposal)
+}
+
+// GetIngredientProposals lists ingredient proposals.
+// GetIngredientProposals godoc
+// @Summary Get ingredient proposals
+// @Description Reviewers see every proposal; other users see their own
+// @Tags ingredient_proposals
+// @Accept json
+// @Produce json
+// @Param status query string false "Filter by status (pending, approved, rejected)"
+// @Success 200 {array} models.IngredientProposal
+// @Failure 401 {object} apierror.Problem
+// @Failure 403 {object} apierror.Problem
+// @Failure 500 {object} apierror.Problem
+// @Router /ingredient-proposals [get]
+func GetIngredientProposals(c *gin.Context, db *sql.DB) {
+	userID, ok := middleware.RequireIdentity(c)
+	if !ok {
+		return
+	}
+
+	// 1. Reviewers see all proposals; everyone else only their own.
+	proposer := &userID
+	if middleware.Can(c, policy.ResourceIngredientProposals, policy.ActionReview) {
+		proposer = nil
+	}
+	var status *string
+	if s := c.Query("status"); s != "" {
+		status = &s
+	}
+
+	// 2. Query the database for the proposals.
+	sqlQuery := `
+		SELECT proposal_id, ingredient_name, ingredient_description, proposed_by, status,
+			reviewed_by, ingredient_id, created_at, reviewed_at
+		FROM ingredient_proposals
+		WHERE ($1::int IS NULL OR proposed_by = $1) AND ($2::text IS NULL OR status = $2)
+		ORDER BY proposal_id`
+	rows, err := db.QueryContext(c.Request.Context(), sqlQuery, proposer, status)
+	if err != nil {
+		c.Error(apierror.Internal("Error querying the database").WithCause(err))
+		return
+	}
+	defer rows.Close()
+
+	// 3. Iterate over the rows and add each proposal to the slice.
+	proposals := []models.IngredientProposal{}
+	for rows.Next() {
+		var p models.IngredientProposal
+		err := rows.Scan(&p.ProposalID, &p.IngredientName, &p.IngredientDescription, &p.ProposedBy, &p.Status,
+			&p.ReviewedBy, &p.IngredientID, &p.CreatedAt, &p.ReviewedAt)
+		if err != nil {
+			c.Error(apierror.Internal("Error scanning rows").WithCause(err))
+			return
+		}
+		proposals = append(proposals, p)
+	}
+
+	// 4. Return a JSON response with the proposals.
+	c.JSON(http.StatusOK, proposals)
+}
+
+// ApproveIngredientProposal adds a proposed ingredient to the shared catalog.
+// ApproveIngredientProposal godoc
+// @Summary Approve an ingredient proposal
+// @Description Add the proposed ingredient to the shared catalog
+// @Tags ingredient_proposals
+// @Accept json
+// @Produce json
+// @Param id path int true "Proposal ID"
+// @Param Idempotency-Key header string false "Unique key making retries of this request replay its first response"
+// @Success 200 {object} models.IngredientProposal
+// @Failure 400 {object} apierror.Problem
+// @Failure 403 {object} apierror.Problem
+// @Failure 404 {object} apierror.Problem
+// @Failure 409 {object} apierror.Problem
+// @Failure 422 {object} apierror.Problem "Idempotency key reused for a different request"
+// @Failure 500 {object} apierror.Problem
+// @Router /ingredient-proposals/{id}/approve [post]
+func ApproveIngredientProposal(c *gin.Context, db *sql.DB) {
+	reviewIngredientProposal(c, db, models.ProposalApproved)
+}
+
+// RejectIngredientProposal declines a proposed ingredient.
+// RejectIngredientProposal godoc
+// @Summary Reject an ingredient proposal
+// @Description Decline a proposed catalog ingredient
+// @Tags ingredient_proposals
+// @Accept json
+// @Produce json
+// @Param id path int true "Proposal ID"
+// @Param Idempotency-Key header string false "Unique key making retries of this request replay its first response"
+// @Success 200 {object} models.IngredientProposal
+// @Failure 400 {object} apierror.Problem
+// @Failure 403 {object} apierror.Problem
+// @Failure 404 {object} apierror.Problem
+// @Failure 409 {object} apierror.Problem
+// @Failure 422 {object} apierror.Problem "Idempotency key reused for a different request"
+// @Failure 500 {object} apierror.Problem
+// @Router /ingredient-proposals/{id}/reject [post]
+func RejectIngredientProposal(c *gin.Context, db *sql.DB) {
+	reviewIngredientProposal(c, db, models.ProposalRejected)
+}
+
+// reviewIngredientProposal settles a pending proposal with the given status.
+// Approved proposals become catalog ingredients owned by their proposer.
+func reviewIngredientProposal(c *gin.Context, db *sql.DB, status string) {
+	reviewerID, ok := middleware.RequireIdentity(c)
+	if !ok {
+		return
+	}
+
+	// 1. Extract the proposal ID from the URL parameter.
+	proposalID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.Error(apierror.BadRequest("Invalid proposal ID"))
+		return
+	}
+
+	// 2. Lock the proposal and check it is still pending.
+	tx, err := db.BeginTx(c.Request.Context(), nil)
+	if err != nil {
+		c.Error(apierror.Internal("Error starting transaction").WithCause(err))
+		return
+	}
+	defer tx.Rollback()
+
+	var p models.IngredientProposal
+	sqlQuery := `
+		SELECT proposal_id, ingredient_name, ingredient_description, proposed_by, status, created_at
+		FROM ingredient_proposals
+		WHERE proposal_id = $1
+		FOR UPDATE`
+	err = tx.QueryRowContext(c.Request.Context(), sqlQuery, proposalID).Scan(&p.ProposalID, &p.IngredientName, &p.IngredientDescription, &p.ProposedBy, &p.Status, &p.CreatedAt)
+	if err == sql.ErrNoRows {
+		c.Error(apierror.NotFound("Proposal not found"))
+		return
+	}
+	if err != nil {
+		c.Error(apierror.Internal("Error fetching proposal").WithCause(err))
+		return
+	}
+	if p.Status != models.ProposalPending {
+		c.Error(apierror.Conflict("Proposal has already been " + p.Status))
+		return
+	}
+
+	// 3. Add approved ingredients to the shared catalog.
+	if status == models.ProposalApproved {
+		var ingredientID int
+		sqlQuery = `
+			INSERT INTO ingredients (ingredient_name, ingredient_description, owner_id)
+			VALUES ($1, $2, $3)
+			RETURNING ingredient_id`
+		if err := tx.QueryRowContext(c.Request.Context(), sqlQuery, p.IngredientName, p.IngredientDescription, p.ProposedBy).Scan(&ingredientID); err != nil {
+			c.Error(apierror.FromDB(err, "Error creating ingredient"))
+			return
+		}
+		p.IngredientID = &ingredientID
+	}
+
+	// 4. Record the review.
+	sqlQuery = `
+		UPDATE ingredient_proposals
+		SET status = $1, reviewed_by = $2, ingredient_id = $3, reviewed_at = NOW()
+		WHERE proposal_id = $4
+		RETURNING reviewed_at`
+	if err := tx.QueryRowContext(c.Request.Context(), sqlQuery, status, reviewerID, p.IngredientID, proposalID).Scan(&p.ReviewedAt); err != nil {
+		c.Error(apierror.FromDB(err, "Error updating proposal"))
+		return
+	}
+
+	// Approvals are audited as the creation of a catalog ingredient.
+	if p.IngredientID != nil {
+		if !commitAudited(c, tx, audit.ActionCreate, audit.EntityIngredient, *p.IngredientID, nil) {
+			return
+		}
+	} else if err := tx.Commit(); err != nil {
+		c.Error(apierror.FromDB(err, "Error committing transaction"))
+		return
+	}
+
+	// 5. Return a JSON response with the reviewed proposal.
+	p.Status = status
+	p.ReviewedBy = &reviewerID
+	c.JSON(http.StatusOK, p)
+}
@@ -0,0 +1,59 @@
+package controllers
+
+import (
+	"backend/apierror"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// listSorts maps the values of the sort query parameter to ORDER BY columns.
+// A leading "-" sorts newest first.
+var listSorts = map[string]string{
+	"created_at":  "created_at ASC",
+	"-created_at": "created_at DESC",
+	"updated_at":  "updated_at ASC",
+	"-updated_at": "updated_at DESC",
+}
+
+// listOptions are the filters and ordering shared by list endpoints.
+type listOptions struct {
+	// updatedSince limits the list to rows changed at or after it; nil lists every row.
+	updatedSince *time.Time
+	// orderBy is the ORDER BY clause of the list query.
+	orderBy string
+}
+
+// parseListOptions reads the updated_since and sort query parameters of a list
+// endpoint whose main table is aliased as alias. Without a sort, rows are
+// listed in defaultOrder. It writes the error response and returns false when
+// the handler should stop.
+func parseListOptions(c *gin.Context, alias, idColumn, defaultOrder string) (listOptions, bool) {
+	opts := listOptions{orderBy: defaultOrder}
+
+	if value := c.Query("updated_since"); value != "" {
+		t, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			c.Error(apierror.BadRequest("Invalid updated_since, expected RFC 3339 time"))
+			return opts, false
+		}
+		opts.updatedSince = &t
+	}
+
+	if value := c.Query("sort"); value != "" {
+		order, ok := listSorts[value]
+		if !ok {
+			c.Error(apierror.BadRequest("Invalid sort, expected created_at, -created_at, updated_at or -updated_at"))
+			return opts, false
+		}
+		// Break ties by ID so pages of equal timestamps are stable.
+		opts.orderBy = alias + "." + order + ", " + alias + "." + idColumn
+	}
+	return opts, true
+}
+
+// updatedSince returns a condition on the updated_at column of alias bound to
+// placeholder, which holds listOptions.updatedSince.
+func updatedSince(alias, placeholder string) string {
+	return "(" + placeholder + "::timestamptz IS NULL OR " + alias + ".updated_at >= " + placeholder + ")"
+}
@@ -0,0 +1,59 @@
+package controllers
+
+import (
+	"backend/apierror"
+	"backend/logging"
+	"backend/middleware"
+	"backend/models"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetLogLevel returns the minimum level logged.
+// GetLogLevel godoc
+// @Summary Get the log level
+// @Description Get the minimum level of the messages the service logs
+// @Tags admin
+// @Produce json
+// @Success 200 {object} models.LogLevel
+// @Failure 401 {object} apierror.Problem
+// @Failure 403 {object} apierror.Problem
+// @Router /admin/log-level [get]
+func GetLogLevel(c *gin.Context) {
+	c.JSON(http.StatusOK, models.LogLevel{Level: logging.LevelName()})
+}
+
+// UpdateLogLevel changes the minimum level logged until the service restarts.
+// UpdateLogLevel godoc
+// @Summary Change the log level
+// @Description Change the minimum level of the messages the service logs, until it restarts
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Param level body models.LogLevel true "Log level"
+// @Success 200 {object} models.LogLevel
+// @Failure 400 {object} apierror.Problem
+// @Failure 401 {object} apierror.Problem
+// @Failure 403 {object} apierror.Problem
+// @Failure 422 {object} apierror.Problem "Validation failed"
+// @Router /admin/log-level [put]
+func UpdateLogLevel(c *gin.Context) {
+	// 1. Bind the request JSON to the level struct.
+	var levelReq models.LogLevel
+	if err := c.ShouldBindJSON(&levelReq); err != nil {
+		c.Error(apierror.FromBinding(err))
+		return
+	}
+
+	// 2. Apply the level, recording who changed it.
+	previous := logging.LevelName()
+	if err := logging.SetLevel(levelReq.Level); err != nil {
+		c.Error(apierror.Validation("Invalid log level", apierror.FieldError{Field: "level", Code: "oneof", Message: err.Error()}))
+		return
+	}
+	middleware.Logger(c).Warn("log level changed", "from", previous, "to", levelReq.Level)
+
+	// 3. Return a JSON response with the new level.
+	c.JSON(http.StatusOK, models.LogLevel{Level: logging.LevelName()})
+}
@@ -0,0 +1,104 @@
+package controllers
+
+import (
+	"backend/apierror"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"reflect"
+
+	jsonpatch "github.com/evanphx/json-patch/v5"
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+)
+
+// Media types of patch documents.
+const (
+	MergePatchContentType = "application/merge-patch+json" // RFC 7396
+	JSONPatchContentType  = "application/json-patch+json"  // RFC 6902
+)
+
+// bindUpdate binds the body of an update request into req, which must point
+// to a request model. PUT requests replace the resource. PATCH requests are
+// applied to the stored values, which load fills into req, and the result is
+// validated like a replacement. It writes the error response and returns
+// false when the handler should stop.
+func bindUpdate(c *gin.Context, req interface{}, load func() error) bool {
+	if c.Request.Method != http.MethodPatch {
+		if err := c.ShouldBindJSON(req); err != nil {
+			c.Error(apierror.FromBinding(err))
+			return false
+		}
+		return true
+	}
+
+	// 1. Load the stored values as the document to patch.
+	if err := load(); err != nil {
+		var apiErr *apierror.Error
+		if !errors.As(err, &apiErr) {
+			apiErr = apierror.Internal("Error loading current values").WithCause(err)
+		}
+		c.Error(apiErr)
+		return false
+	}
+	current, err := json.Marshal(req)
+	if err != nil {
+		c.Error(apierror.Internal("Error encoding current values").WithCause(err))
+		return false
+	}
+
+	// 2. Apply the patch. Plain JSON bodies are treated as merge patches.
+	body, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		c.Error(apierror.BadRequest("Error reading request body").WithCause(err))
+		return false
+	}
+	patched, apiErr := applyPatch(c.ContentType(), current, body)
+	if apiErr != nil {
+		c.Error(apiErr)
+		return false
+	}
+
+	// 3. Bind and validate the patched document from scratch, so removed
+	// members end up empty rather than keeping their stored values.
+	target := reflect.ValueOf(req).Elem()
+	target.Set(reflect.Zero(target.Type()))
+	if err := json.Unmarshal(patched, req); err != nil {
+		c.Error(apierror.FromBinding(err))
+		return false
+	}
+	if err := binding.Validator.ValidateStruct(req); err != nil {
+		c.Error(apierror.FromBinding(err))
+		return false
+	}
+	return true
+}
+
+// applyPatch applies a patch document of the given media type to doc.
+func applyPatch(contentType string, doc, patch []byte) ([]byte, *apierror.Error) {
+	switch contentType {
+	case MergePatchContentType, binding.MIMEJSON:
+		patched, err := jsonpatch.MergePatch(doc, patch)
+		if err != nil {
+			return nil, apierror.BadRequest("Invalid merge patch document").WithCause(err)
+		}
+		return patched, nil
+
+	case JSONPatchContentType:
+		operations, err := jsonpatch.DecodePatch(patch)
+		if err != nil {
+			return nil, apierror.BadRequest("Invalid JSON Patch document").WithCause(err)
+		}
+		patched, err := operations.Apply(doc)
+		if errors.Is(err, jsonpatch.ErrTestFailed) {
+			return nil, apierror.Conflict("A JSON Patch test operation failed").WithCause(err)
+		}
+		if err != nil {
+			return nil, apierror.Validation("The JSON Patch document cannot be applied to the resource").WithCause(err)
+		}
+		return patched, nil
+	}
+
+	return nil, apierror.UnsupportedMediaType("Patches must be sent as " + MergePatchContentType + " or " + JSONPatchContentType)
+}
@@ -1,15 +1,27 @@
 package controllers
 
 import (
+	"backend/apierror"
+	"backend/audit"
+	"backend/db"
+	"backend/middleware"
 	"backend/models" // Import your models package where you have your struct definitions
+	"context"
 	"database/sql"
 	"net/http"
 	"strconv"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	_ "github.com/lib/pq" // Import the PostgreSQL driver
 )
 
+// insertRecipe creates a recipe, returning its ID and creation time.
+var insertRecipe = db.Register(`
+	INSERT INTO recipes (recipe_name, recipe_description, cook_time, owner_id, household_id, visibility)
+	VALUES ($1, $2, $3, $4, $5, $6)
+	RETURNING recipe_id, created_at`)
+
 // CreateRecipe creates a new recipe.
 // CreateRecipe godoc
 // @Summary Create a new recipe
@@ -18,62 +30,102 @@ import (
 // @Accept json
 // @Produce json
 // @Param recipe body models.RecipeRequest true "Add recipe"
+// @Param Idempotency-Key header string false "Unique key making retries of this request replay its first response"
 // @Success 201 {object} models.Recipe
-// @Failure 400 {object} map[string]interface{}
-// @Failure 500 {object} map[string]interface{}
+// @Failure 400 {object} apierror.Problem
+// @Failure 401 {object} apierror.Problem
+// @Failure 409 {object} apierror.Problem "Request with this idempotency key in progress"
+// @Failure 422 {object} apierror.Problem "Validation failed"
+// @Failure 500 {object} apierror.Problem
 // @Router /recipes [post]
 func CreateRecipe(c *gin.Context, db *sql.DB) {
-	// 1. Define a variable to hold the recipe data.
+	// 1. The caller becomes the owner of the recipe, within its household if any.
+	ownerID, ok := middleware.RequireIdentity(c)
+	if !ok {
+		return
+	}
+	who := callerOf(c)
+	if !requireWriter(c, who) {
+		return
+	}
+
+	// 2. Define a variable to hold the recipe data.
 	var recipe models.RecipeRequest
 
-	// 2. Bind the request JSON to the recipe struct.
+	// 3. Bind the request JSON to the recipe struct.
 	if err := c.ShouldBindJSON(&recipe); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		c.Error(apierror.FromBinding(err))
 		return
 	}
 
-	// 3. Perform validation and save the recipe to the database.
-	sqlQuery := `
-		INSERT INTO recipes (recipe_name, recipe_description, cook_time)
-		VALUES ($1, $2, $3)
-		RETURNING recipe_id`
+	// New recipes are private unless stated otherwise.
+	if recipe.Visibility == "" {
+		recipe.Visibility = models.VisibilityPrivate
+	}
+
+	// 4. Perform validation and save the recipe to the database.
+	// Apply the change and its audit entry in one transaction.
+	tx, err := db.BeginTx(c.Request.Context(), nil)
+	if err != nil {
+		c.Error(apierror.Internal("Error starting transaction").WithCause(err))
+		return
+	}
+	defer tx.Rollback()
 
-	stmt, err := db.Prepare(sqlQuery)
+	stmt, err := insertRecipe.In(c.Request.Context(), tx)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error preparing SQL statement"})
+		c.Error(apierror.Internal("Error preparing SQL statement").WithCause(err))
 		return
 	}
-	defer stmt.Close()
 	var recipeID int
-	err = stmt.QueryRow(recipe.RecipeName, recipe.RecipeDescription, recipe.CookTime).Scan(&recipeID)
+	var createdAt time.Time
+	err = stmt.QueryRowContext(c.Request.Context(), recipe.RecipeName, recipe.RecipeDescription, recipe.CookTime, ownerID, who.householdID, recipe.Visibility).Scan(&recipeID, &createdAt)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error executing SQL statement"})
+		c.Error(apierror.FromDB(err, "Error executing SQL statement"))
 		return
 	}
 
-	// 4. Return a JSON response with the created recipe.
+	if !commitAudited(c, tx, audit.ActionCreate, audit.EntityRecipe, recipeID, nil) {
+		return
+	}
+
+	// 5. Return a JSON response with the created recipe.
 	createdRecipe := models.Recipe{
 		RecipeID:          recipeID,
 		RecipeName:        recipe.RecipeName,
 		RecipeDescription: recipe.RecipeDescription,
 		CookTime:          recipe.CookTime,
+		OwnerID:           &ownerID,
+		HouseholdID:       who.householdID,
+		Visibility:        recipe.Visibility,
+		Version:           1,
+		CreatedAt:         createdAt,
+		UpdatedAt:         createdAt,
 	}
 
 	c.JSON(http.StatusCreated, createdRecipe)
 }
 
+// selectRecipe fetches a recipe by ID if the caller, bound to $2 and $3, may see it.
+var selectRecipe = db.Register(`
+	SELECT r.recipe_id, r.recipe_name, r.recipe_description, r.cook_time, r.owner_id, r.household_id, r.visibility, r.version, r.created_at, r.updated_at, r.forked_from
+	FROM recipes r
+	WHERE r.recipe_id = $1 AND ` + recipeViewable("$2", "$3"))
+
 // GetRecipe retrieves a single recipe by ID.
 // GetRecipe godoc
 // @Summary Get a recipe by ID
-// @Description Get a single recipe from the database by ID
+// @Description Get a single recipe from the database by ID, with the recipe it was forked from and its variations
 // @Tags recipes
 // @Accept json
 // @Produce json
 // @Param id path int true "Recipe ID"
+// @Param If-None-Match header string false "ETag held by the client; unchanged resources return 304"
 // @Success 200 {object} models.Recipe
-// @Failure 400 {object} map[string]interface{}
-// @Failure 404 {object} map[string]interface{}
-// @Failure 500 {object} map[string]interface{}
+// @Success 304 "Not Modified"
+// @Failure 400 {object} apierror.Problem
+// @Failure 404 {object} apierror.Problem
+// @Failure 500 {object} apierror.Problem
 // @Router /recipes/{id} [get]
 func GetRecipe(c *gin.Context, db *sql.DB) {
 	// 1. Extract the recipe ID from the URL parameter.
@@ -82,30 +134,43 @@ func GetRecipe(c *gin.Context, db *sql.DB) {
 	recipeID, err := strconv.Atoi(recipeIDStr)
 
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Recipe ID must be a valid integer"})
+		c.Error(apierror.BadRequest("Recipe ID must be a valid integer"))
 		return
 	}
-	// 2. Fetch the recipe from the database by ID.
-	sqlQuery :=
-		`SELECT recipe_id, recipe_name, recipe_description, cook_time
-		FROM recipes
-		WHERE recipe_id = $1`
-
+	// Answer from the response cache when possible.
+	key, served := serveCached(c, cacheRecipes)
+	if served {
+		return
+	}
+	// 2. Fetch the recipe from the database by ID, if the caller may see it.
+	who := callerOf(c)
 	var recipe models.Recipe
-	err = db.QueryRow(sqlQuery, recipeID).Scan(&recipe.RecipeID, &recipe.RecipeName, &recipe.RecipeDescription, &recipe.CookTime)
+	err = selectRecipe.QueryRow(c.Request.Context(), db, recipeID, who.userID, who.householdID).Scan(&recipe.RecipeID, &recipe.RecipeName, &recipe.RecipeDescription, &recipe.CookTime, &recipe.OwnerID, &recipe.HouseholdID, &recipe.Visibility, &recipe.Version, &recipe.CreatedAt, &recipe.UpdatedAt, &recipe.ForkedFrom)
 	if err != nil {
 		if err == sql.ErrNoRows { //If no recipe found, 404 Not Found response.
-			c.JSON(http.StatusNotFound, gin.H{"error": "Recipe not found"})
+			c.Error(apierror.NotFound("Recipe not found"))
 			return
 		}
 
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching recipe from database"})
+		c.Error(apierror.Internal("Error fetching recipe from database").WithCause(err))
+		return
+	}
+	// The recipe it was forked from and its own forks are listed as far as the
+	// caller may see them.
+	if !loadRecipeRelatives(c, db, &recipe, who) {
 		return
 	}
-	// 3. Return a JSON response with the fetched recipe.
-	c.JSON(http.StatusOK, recipe)
+
+	// 3. Return a JSON response with the fetched recipe, tagged with its version.
+	respondCached(c, key, etag(recipe.Version), recipe)
 }
 
+// updateRecipe replaces the fields of a recipe, returning the updated recipe.
+var updateRecipe = db.Register(`UPDATE recipes
+	SET recipe_name = $1, recipe_description = $2, cook_time = $3, visibility = $4
+	WHERE recipe_id = $5
+	RETURNING recipe_id, recipe_name, recipe_description, cook_time, owner_id, household_id, visibility, version, created_at, updated_at, forked_from`)
+
 // UpdateRecipe updates a recipe by ID.
 // UpdateRecipe godoc
 // @Summary Update a recipe by ID
@@ -114,139 +179,242 @@ func GetRecipe(c *gin.Context, db *sql.DB) {
 // @Accept json
 // @Produce json
 // @Param id path int true "Recipe ID"
-// @Param recipe body models.Recipe true "Update recipe"
+// @Param recipe body models.RecipeRequest true "Update recipe"
+// @Param If-Match header string false "ETag of the version being changed; the request fails with 412 if it is outdated"
 // @Success 200 {object} map[string]interface{} "Recipe updated successfully"
-// @Failure 400 {object} map[string]interface{} "Invalid recipe ID"
-// @Failure 404 {object} map[string]interface{} "Recipe not found"
-// @Failure 500 {object} map[string]interface{} "Internal server error"
+// @Failure 400 {object} apierror.Problem "Invalid recipe ID"
+// @Failure 401 {object} apierror.Problem "Authentication required"
+// @Failure 403 {object} apierror.Problem "Not allowed to modify the recipe"
+// @Failure 404 {object} apierror.Problem "Recipe not found"
+// @Failure 422 {object} apierror.Problem "Validation failed"
+// @Failure 412 {object} apierror.Problem "Resource was modified"
+// @Failure 500 {object} apierror.Problem "Internal server error"
 // @Router /recipes/{id} [put]
 func UpdateRecipe(c *gin.Context, db *sql.DB) {
 	// 1. Extract the recipe ID from the URL parameter.
 	recipeIDStr := c.Param("id")
 	recipeID, err := strconv.Atoi(recipeIDStr)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid recipe ID"})
+		c.Error(apierror.BadRequest("Invalid recipe ID"))
 		return
 	}
 
-	// Check if the recipe exists before updating.
-	checkExistenceQuery := "SELECT COUNT(*) FROM recipes WHERE recipe_id = $1"
-	var count int
-	err = db.QueryRow(checkExistenceQuery, recipeID).Scan(&count)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error checking recipe existence"})
+	// Check that the recipe exists and the caller may modify it.
+	access, ok := authorizeRecipe(c, db, recipeID, true)
+	if !ok {
 		return
 	}
 
-	if count == 0 {
-		// Recipe with the provided ID doesn't exist; respond with 404 Not Found
-		c.JSON(http.StatusNotFound, gin.H{"error": "Recipe not found"})
+	// 2. Bind the updated recipe data; patches apply to the stored recipe.
+	var updatedRecipe models.RecipeRequest
+	if !bindUpdate(c, &updatedRecipe, func() error { return loadRecipeRequest(c.Request.Context(), db, recipeID, &updatedRecipe) }) {
 		return
 	}
 
-	// 2. Define a variable to hold the updated recipe data.
-	var updatedRecipe models.Recipe
-	err = c.ShouldBindJSON(&updatedRecipe)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	// Only the owner may change who can see the recipe.
+	if updatedRecipe.Visibility == "" {
+		updatedRecipe.Visibility = access.visibility
+	}
+	if updatedRecipe.Visibility != access.visibility && !access.isOwner {
+		c.Error(apierror.Forbidden("Only the recipe owner can change its visibility"))
 		return
 	}
 
 	// 3. Perform validation and update the recipe in the database.
-	sqlQuery :=
-		`UPDATE recipes
-		SET recipe_name = $1, recipe_description = $2, cook_time = $3
-		WHERE recipe_id = $4`
+	// Apply the change and its audit entry in one transaction.
+	tx, err := db.BeginTx(c.Request.Context(), nil)
+	if err != nil {
+		c.Error(apierror.Internal("Error starting transaction").WithCause(err))
+		return
+	}
+	defer tx.Rollback()
+	before, ok := snapshotForAudit(c, tx, audit.EntityRecipe, recipeID)
+	if !ok {
+		return
+	}
+	if !checkIfMatch(c, tx, "recipes", "recipe_id", recipeID) {
+		return
+	}
 
-	stmt, err := db.Prepare(sqlQuery)
+	stmt, err := updateRecipe.In(c.Request.Context(), tx)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error preparing SQL statement"})
+		c.Error(apierror.Internal("Error preparing SQL statement").WithCause(err))
 		return
 	}
-	defer stmt.Close()
-	_, err = stmt.Exec(updatedRecipe.RecipeName, updatedRecipe.RecipeDescription, updatedRecipe.CookTime, recipeID)
+	var recipe models.Recipe
+	err = stmt.QueryRowContext(c.Request.Context(), updatedRecipe.RecipeName, updatedRecipe.RecipeDescription, updatedRecipe.CookTime, updatedRecipe.Visibility, recipeID).
+		Scan(&recipe.RecipeID, &recipe.RecipeName, &recipe.RecipeDescription, &recipe.CookTime, &recipe.OwnerID, &recipe.HouseholdID, &recipe.Visibility, &recipe.Version, &recipe.CreatedAt, &recipe.UpdatedAt, &recipe.ForkedFrom)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error executing SQL statement"})
+		c.Error(apierror.FromDB(err, "Error executing SQL statement"))
+		return
+	}
+
+	if !commitAudited(c, tx, audit.ActionUpdate, audit.EntityRecipe, recipeID, before) {
 		return
 	}
 
-	// Respond with a success message or the updated recipe if needed.
+	// Respond with a success message, or the updated recipe for patches.
+	c.Header("ETag", etag(recipe.Version))
+	if c.Request.Method == http.MethodPatch {
+		c.JSON(http.StatusOK, recipe)
+		return
+	}
 	c.JSON(http.StatusOK, gin.H{"message": "Recipe updated successfully"})
 }
 
-// DeleteRecipe deletes a recipe by ID.
+// PatchRecipe partially updates a recipe by ID.
+// PatchRecipe godoc
+// @Summary Partially update a recipe by ID
+// @Description Apply a JSON Merge Patch (RFC 7396) or JSON Patch (RFC 6902) to a recipe; only the supplied fields change
+// @Tags recipes
+// @Accept application/merge-patch+json,application/json-patch+json
+// @Produce json
+// @Param id path int true "Recipe ID"
+// @Param patch body models.RecipeRequest true "Fields to change"
+// @Param If-Match header string false "ETag of the version being changed; the request fails with 412 if it is outdated"
+// @Success 200 {object} models.Recipe
+// @Failure 400 {object} apierror.Problem "Invalid recipe ID or patch document"
+// @Failure 401 {object} apierror.Problem "Authentication required"
+// @Failure 403 {object} apierror.Problem "Not allowed to modify the recipe"
+// @Failure 404 {object} apierror.Problem "Recipe not found"
+// @Failure 409 {object} apierror.Problem "JSON Patch test failed"
+// @Failure 415 {object} apierror.Problem "Unsupported patch format"
+// @Failure 422 {object} apierror.Problem "Validation failed"
+// @Failure 412 {object} apierror.Problem "Resource was modified"
+// @Failure 500 {object} apierror.Problem "Internal server error"
+// @Router /recipes/{id} [patch]
+func PatchRecipe(c *gin.Context, db *sql.DB) {
+	UpdateRecipe(c, db)
+}
+
+// loadRecipeRequest fills req with the stored values of a recipe.
+func loadRecipeRequest(ctx context.Context, db *sql.DB, recipeID int, req *models.RecipeRequest) error {
+	sqlQuery := `
+		SELECT recipe_name, COALESCE(recipe_description, ''), COALESCE(cook_time, 0), visibility
+		FROM recipes
+		WHERE recipe_id = $1 AND deleted_at IS NULL`
+	return db.QueryRowContext(ctx, sqlQuery, recipeID).Scan(&req.RecipeName, &req.RecipeDescription, &req.CookTime, &req.Visibility)
+}
+
+// trashRecipe moves a recipe to the trash.
+var trashRecipe = db.Register("UPDATE recipes SET deleted_at = NOW() WHERE recipe_id = $1 AND deleted_at IS NULL")
+
+// DeleteRecipe moves a recipe to the trash by ID.
 // DeleteRecipe godoc
 // @Summary Delete a recipe by ID
-// @Description Delete a recipe from the database by ID
+// @Description Move a recipe to the trash by ID; it can be restored until it is purged. Unless deletes cascade, recipes with ingredients or steps cannot be deleted
 // @Tags recipes
 // @Accept json
 // @Produce json
 // @Param id path int true "Recipe ID"
+// @Param If-Match header string false "ETag of the version being changed; the request fails with 412 if it is outdated"
 // @Success 204 "Recipe deleted"
-// @Failure 400 {object} map[string]interface{} "Invalid recipe ID"
-// @Failure 404 {object} map[string]interface{} "Recipe not found"
-// @Failure 500 {object} map[string]interface{} "Internal server error"
+// @Failure 400 {object} apierror.Problem "Invalid recipe ID"
+// @Failure 401 {object} apierror.Problem "Authentication required"
+// @Failure 403 {object} apierror.Problem "Not the recipe owner"
+// @Failure 404 {object} apierror.Problem "Recipe not found"
+// @Failure 409 {object} apierror.Problem "Recipe still has ingredients or steps"
+// @Failure 412 {object} apierror.Problem "Resource was modified"
+// @Failure 500 {object} apierror.Problem "Internal server error"
 // @Router /recipes/{id} [delete]
 func DeleteRecipe(c *gin.Context, db *sql.DB) {
 	// 1. Extract the recipe ID from the URL parameter.
 	recipeIDStr := c.Param("id")
 	recipeID, err := strconv.Atoi(recipeIDStr)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid recipe ID"})
+		c.Error(apierror.BadRequest("Invalid recipe ID"))
 		return
 	}
 
-	// 2. Check if the recipe exists before deleting.
-	checkExistenceQuery := "SELECT COUNT(*) FROM recipes WHERE recipe_id = $1"
-	var count int
-	err = db.QueryRow(checkExistenceQuery, recipeID).Scan(&count)
+	// 2. Check that the recipe exists and the caller owns it.
+	if _, ok := authorizeRecipeOwner(c, db, recipeID); !ok {
+		return
+	}
+
+	// 3. Move the recipe to the trash.
+	// Apply the change and its audit entry in one transaction.
+	tx, err := db.BeginTx(c.Request.Context(), nil)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error checking recipe existence"})
+		c.Error(apierror.Internal("Error starting transaction").WithCause(err))
+		return
+	}
+	defer tx.Rollback()
+	before, ok := snapshotForAudit(c, tx, audit.EntityRecipe, recipeID)
+	if !ok {
+		return
+	}
+	if !checkIfMatch(c, tx, "recipes", "recipe_id", recipeID) {
 		return
 	}
 
-	if count == 0 {
-		// Recipe with the provided ID doesn't exist; respond with 404 Not Found
-		c.JSON(http.StatusNotFound, gin.H{"error": "Recipe not found"})
+	// Its ingredients and steps go to the trash with it only when deletes
+	// cascade. Collaborators stay so a restored recipe is shared as before.
+	if RecipeDeleteMode == DeleteCascade {
+		if !trashDependents(c, tx, audit.EntityRecipeIngredient, "recipe_ingredients", "recipe_ingredient_id", "recipe_id", recipeID) ||
+			!trashDependents(c, tx, audit.EntityRecipeStep, "recipe_steps", "recipe_step_id", "recipe_id", recipeID) {
+			return
+		}
+	} else if !refuseIfUsed(c, tx, "recipe", "recipe_ingredients", "recipe_id", recipeID) ||
+		!refuseIfUsed(c, tx, "recipe", "recipe_steps", "recipe_id", recipeID) {
 		return
 	}
 
-	// 3. Delete the recipe from the database.
-	sqlQuery := "DELETE FROM recipes WHERE recipe_id = $1"
-	stmt, err := db.Prepare(sqlQuery)
+	stmt, err := trashRecipe.In(c.Request.Context(), tx)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error preparing SQL statement"})
+		c.Error(apierror.Internal("Error preparing SQL statement").WithCause(err))
 		return
 	}
-	defer stmt.Close()
-	_, err = stmt.Exec(recipeID)
+	_, err = stmt.ExecContext(c.Request.Context(), recipeID)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error executing SQL statement"})
+		c.Error(apierror.FromDB(err, "Error executing SQL statement"))
+		return
+	}
+
+	if !commitAudited(c, tx, audit.ActionDelete, audit.EntityRecipe, recipeID, before) {
 		return
 	}
 
+	// 4. Return a 204 No Content response.
+	c.Status(http.StatusNoContent)
 }
 
 // GetRecipes retrieves a list of recipes.
 // GetRecipes godoc
 // @Summary Get all recipes
-// @Description Get a list of all recipes from the database
+// @Description Get a list of the recipes the caller may see in the current household
 // @Tags recipes
 // @Accept json
 // @Produce json
+// @Param updated_since query string false "Only rows changed at or after this RFC 3339 time"
+// @Param sort query string false "Sort by created_at or updated_at; prefix with - for newest first" Enums(created_at, -created_at, updated_at, -updated_at)
+// @Param If-None-Match header string false "ETag held by the client; unchanged resources return 304"
 // @Success 200 {array} models.Recipe
-// @Failure 500 {object} map[string]interface{} "Internal server error"
+// @Success 304 "Not Modified"
+// @Failure 400 {object} apierror.Problem "Invalid filter or sort"
+// @Failure 500 {object} apierror.Problem "Internal server error"
 // @Router /recipes [get]
 func GetRecipes(c *gin.Context, db *sql.DB) {
 
-	// 1. Fetch the recipes from the database.
+	// 1. Fetch the recipes visible to the caller from the database.
+	opts, ok := parseListOptions(c, "r", "recipe_id", "r.recipe_id")
+	if !ok {
+		return
+	}
+	// Answer from the response cache when possible.
+	key, served := serveCached(c, cacheRecipes)
+	if served {
+		return
+	}
+	who := callerOf(c)
 	sqlQuery :=
-		`SELECT recipe_id, recipe_name, recipe_description, cook_time
-		FROM recipes`
+		`SELECT r.recipe_id, r.recipe_name, r.recipe_description, r.cook_time, r.owner_id, r.household_id, r.visibility, r.version, r.created_at, r.updated_at, r.forked_from
+		FROM recipes r
+		WHERE ` + recipeViewable("$1", "$2") + ` AND ` + updatedSince("r", "$3") + `
+		ORDER BY ` + opts.orderBy
 
-	rows, err := db.Query(sqlQuery)
+	rows, err := db.QueryContext(c.Request.Context(), sqlQuery, who.userID, who.householdID, opts.updatedSince)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching recipes from database"})
+		c.Error(apierror.Internal("Error fetching recipes from database").WithCause(err))
 		return
 	}
 	defer rows.Close()
@@ -255,14 +423,14 @@ func GetRecipes(c *gin.Context, db *sql.DB) {
 	var recipes []models.Recipe
 	for rows.Next() {
 		var recipe models.Recipe
-		err := rows.Scan(&recipe.RecipeID, &recipe.RecipeName, &recipe.RecipeDescription, &recipe.CookTime)
+		err := rows.Scan(&recipe.RecipeID, &recipe.RecipeName, &recipe.RecipeDescription, &recipe.CookTime, &recipe.OwnerID, &recipe.HouseholdID, &recipe.Visibility, &recipe.Version, &recipe.CreatedAt, &recipe.UpdatedAt, &recipe.ForkedFrom)
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error scanning recipe row"})
+			c.Error(apierror.Internal("Error scanning recipe row").WithCause(err))
 			return
 		}
 		recipes = append(recipes, recipe)
 	}
 
 	// 3. Return a JSON response with the fetched recipes.
-	c.JSON(http.StatusOK, recipes)
+	respondCachedList(c, key, recipes)
 }
@@ -0,0 +1,161 @@
+package controllers
+
+import (
+	"backend/apierror"
+	"backend/models"
+	"database/sql"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetRecipeCollaborators returns the collaborators of a recipe.
+// GetRecipeCollaborators godoc
+// @Summary Get the collaborators of a recipe
+// @Description Get the users a recipe is shared with
+// @Tags recipe_collaborators
+// @Accept json
+// @Produce json
+// @Param id path int true "Recipe ID"
+// @Success 200 {array} models.RecipeCollaborator
+// @Failure 400 {object} apierror.Problem
+// @Failure 404 {object} apierror.Problem
+// @Failure 500 {object} apierror.Problem
+// @Router /recipes/{id}/collaborators [get]
+func GetRecipeCollaborators(c *gin.Context, db *sql.DB) {
+	// 1. Extract the recipe ID from the URL parameter.
+	recipeID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.Error(apierror.BadRequest("Invalid recipe ID"))
+		return
+	}
+
+	// 2. Check that the caller may see the recipe.
+	if _, ok := authorizeRecipe(c, db, recipeID, false); !ok {
+		return
+	}
+
+	// 3. Query the database for the collaborators.
+	sqlQuery := `SELECT recipe_id, user_id FROM recipe_collaborators WHERE recipe_id = $1 ORDER BY user_id`
+	rows, err := db.QueryContext(c.Request.Context(), sqlQuery, recipeID)
+	if err != nil {
+		c.Error(apierror.Internal("Error querying the database").WithCause(err))
+		return
+	}
+	defer rows.Close()
+
+	// 4. Iterate over the rows and add each collaborator to the slice.
+	collaborators := []models.RecipeCollaborator{}
+	for rows.Next() {
+		var collaborator models.RecipeCollaborator
+		if err := rows.Scan(&collaborator.RecipeID, &collaborator.UserID); err != nil {
+			c.Error(apierror.Internal("Error scanning rows").WithCause(err))
+			return
+		}
+		collaborators = append(collaborators, collaborator)
+	}
+
+	// 5. Return a JSON response with the collaborators.
+	c.JSON(http.StatusOK, collaborators)
+}
+
+// AddRecipeCollaborator shares a recipe with another user.
+// AddRecipeCollaborator godoc
+// @Summary Add a collaborator to a recipe
+// @Description Allow another user to view and edit a shared recipe
+// @Tags recipe_collaborators
+// @Accept json
+// @Produce json
+// @Param id path int true "Recipe ID"
+// @Param collaborator body models.RecipeCollaboratorRequest true "Collaborator"
+// @Param Idempotency-Key header string false "Unique key making retries of this request replay its first response"
+// @Success 201 {object} models.RecipeCollaborator
+// @Failure 400 {object} apierror.Problem
+// @Failure 401 {object} apierror.Problem
+// @Failure 403 {object} apierror.Problem
+// @Failure 404 {object} apierror.Problem
+// @Failure 409 {object} apierror.Problem "Request with this idempotency key in progress"
+// @Failure 422 {object} apierror.Problem "Validation failed"
+// @Failure 500 {object} apierror.Problem
+// @Router /recipes/{id}/collaborators [post]
+func AddRecipeCollaborator(c *gin.Context, db *sql.DB) {
+	// 1. Extract the recipe ID from the URL parameter.
+	recipeID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.Error(apierror.BadRequest("Invalid recipe ID"))
+		return
+	}
+
+	// 2. Bind the request JSON to the collaborator struct.
+	var collaboratorReq models.RecipeCollaboratorRequest
+	if err := c.ShouldBindJSON(&collaboratorReq); err != nil {
+		c.Error(apierror.FromBinding(err))
+		return
+	}
+
+	// 3. Only the owner may share the recipe.
+	if _, ok := authorizeRecipeOwner(c, db, recipeID); !ok {
+		return
+	}
+
+	// 4. Save the collaborator; adding an existing collaborator is a no-op.
+	sqlQuery := `
+		INSERT INTO recipe_collaborators (recipe_id, user_id)
+		VALUES ($1, $2)
+		ON CONFLICT DO NOTHING`
+	if _, err := db.ExecContext(c.Request.Context(), sqlQuery, recipeID, collaboratorReq.UserID); err != nil {
+		c.Error(apierror.FromDB(err, "Error adding collaborator"))
+		return
+	}
+	invalidateCache(c.Request.Context(), cacheRecipes)
+
+	// 5. Return a JSON response with the collaborator.
+	c.JSON(http.StatusCreated, models.RecipeCollaborator{RecipeID: recipeID, UserID: collaboratorReq.UserID})
+}
+
+// RemoveRecipeCollaborator revokes a user's access to a recipe.
+// RemoveRecipeCollaborator godoc
+// @Summary Remove a collaborator from a recipe
+// @Description Revoke another user's access to a shared recipe
+// @Tags recipe_collaborators
+// @Accept json
+// @Produce json
+// @Param id path int true "Recipe ID"
+// @Param user_id path int true "User ID"
+// @Success 204 "Collaborator removed"
+// @Failure 400 {object} apierror.Problem
+// @Failure 401 {object} apierror.Problem
+// @Failure 403 {object} apierror.Problem
+// @Failure 404 {object} apierror.Problem
+// @Failure 500 {object} apierror.Problem
+// @Router /recipes/{id}/collaborators/{user_id} [delete]
+func RemoveRecipeCollaborator(c *gin.Context, db *sql.DB) {
+	// 1. Extract the recipe and user IDs from the URL parameters.
+	recipeID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.Error(apierror.BadRequest("Invalid recipe ID"))
+		return
+	}
+	userID, err := strconv.Atoi(c.Param("user_id"))
+	if err != nil {
+		c.Error(apierror.BadRequest("Invalid user ID"))
+		return
+	}
+
+	// 2. Only the owner may revoke access.
+	if _, ok := authorizeRecipeOwner(c, db, recipeID); !ok {
+		return
+	}
+
+	// 3. Delete the collaborator from the database.
+	sqlQuery := `DELETE FROM recipe_collaborators WHERE recipe_id = $1 AND user_id = $2`
+	if _, err := db.ExecContext(c.Request.Context(), sqlQuery, recipeID, userID); err != nil {
+		c.Error(apierror.FromDB(err, "Error removing collaborator"))
+		return
+	}
+	invalidateCache(c.Request.Context(), cacheRecipes)
+
+	// 4. Return a 204 No Content response.
+	c.Status(http.StatusNoContent)
+}
@@ -0,0 +1,157 @@
+package controllers
+
+import (
+	"backend/apierror"
+	"backend/audit"
+	"backend/middleware"
+	"backend/models"
+	"database/sql"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ForkRecipe copies a recipe into a new, independently editable variation.
+// ForkRecipe godoc
+// @Summary Fork a recipe
+// @Description Copy a recipe with its ingredients and steps into a new private recipe owned by the caller, remembering where it came from
+// @Tags recipes
+// @Accept json
+// @Produce json
+// @Param id path int true "Recipe ID"
+// @Param Idempotency-Key header string false "Unique key making retries of this request replay its first response"
+// @Success 201 {object} models.Recipe "The fork"
+// @Failure 400 {object} apierror.Problem
+// @Failure 401 {object} apierror.Problem
+// @Failure 403 {object} apierror.Problem
+// @Failure 404 {object} apierror.Problem
+// @Failure 409 {object} apierror.Problem "Request with this idempotency key in progress"
+// @Failure 422 {object} apierror.Problem "Idempotency key reused for a different request"
+// @Failure 500 {object} apierror.Problem
+// @Router /recipes/{id}/fork [post]
+func ForkRecipe(c *gin.Context, db *sql.DB) {
+	// 1. Extract the recipe ID from the URL parameter.
+	recipeID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.Error(apierror.BadRequest("Invalid recipe ID"))
+		return
+	}
+
+	// 2. Anyone who may see a recipe may fork it; the caller owns the fork.
+	ownerID, ok := middleware.RequireIdentity(c)
+	if !ok {
+		return
+	}
+	who := callerOf(c)
+	if !requireWriter(c, who) {
+		return
+	}
+	if _, ok := authorizeRecipe(c, db, recipeID, false); !ok {
+		return
+	}
+
+	// 3. Copy the recipe, its ingredients and steps in one transaction.
+	tx, err := db.BeginTx(c.Request.Context(), nil)
+	if err != nil {
+		c.Error(apierror.Internal("Error starting transaction").WithCause(err))
+		return
+	}
+	defer tx.Rollback()
+
+	// Forks start out private, like any new recipe.
+	sqlQuery := `
+		INSERT INTO recipes (recipe_name, recipe_description, cook_time, owner_id, household_id, visibility, forked_from)
+		SELECT recipe_name, recipe_description, cook_time, $2, $3, $4, recipe_id
+		FROM recipes
+		WHERE recipe_id = $1 AND deleted_at IS NULL
+		RETURNING recipe_id, recipe_name, recipe_description, cook_time, owner_id, household_id, visibility, version, created_at, updated_at, forked_from`
+	var fork models.Recipe
+	err = tx.QueryRowContext(c.Request.Context(), sqlQuery, recipeID, ownerID, who.householdID, models.VisibilityPrivate).
+		Scan(&fork.RecipeID, &fork.RecipeName, &fork.RecipeDescription, &fork.CookTime, &fork.OwnerID, &fork.HouseholdID, &fork.Visibility, &fork.Version, &fork.CreatedAt, &fork.UpdatedAt, &fork.ForkedFrom)
+	if err == sql.ErrNoRows {
+		c.Error(apierror.NotFound("Recipe not found"))
+		return
+	}
+	if err != nil {
+		c.Error(apierror.FromDB(err, "Error copying recipe"))
+		return
+	}
+
+	var entries []models.AuditEntry
+	for _, part := range []struct {
+		entityType, sqlQuery string
+	}{
+		{audit.EntityRecipeIngredient, `
+			INSERT INTO recipe_ingredients AS t (recipe_id, ingredient_id, quantity, measurement)
+			SELECT $2, ingredient_id, quantity, measurement
+			FROM recipe_ingredients
+			WHERE recipe_id = $1 AND deleted_at IS NULL
+			ORDER BY recipe_ingredient_id
+			RETURNING t.recipe_ingredient_id, row_to_json(t)`},
+		{audit.EntityRecipeStep, `
+			INSERT INTO recipe_steps AS t (recipe_id, step_number, step_description)
+			SELECT $2, step_number, step_description
+			FROM recipe_steps
+			WHERE recipe_id = $1 AND deleted_at IS NULL
+			ORDER BY step_number, recipe_step_id
+			RETURNING t.recipe_step_id, row_to_json(t)`},
+	} {
+		copied, ok := auditRows(c, tx, audit.ActionCreate, part.entityType, part.sqlQuery, recipeID, fork.RecipeID)
+		if !ok {
+			return
+		}
+		entries = append(entries, copied...)
+	}
+	for _, entry := range entries {
+		if err := audit.Record(c.Request.Context(), tx, entry); err != nil {
+			c.Error(apierror.Internal("Error writing audit log").WithCause(err))
+			return
+		}
+	}
+
+	if !commitAudited(c, tx, audit.ActionCreate, audit.EntityRecipe, fork.RecipeID, nil) {
+		return
+	}
+
+	// 4. Return a JSON response with the fork.
+	c.Header("ETag", etag(fork.Version))
+	c.JSON(http.StatusCreated, fork)
+}
+
+// loadRecipeRelatives fills in the origin and variations of a recipe that the
+// caller may see. The recipe's ETag only covers the recipe itself, so clients
+// polling with If-None-Match see new variations once the recipe changes. It
+// writes the error response and returns false when the handler should stop.
+func loadRecipeRelatives(c *gin.Context, db *sql.DB, recipe *models.Recipe, who caller) bool {
+	sqlQuery := `
+		SELECT r.recipe_id, r.recipe_name, r.owner_id, COALESCE(r.recipe_id = $1::int, false)
+		FROM recipes r
+		WHERE (r.recipe_id = $1::int OR r.forked_from = $2) AND ` + recipeViewable("$3", "$4") + `
+		ORDER BY r.recipe_id`
+	rows, err := db.QueryContext(c.Request.Context(), sqlQuery, recipe.ForkedFrom, recipe.RecipeID, who.userID, who.householdID)
+	if err != nil {
+		c.Error(apierror.Internal("Error fetching related recipes").WithCause(err))
+		return false
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var summary models.RecipeSummary
+		var isOrigin bool
+		if err := rows.Scan(&summary.RecipeID, &summary.RecipeName, &summary.OwnerID, &isOrigin); err != nil {
+			c.Error(apierror.Internal("Error scanning related recipes").WithCause(err))
+			return false
+		}
+		if isOrigin {
+			recipe.Origin = &summary
+		} else {
+			recipe.Variations = append(recipe.Variations, summary)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		c.Error(apierror.Internal("Error fetching related recipes").WithCause(err))
+		return false
+	}
+	return true
+}
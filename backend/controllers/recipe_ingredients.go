@@ -1,15 +1,26 @@
 package controllers
 
 import (
+	"backend/apierror"
+	"backend/audit"
+	"backend/db"
 	"backend/models" // Import your models package where you have your struct definitions
+	"context"
 	"database/sql"
 	"net/http"
 	"strconv"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	_ "github.com/lib/pq" // Import the PostgreSQL driver
 )
 
+// insertRecipeIngredient adds an ingredient to a recipe, returning its ID and creation time.
+var insertRecipeIngredient = db.Register(`
+	INSERT INTO recipe_ingredients (recipe_id, ingredient_id, quantity, measurement)
+	VALUES ($1, $2, $3, $4)
+	RETURNING recipe_ingredient_id, created_at`)
+
 // CreateRecipeIngredient creates a new recipe ingredient.
 // CreateRecipeIngredient godoc
 // @Summary Create a new recipe ingredient
@@ -18,9 +29,15 @@ import (
 // @Accept json
 // @Produce json
 // @Param recipe_ingredient body models.RecipeIngredientRequest true "Add recipe ingredient"
+// @Param Idempotency-Key header string false "Unique key making retries of this request replay its first response"
 // @Success 201 {object} models.RecipeIngredient
-// @Failure 400 {object} map[string]interface{}
-// @Failure 500 {object} map[string]interface{}
+// @Failure 400 {object} apierror.Problem
+// @Failure 401 {object} apierror.Problem
+// @Failure 403 {object} apierror.Problem
+// @Failure 404 {object} apierror.Problem
+// @Failure 409 {object} apierror.Problem "Request with this idempotency key in progress"
+// @Failure 422 {object} apierror.Problem "Validation failed"
+// @Failure 500 {object} apierror.Problem
 // @Router /recipe-ingredients [post]
 func CreateRecipeIngredient(c *gin.Context, db *sql.DB) {
 	// 1. Define a variable to hold the recipe ingredient data.
@@ -28,26 +45,41 @@ func CreateRecipeIngredient(c *gin.Context, db *sql.DB) {
 
 	// 2. Bind the request JSON to the recipe ingredient struct.
 	if err := c.ShouldBindJSON(&recipeIngredient); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		c.Error(apierror.FromBinding(err))
+		return
+	}
+
+	// Only those who may edit the recipe may add ingredients to it.
+	if _, ok := authorizeRecipe(c, db, recipeIngredient.RecipeID, true); !ok {
+		return
+	}
+	if !authorizeIngredient(c, db, recipeIngredient.IngredientID) {
 		return
 	}
 
 	// 3. Perform validation and save the recipe ingredient to the database.
-	sqlQuery := `
-		INSERT INTO recipe_ingredients (recipe_id, ingredient_id, quantity)
-		VALUES ($1, $2, $3)
-		RETURNING recipe_ingredient_id`
+	// Apply the change and its audit entry in one transaction.
+	tx, err := db.BeginTx(c.Request.Context(), nil)
+	if err != nil {
+		c.Error(apierror.Internal("Error starting transaction").WithCause(err))
+		return
+	}
+	defer tx.Rollback()
 
-	stmt, err := db.Prepare(sqlQuery)
+	stmt, err := insertRecipeIngredient.In(c.Request.Context(), tx)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error preparing SQL statement"})
+		c.Error(apierror.Internal("Error preparing SQL statement").WithCause(err))
 		return
 	}
-	defer stmt.Close()
 	var recipeIngredientID int
-	err = stmt.QueryRow(recipeIngredient.RecipeID, recipeIngredient.IngredientID, recipeIngredient.Quantity).Scan(&recipeIngredientID)
+	var createdAt time.Time
+	err = stmt.QueryRowContext(c.Request.Context(), recipeIngredient.RecipeID, recipeIngredient.IngredientID, recipeIngredient.Quantity, recipeIngredient.Measurement).Scan(&recipeIngredientID, &createdAt)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error executing SQL statement"})
+		c.Error(apierror.FromDB(err, "Error executing SQL statement"))
+		return
+	}
+
+	if !commitAudited(c, tx, audit.ActionCreate, audit.EntityRecipeIngredient, recipeIngredientID, nil) {
 		return
 	}
 
@@ -57,6 +89,10 @@ func CreateRecipeIngredient(c *gin.Context, db *sql.DB) {
 		RecipeID:           recipeIngredient.RecipeID,
 		IngredientID:       recipeIngredient.IngredientID,
 		Quantity:           recipeIngredient.Quantity,
+		Measurement:        recipeIngredient.Measurement,
+		Version:            1,
+		CreatedAt:          createdAt,
+		UpdatedAt:          createdAt,
 	}
 
 	c.JSON(http.StatusCreated, createdRecipeIngredient)
@@ -65,22 +101,37 @@ func CreateRecipeIngredient(c *gin.Context, db *sql.DB) {
 // GetRecipeIngredients returns all recipe ingredients.
 // GetRecipeIngredients godoc
 // @Summary Get all recipe ingredients
-// @Description Get all recipe ingredients from the database
+// @Description Get all recipe ingredients of the recipes the caller may see
 // @Tags recipe_ingredients
 // @Accept json
 // @Produce json
+// @Param updated_since query string false "Only rows changed at or after this RFC 3339 time"
+// @Param sort query string false "Sort by created_at or updated_at; prefix with - for newest first" Enums(created_at, -created_at, updated_at, -updated_at)
+// @Param If-None-Match header string false "ETag held by the client; unchanged resources return 304"
 // @Success 200 {array} models.RecipeIngredient
-// @Failure 500 {object} map[string]interface{}
+// @Success 304 "Not Modified"
+// @Failure 400 {object} apierror.Problem "Invalid filter or sort"
+// @Failure 500 {object} apierror.Problem
 // @Router /recipe-ingredients [get]
 func GetRecipeIngredients(c *gin.Context, db *sql.DB) {
 	// 1. Define a variable to hold the recipe ingredients.
 	var recipeIngredients []models.RecipeIngredient
 
-	// 2. Query the database for all recipe ingredients.
-	sqlQuery := `SELECT recipe_ingredient_id, recipe_id, ingredient_id, quantity FROM recipe_ingredients`
-	rows, err := db.Query(sqlQuery)
+	// 2. Query the database for all recipe ingredients of visible recipes.
+	opts, ok := parseListOptions(c, "ri", "recipe_ingredient_id", "ri.recipe_ingredient_id")
+	if !ok {
+		return
+	}
+	who := callerOf(c)
+	sqlQuery := `
+		SELECT ri.recipe_ingredient_id, ri.recipe_id, ri.ingredient_id, ri.quantity, COALESCE(ri.measurement, ''), ri.version, ri.created_at, ri.updated_at
+		FROM recipe_ingredients ri
+		JOIN recipes r ON r.recipe_id = ri.recipe_id
+		WHERE ri.deleted_at IS NULL AND ` + recipeViewable("$1", "$2") + ` AND ` + updatedSince("ri", "$3") + `
+		ORDER BY ` + opts.orderBy
+	rows, err := db.QueryContext(c.Request.Context(), sqlQuery, who.userID, who.householdID, opts.updatedSince)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error querying the database"})
+		c.Error(apierror.Internal("Error querying the database").WithCause(err))
 		return
 	}
 	defer rows.Close()
@@ -88,16 +139,16 @@ func GetRecipeIngredients(c *gin.Context, db *sql.DB) {
 	// 3. Iterate over the rows and add each recipe ingredient to the slice.
 	for rows.Next() {
 		var recipeIngredient models.RecipeIngredient
-		err := rows.Scan(&recipeIngredient.RecipeIngredientID, &recipeIngredient.RecipeID, &recipeIngredient.IngredientID, &recipeIngredient.Quantity)
+		err := rows.Scan(&recipeIngredient.RecipeIngredientID, &recipeIngredient.RecipeID, &recipeIngredient.IngredientID, &recipeIngredient.Quantity, &recipeIngredient.Measurement, &recipeIngredient.Version, &recipeIngredient.CreatedAt, &recipeIngredient.UpdatedAt)
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error scanning rows"})
+			c.Error(apierror.Internal("Error scanning rows").WithCause(err))
 			return
 		}
 		recipeIngredients = append(recipeIngredients, recipeIngredient)
 	}
 
 	// 4. Return a JSON response with the recipe ingredients.
-	c.JSON(http.StatusOK, recipeIngredients)
+	respondWithListETag(c, recipeIngredients)
 }
 
 // GetRecipeIngredient retrieves a single recipe ingredient by ID.
@@ -108,9 +159,11 @@ func GetRecipeIngredients(c *gin.Context, db *sql.DB) {
 // @Accept json
 // @Produce json
 // @Param id path int true "Recipe Ingredient ID"
+// @Param If-None-Match header string false "ETag held by the client; unchanged resources return 304"
 // @Success 200 {object} models.RecipeIngredient "Recipe ingredient retrieved"
-// @Failure 400 {object} map[string]interface{} "Invalid ID"
-// @Failure 404 {object} map[string]interface{} "Recipe ingredient not found"
+// @Success 304 "Not Modified"
+// @Failure 400 {object} apierror.Problem "Invalid ID"
+// @Failure 404 {object} apierror.Problem "Recipe ingredient not found"
 // @Router /recipe-ingredients/{id} [get]
 func GetRecipeIngredient(c *gin.Context, db *sql.DB) {
 	// 1. Extract the recipe ingredient ID from the URL parameter.
@@ -118,23 +171,39 @@ func GetRecipeIngredient(c *gin.Context, db *sql.DB) {
 	// If the recipeIngredientID is not a valid integer, return a 400 Bad Request response.
 	recipeIngredientID, err := strconv.Atoi(recipeIngredientIDStr)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid recipe ingredient ID"})
+		c.Error(apierror.BadRequest("Invalid recipe ingredient ID"))
 		return
 	}
 
 	// 2. Query the database for the recipe ingredient.
-	sqlQuery := `SELECT recipe_ingredient_id, recipe_id, ingredient_id, quantity FROM recipe_ingredients WHERE recipe_ingredient_id = $1`
+	sqlQuery := `SELECT recipe_ingredient_id, recipe_id, ingredient_id, quantity, COALESCE(measurement, ''), version, created_at, updated_at FROM recipe_ingredients WHERE recipe_ingredient_id = $1 AND deleted_at IS NULL`
 	var recipeIngredient models.RecipeIngredient
-	err = db.QueryRow(sqlQuery, recipeIngredientID).Scan(&recipeIngredient.RecipeIngredientID, &recipeIngredient.RecipeID, &recipeIngredient.IngredientID, &recipeIngredient.Quantity)
+	err = db.QueryRowContext(c.Request.Context(), sqlQuery, recipeIngredientID).Scan(&recipeIngredient.RecipeIngredientID, &recipeIngredient.RecipeID, &recipeIngredient.IngredientID, &recipeIngredient.Quantity, &recipeIngredient.Measurement, &recipeIngredient.Version, &recipeIngredient.CreatedAt, &recipeIngredient.UpdatedAt)
+	if err == sql.ErrNoRows {
+		c.Error(apierror.NotFound("Recipe ingredient not found"))
+		return
+	}
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Recipe ingredient not found"})
+		c.Error(apierror.FromDB(err, "Error fetching recipe ingredient"))
+		return
+	}
+
+	// The recipe ingredient is only visible to those who may see its recipe.
+	if _, ok := authorizeRecipe(c, db, recipeIngredient.RecipeID, false); !ok {
 		return
 	}
 
-	// 3. Return a JSON response with the retrieved recipe ingredient.
-	c.JSON(http.StatusOK, recipeIngredient)
+	// 3. Return a JSON response with the retrieved recipe ingredient, tagged with its version.
+	respondWithETag(c, etag(recipeIngredient.Version), recipeIngredient)
 }
 
+// updateRecipeIngredient replaces the fields of a recipe ingredient, returning its version and timestamps.
+var updateRecipeIngredient = db.Register(`
+	UPDATE recipe_ingredients
+	SET recipe_id = $1, ingredient_id = $2, quantity = $3, measurement = $4
+	WHERE recipe_ingredient_id = $5
+	RETURNING version, created_at, updated_at`)
+
 // UpdateRecipeIngredient updates a recipe ingredient by ID.
 // UpdateRecipeIngredient godoc
 // @Summary Update a recipe ingredient
@@ -143,45 +212,77 @@ func GetRecipeIngredient(c *gin.Context, db *sql.DB) {
 // @Accept json
 // @Produce json
 // @Param id path int true "Recipe Ingredient ID"
-// @Param recipe_ingredient body models.RecipeIngredient true "Update recipe ingredient"
+// @Param recipe_ingredient body models.RecipeIngredientRequest true "Update recipe ingredient"
+// @Param If-Match header string false "ETag of the version being changed; the request fails with 412 if it is outdated"
 // @Success 200 {object} models.RecipeIngredient
-// @Failure 400 {object} map[string]interface{}
-// @Failure 404 {object} map[string]interface{}
-// @Failure 500 {object} map[string]interface{}
+// @Failure 400 {object} apierror.Problem
+// @Failure 401 {object} apierror.Problem
+// @Failure 403 {object} apierror.Problem
+// @Failure 404 {object} apierror.Problem
+// @Failure 422 {object} apierror.Problem "Validation failed"
+// @Failure 412 {object} apierror.Problem "Resource was modified"
+// @Failure 500 {object} apierror.Problem
 // @Router /recipe-ingredients/{id} [put]
 func UpdateRecipeIngredient(c *gin.Context, db *sql.DB) {
 	// 1. Extract the recipe ingredient ID from the URL parameter.
 	recipeIngredientIDStr := c.Param("id")
 	recipeIngredientID, err := strconv.Atoi(recipeIngredientIDStr)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid recipe ingredient ID"})
+		c.Error(apierror.BadRequest("Invalid recipe ingredient ID"))
+		return
+	}
+
+	// The caller must be able to edit both the current and the target recipe.
+	if !authorizeRecipeIngredientEdit(c, db, recipeIngredientID) {
 		return
 	}
 
 	// 2. Define a variable to hold the recipe ingredient data.
-	var recipeIngredient models.RecipeIngredient
+	var recipeIngredient models.RecipeIngredientRequest
 
-	// 3. Bind the request JSON to the recipe ingredient struct.
-	if err := c.ShouldBindJSON(&recipeIngredient); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	// 3. Bind the request JSON to the recipe ingredient struct; patches apply to the stored ingredient.
+	if !bindUpdate(c, &recipeIngredient, func() error {
+		return loadRecipeIngredientRequest(c.Request.Context(), db, recipeIngredientID, &recipeIngredient)
+	}) {
+		return
+	}
+	if _, ok := authorizeRecipe(c, db, recipeIngredient.RecipeID, true); !ok {
+		return
+	}
+	if !authorizeIngredient(c, db, recipeIngredient.IngredientID) {
 		return
 	}
 
 	// 4. Perform validation and update the recipe ingredient in the database.
-	sqlQuery := `
-		UPDATE recipe_ingredients
-		SET recipe_id = $1, ingredient_id = $2, quantity = $3
-		WHERE recipe_ingredient_id = $4`
+	// Apply the change and its audit entry in one transaction.
+	tx, err := db.BeginTx(c.Request.Context(), nil)
+	if err != nil {
+		c.Error(apierror.Internal("Error starting transaction").WithCause(err))
+		return
+	}
+	defer tx.Rollback()
+	before, ok := snapshotForAudit(c, tx, audit.EntityRecipeIngredient, recipeIngredientID)
+	if !ok {
+		return
+	}
+	if !checkIfMatch(c, tx, "recipe_ingredients", "recipe_ingredient_id", recipeIngredientID) {
+		return
+	}
 
-	stmt, err := db.Prepare(sqlQuery)
+	stmt, err := updateRecipeIngredient.In(c.Request.Context(), tx)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error preparing SQL statement"})
+		c.Error(apierror.Internal("Error preparing SQL statement").WithCause(err))
 		return
 	}
-	defer stmt.Close()
-	_, err = stmt.Exec(recipeIngredient.RecipeID, recipeIngredient.IngredientID, recipeIngredient.Quantity, recipeIngredientID)
+	var version int
+	var createdAt, updatedAt time.Time
+	err = stmt.QueryRowContext(c.Request.Context(), recipeIngredient.RecipeID, recipeIngredient.IngredientID, recipeIngredient.Quantity, recipeIngredient.Measurement, recipeIngredientID).Scan(&version, &createdAt, &updatedAt)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error executing SQL statement"})
+		c.Error(apierror.FromDB(err, "Error executing SQL statement"))
+		return
+	}
+
+	if !commitAudited(c, tx, audit.ActionUpdate, audit.EntityRecipeIngredient, recipeIngredientID, before) {
 		return
 	}
 
@@ -191,11 +292,19 @@ func UpdateRecipeIngredient(c *gin.Context, db *sql.DB) {
 		RecipeID:           recipeIngredient.RecipeID,
 		IngredientID:       recipeIngredient.IngredientID,
 		Quantity:           recipeIngredient.Quantity,
+		Measurement:        recipeIngredient.Measurement,
+		Version:            version,
+		CreatedAt:          createdAt,
+		UpdatedAt:          updatedAt,
 	}
 
+	c.Header("ETag", etag(version))
 	c.JSON(http.StatusOK, updatedRecipeIngredient)
 }
 
+// deleteRecipeIngredient removes an ingredient from a recipe.
+var deleteRecipeIngredient = db.Register(`DELETE FROM recipe_ingredients WHERE recipe_ingredient_id = $1`)
+
 // DeleteRecipeIngredient deletes a recipe ingredient by ID.
 // DeleteRecipeIngredient godoc
 // @Summary Delete a recipe ingredient
@@ -204,28 +313,111 @@ func UpdateRecipeIngredient(c *gin.Context, db *sql.DB) {
 // @Accept json
 // @Produce json
 // @Param id path int true "Recipe Ingredient ID"
+// @Param If-Match header string false "ETag of the version being changed; the request fails with 412 if it is outdated"
 // @Success 204 "Recipe ingredient deleted"
-// @Failure 400 {object} map[string]interface{} "Invalid ID"
-// @Failure 404 {object} map[string]interface{} "Recipe ingredient not found"
-// @Failure 500 {object} map[string]interface{} "Internal server error"
+// @Failure 400 {object} apierror.Problem "Invalid ID"
+// @Failure 401 {object} apierror.Problem "Authentication required"
+// @Failure 403 {object} apierror.Problem "Not allowed to modify the recipe"
+// @Failure 404 {object} apierror.Problem "Recipe ingredient not found"
+// @Failure 412 {object} apierror.Problem "Resource was modified"
+// @Failure 500 {object} apierror.Problem "Internal server error"
 // @Router /recipe-ingredients/{id} [delete]
 func DeleteRecipeIngredient(c *gin.Context, db *sql.DB) {
 	// 1. Extract the recipe ingredient ID from the URL parameter.
 	recipeIngredientIDStr := c.Param("id")
 	recipeIngredientID, err := strconv.Atoi(recipeIngredientIDStr)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid recipe ingredient ID"})
+		c.Error(apierror.BadRequest("Invalid recipe ingredient ID"))
+		return
+	}
+
+	// Only those who may edit the recipe may remove its ingredients.
+	if !authorizeRecipeIngredientEdit(c, db, recipeIngredientID) {
 		return
 	}
 
 	// 2. Delete the recipe ingredient from the database.
-	sqlQuery := `DELETE FROM recipe_ingredients WHERE recipe_ingredient_id = $1`
-	_, err = db.Exec(sqlQuery, recipeIngredientID)
+	// Apply the change and its audit entry in one transaction.
+	tx, err := db.BeginTx(c.Request.Context(), nil)
+	if err != nil {
+		c.Error(apierror.Internal("Error starting transaction").WithCause(err))
+		return
+	}
+	defer tx.Rollback()
+	before, ok := snapshotForAudit(c, tx, audit.EntityRecipeIngredient, recipeIngredientID)
+	if !ok {
+		return
+	}
+	if !checkIfMatch(c, tx, "recipe_ingredients", "recipe_ingredient_id", recipeIngredientID) {
+		return
+	}
+
+	stmt, err := deleteRecipeIngredient.In(c.Request.Context(), tx)
+	if err != nil {
+		c.Error(apierror.Internal("Error preparing SQL statement").WithCause(err))
+		return
+	}
+	_, err = stmt.ExecContext(c.Request.Context(), recipeIngredientID)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error deleting recipe ingredient"})
+		c.Error(apierror.FromDB(err, "Error deleting recipe ingredient"))
+		return
+	}
+
+	if !commitAudited(c, tx, audit.ActionDelete, audit.EntityRecipeIngredient, recipeIngredientID, before) {
 		return
 	}
 
 	// 3. Return a 204 No Content response.
 	c.Status(http.StatusNoContent)
 }
+
+// PatchRecipeIngredient partially updates a recipe ingredient by ID.
+// PatchRecipeIngredient godoc
+// @Summary Partially update a recipe ingredient
+// @Description Apply a JSON Merge Patch (RFC 7396) or JSON Patch (RFC 6902) to a recipe ingredient; only the supplied fields change
+// @Tags recipe_ingredients
+// @Accept application/merge-patch+json,application/json-patch+json
+// @Produce json
+// @Param id path int true "Recipe Ingredient ID"
+// @Param patch body models.RecipeIngredientRequest true "Fields to change"
+// @Param If-Match header string false "ETag of the version being changed; the request fails with 412 if it is outdated"
+// @Success 200 {object} models.RecipeIngredient
+// @Failure 400 {object} apierror.Problem "Invalid recipe ingredient ID or patch document"
+// @Failure 401 {object} apierror.Problem "Authentication required"
+// @Failure 403 {object} apierror.Problem "Not allowed to modify the recipe"
+// @Failure 404 {object} apierror.Problem "Recipe ingredient not found"
+// @Failure 409 {object} apierror.Problem "JSON Patch test failed"
+// @Failure 415 {object} apierror.Problem "Unsupported patch format"
+// @Failure 422 {object} apierror.Problem "Validation failed"
+// @Failure 412 {object} apierror.Problem "Resource was modified"
+// @Failure 500 {object} apierror.Problem "Internal server error"
+// @Router /recipe-ingredients/{id} [patch]
+func PatchRecipeIngredient(c *gin.Context, db *sql.DB) {
+	UpdateRecipeIngredient(c, db)
+}
+
+// loadRecipeIngredientRequest fills req with the stored values of a recipe ingredient.
+func loadRecipeIngredientRequest(ctx context.Context, db *sql.DB, recipeIngredientID int, req *models.RecipeIngredientRequest) error {
+	sqlQuery := `
+		SELECT recipe_id, ingredient_id, quantity, COALESCE(measurement, '')
+		FROM recipe_ingredients
+		WHERE recipe_ingredient_id = $1 AND deleted_at IS NULL`
+	return db.QueryRowContext(ctx, sqlQuery, recipeIngredientID).Scan(&req.RecipeID, &req.IngredientID, &req.Quantity, &req.Measurement)
+}
+
+// authorizeRecipeIngredientEdit checks that the caller may edit the recipe a
+// recipe ingredient belongs to. It returns false once a response has been written.
+func authorizeRecipeIngredientEdit(c *gin.Context, db *sql.DB, recipeIngredientID int) bool {
+	recipeID, err := parentRecipeID(c.Request.Context(), db, "recipe_ingredients", "recipe_ingredient_id", recipeIngredientID)
+	if err == sql.ErrNoRows {
+		c.Error(apierror.NotFound("Recipe ingredient not found"))
+		return false
+	}
+	if err != nil {
+		c.Error(apierror.Internal("Error fetching recipe ingredient").WithCause(err))
+		return false
+	}
+
+	_, ok := authorizeRecipe(c, db, recipeID, true)
+	return ok
+}
@@ -0,0 +1,550 @@
+package controllers
+
+import (
+	"backend/apierror"
+	"backend/audit"
+	"backend/models"
+	"backend/revisions"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lib/pq"
+)
+
+// GetRecipeRevisions lists the revisions of a recipe, newest first.
+// GetRecipeRevisions godoc
+// @Summary Get the revisions of a recipe
+// @Description Get the revisions recorded whenever the recipe, its ingredients or steps changed, newest first, without their documents
+// @Tags recipe_revisions
+// @Accept json
+// @Produce json
+// @Param id path int true "Recipe ID"
+// @Success 200 {array} models.RecipeRevision
+// @Failure 400 {object} apierror.Problem
+// @Failure 404 {object} apierror.Problem
+// @Failure 500 {object} apierror.Problem
+// @Router /recipes/{id}/revisions [get]
+func GetRecipeRevisions(c *gin.Context, db *sql.DB) {
+	// 1. Extract the recipe ID from the URL parameter.
+	recipeID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.Error(apierror.BadRequest("Invalid recipe ID"))
+		return
+	}
+
+	// 2. Check that the caller may see the recipe.
+	if _, ok := authorizeRecipe(c, db, recipeID, false); !ok {
+		return
+	}
+
+	// 3. Query the database for the revisions.
+	sqlQuery := `
+		SELECT recipe_id, revision, actor_id, COALESCE(request_id, ''), created_at
+		FROM recipe_revisions
+		WHERE recipe_id = $1
+		ORDER BY revision DESC`
+	rows, err := db.QueryContext(c.Request.Context(), sqlQuery, recipeID)
+	if err != nil {
+		c.Error(apierror.Internal("Error querying the database").WithCause(err))
+		return
+	}
+	defer rows.Close()
+
+	// 4. Iterate over the rows and add each revision to the slice.
+	list := []models.RecipeRevision{}
+	for rows.Next() {
+		var revision models.RecipeRevision
+		if err := rows.Scan(&revision.RecipeID, &revision.Revision, &revision.ActorID, &revision.RequestID, &revision.CreatedAt); err != nil {
+			c.Error(apierror.Internal("Error scanning rows").WithCause(err))
+			return
+		}
+		list = append(list, revision)
+	}
+
+	// 5. Return a JSON response with the revisions.
+	c.JSON(http.StatusOK, list)
+}
+
+// GetRecipeRevision returns one revision of a recipe with its document.
+// GetRecipeRevision godoc
+// @Summary Get a revision of a recipe
+// @Description Get a revision of a recipe, including the recipe fields, ingredients and steps it recorded
+// @Tags recipe_revisions
+// @Accept json
+// @Produce json
+// @Param id path int true "Recipe ID"
+// @Param revision path int true "Revision number"
+// @Success 200 {object} models.RecipeRevision
+// @Failure 400 {object} apierror.Problem
+// @Failure 404 {object} apierror.Problem
+// @Failure 500 {object} apierror.Problem
+// @Router /recipes/{id}/revisions/{revision} [get]
+func GetRecipeRevision(c *gin.Context, db *sql.DB) {
+	// 1. Extract the recipe ID and revision number from the URL parameters.
+	recipeID, number, ok := revisionParams(c, "revision")
+	if !ok {
+		return
+	}
+
+	// 2. Check that the caller may see the recipe.
+	if _, ok := authorizeRecipe(c, db, recipeID, false); !ok {
+		return
+	}
+
+	// 3. Load the revision and return it.
+	revision, ok := loadRevision(c, db, recipeID, number)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, revision)
+}
+
+// GetRecipeRevisionDiff compares two revisions of a recipe.
+// GetRecipeRevisionDiff godoc
+// @Summary Compare two revisions of a recipe
+// @Description List the field, ingredient and step changes from one revision of a recipe to another
+// @Tags recipe_revisions
+// @Accept json
+// @Produce json
+// @Param id path int true "Recipe ID"
+// @Param revision path int true "Revision to compare from"
+// @Param to path int true "Revision to compare to"
+// @Success 200 {object} models.RevisionDiff
+// @Failure 400 {object} apierror.Problem
+// @Failure 404 {object} apierror.Problem
+// @Failure 500 {object} apierror.Problem
+// @Router /recipes/{id}/revisions/{revision}/diff/{to} [get]
+func GetRecipeRevisionDiff(c *gin.Context, db *sql.DB) {
+	// 1. Extract the recipe ID and both revision numbers from the URL parameters.
+	recipeID, fromNumber, ok := revisionParams(c, "revision")
+	if !ok {
+		return
+	}
+	toNumber, err := strconv.Atoi(c.Param("to"))
+	if err != nil {
+		c.Error(apierror.BadRequest("Invalid revision number"))
+		return
+	}
+
+	// 2. Check that the caller may see the recipe.
+	if _, ok := authorizeRecipe(c, db, recipeID, false); !ok {
+		return
+	}
+
+	// 3. Load both revisions and compare their documents.
+	from, ok := loadRevision(c, db, recipeID, fromNumber)
+	if !ok {
+		return
+	}
+	to, ok := loadRevision(c, db, recipeID, toNumber)
+	if !ok {
+		return
+	}
+	diff := revisions.Diff(*from.Document, *to.Document)
+	diff.RecipeID, diff.From, diff.To = recipeID, fromNumber, toNumber
+
+	// 4. Return a JSON response with the differences.
+	c.JSON(http.StatusOK, diff)
+}
+
+// RevertRecipe restores a recipe to the content of an earlier revision.
+// RevertRecipe godoc
+// @Summary Revert a recipe to a revision
+// @Description Change the recipe fields, ingredients and steps to those of an earlier revision, recording the result as a new revision. Only ingredients and steps that differ from the revision are changed
+// @Tags recipe_revisions
+// @Accept json
+// @Produce json
+// @Param id path int true "Recipe ID"
+// @Param revision path int true "Revision to revert to"
+// @Param If-Match header string false "ETag of the version being changed; the request fails with 412 if it is outdated"
+// @Param Idempotency-Key header string false "Unique key making retries of this request replay its first response"
+// @Success 201 {object} models.RecipeRevision "The new revision"
+// @Success 200 {object} models.RecipeRevision "The recipe already matched the revision"
+// @Failure 400 {object} apierror.Problem
+// @Failure 401 {object} apierror.Problem
+// @Failure 403 {object} apierror.Problem
+// @Failure 404 {object} apierror.Problem
+// @Failure 409 {object} apierror.Problem "The revision uses deleted or unavailable ingredients"
+// @Failure 412 {object} apierror.Problem "Resource was modified"
+// @Failure 422 {object} apierror.Problem "Idempotency key reused for a different request"
+// @Failure 500 {object} apierror.Problem
+// @Router /recipes/{id}/revisions/{revision}/revert [post]
+func RevertRecipe(c *gin.Context, db *sql.DB) {
+	// 1. Extract the recipe ID and revision number from the URL parameters.
+	recipeID, number, ok := revisionParams(c, "revision")
+	if !ok {
+		return
+	}
+
+	// 2. Check that the caller may edit the recipe and load the revision.
+	access, ok := authorizeRecipe(c, db, recipeID, true)
+	if !ok {
+		return
+	}
+	target, ok := loadRevision(c, db, recipeID, number)
+	if !ok {
+		return
+	}
+	doc := target.Document
+	if doc.Visibility != access.visibility && !access.isOwner {
+		c.Error(apierror.Forbidden("Only the recipe owner can change its visibility"))
+		return
+	}
+
+	// 3. Change the recipe's content back and audit every change in one transaction.
+	tx, err := db.BeginTx(c.Request.Context(), nil)
+	if err != nil {
+		c.Error(apierror.Internal("Error starting transaction").WithCause(err))
+		return
+	}
+	defer tx.Rollback()
+	before, ok := snapshotForAudit(c, tx, audit.EntityRecipe, recipeID)
+	if !ok {
+		return
+	}
+	if !checkIfMatch(c, tx, "recipes", "recipe_id", recipeID) {
+		return
+	}
+	var latest int
+	if err := tx.QueryRowContext(c.Request.Context(), `SELECT COALESCE(MAX(revision), 0) FROM recipe_revisions WHERE recipe_id = $1`, recipeID).Scan(&latest); err != nil {
+		c.Error(apierror.Internal("Error reading revisions").WithCause(err))
+		return
+	}
+
+	// Ingredients deleted since the revision was recorded cannot come back with
+	// it, and neither can ingredients the recipe's tenant may not use.
+	ingredientIDs := make([]int64, 0, len(doc.Ingredients))
+	for _, ingredient := range doc.Ingredients {
+		ingredientIDs = append(ingredientIDs, int64(ingredient.IngredientID))
+	}
+	var missing int
+	sqlQuery := `
+		SELECT COUNT(*) FROM unnest($1::int[]) AS ref(ingredient_id)
+		WHERE NOT EXISTS (SELECT 1 FROM ingredients i WHERE i.ingredient_id = ref.ingredient_id AND ` + ingredientVisible("$2") + `)`
+	if err := tx.QueryRowContext(c.Request.Context(), sqlQuery, pq.Array(ingredientIDs), callerOf(c).householdID).Scan(&missing); err != nil {
+		c.Error(apierror.Internal("Error checking ingredients").WithCause(err))
+		return
+	}
+	if missing > 0 {
+		c.Error(apierror.Conflict("The revision uses ingredients that have since been deleted or are not available to the recipe"))
+		return
+	}
+
+	sqlQuery = `
+		UPDATE recipes
+		SET recipe_name = $1, recipe_description = $2, cook_time = $3, visibility = $4
+		WHERE recipe_id = $5`
+	if _, err := tx.ExecContext(c.Request.Context(), sqlQuery, doc.RecipeName, doc.RecipeDescription, doc.CookTime, doc.Visibility, recipeID); err != nil {
+		c.Error(apierror.FromDB(err, "Error updating recipe"))
+		return
+	}
+
+	// Only the ingredients and steps that differ from the revision change, so
+	// that the others keep their IDs and history.
+	entries, ok := revertIngredients(c, tx, recipeID, doc.Ingredients)
+	if !ok {
+		return
+	}
+	steps, ok := revertSteps(c, tx, recipeID, doc.Steps)
+	if !ok {
+		return
+	}
+	entries = append(entries, steps...)
+	for _, entry := range entries {
+		if err := audit.Record(c.Request.Context(), tx, entry); err != nil {
+			c.Error(apierror.Internal("Error writing audit log").WithCause(err))
+			return
+		}
+	}
+
+	if !commitAudited(c, tx, audit.ActionUpdate, audit.EntityRecipe, recipeID, before) {
+		return
+	}
+
+	// 4. Return the revision the recipe now matches.
+	var current int
+	if err := db.QueryRowContext(c.Request.Context(), `SELECT COALESCE(MAX(revision), 0) FROM recipe_revisions WHERE recipe_id = $1`, recipeID).Scan(&current); err != nil {
+		c.Error(apierror.Internal("Error reading revisions").WithCause(err))
+		return
+	}
+	revision, ok := loadRevision(c, db, recipeID, current)
+	if !ok {
+		return
+	}
+	status := http.StatusOK
+	if current > latest {
+		status = http.StatusCreated
+	}
+	c.JSON(status, revision)
+}
+
+// revisionParams parses the recipe ID and the revision number in the named
+// URL parameter. It writes the error response and returns false when the
+// handler should stop.
+func revisionParams(c *gin.Context, name string) (int, int, bool) {
+	recipeID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.Error(apierror.BadRequest("Invalid recipe ID"))
+		return 0, 0, false
+	}
+	number, err := strconv.Atoi(c.Param(name))
+	if err != nil {
+		c.Error(apierror.BadRequest("Invalid revision number"))
+		return 0, 0, false
+	}
+	return recipeID, number, true
+}
+
+// loadRevision reads a revision of a recipe with its document. It writes the
+// error response and returns false when the handler should stop.
+func loadRevision(c *gin.Context, db *sql.DB, recipeID, number int) (models.RecipeRevision, bool) {
+	sqlQuery := `
+		SELECT recipe_id, revision, actor_id, COALESCE(request_id, ''), created_at, document
+		FROM recipe_revisions
+		WHERE recipe_id = $1 AND revision = $2`
+	var revision models.RecipeRevision
+	var document []byte
+	err := db.QueryRowContext(c.Request.Context(), sqlQuery, recipeID, number).Scan(&revision.RecipeID, &revision.Revision, &revision.ActorID, &revision.RequestID, &revision.CreatedAt, &document)
+	if err == sql.ErrNoRows {
+		c.Error(apierror.NotFound("Revision not found"))
+		return revision, false
+	}
+	if err != nil {
+		c.Error(apierror.Internal("Error querying the database").WithCause(err))
+		return revision, false
+	}
+
+	revision.Document = &models.RecipeDocument{}
+	if err := json.Unmarshal(document, revision.Document); err != nil {
+		c.Error(apierror.Internal("Error decoding revision").WithCause(err))
+		return revision, false
+	}
+	return revision, true
+}
+
+// revertIngredients changes the live ingredients of a recipe to match target.
+// Current rows are paired with the target's ingredients in order, as
+// revisions.Diff pairs repeated ingredients; paired rows that differ are
+// updated, unpaired rows deleted and missing ingredients inserted. It returns
+// the audit entries of the changes, or writes the error response and returns
+// false when the handler should stop.
+func revertIngredients(c *gin.Context, tx *sql.Tx, recipeID int, target []models.RevisionIngredient) ([]models.AuditEntry, bool) {
+	type row struct {
+		id         int
+		ingredient models.RevisionIngredient
+	}
+	sqlQuery := `
+		SELECT recipe_ingredient_id, ingredient_id, quantity, COALESCE(measurement, '')
+		FROM recipe_ingredients
+		WHERE recipe_id = $1 AND deleted_at IS NULL
+		ORDER BY recipe_ingredient_id
+		FOR UPDATE`
+	rows, err := tx.QueryContext(c.Request.Context(), sqlQuery, recipeID)
+	if err != nil {
+		c.Error(apierror.Internal("Error querying the database").WithCause(err))
+		return nil, false
+	}
+	var current []row
+	for rows.Next() {
+		var r row
+		if err := rows.Scan(&r.id, &r.ingredient.IngredientID, &r.ingredient.Quantity, &r.ingredient.Measurement); err != nil {
+			rows.Close()
+			c.Error(apierror.Internal("Error scanning rows").WithCause(err))
+			return nil, false
+		}
+		current = append(current, r)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		c.Error(apierror.Internal("Error querying the database").WithCause(err))
+		return nil, false
+	}
+
+	unpaired := map[int][]row{}
+	for _, r := range current {
+		unpaired[r.ingredient.IngredientID] = append(unpaired[r.ingredient.IngredientID], r)
+	}
+	paired := map[int]bool{}
+	var entries []models.AuditEntry
+	for _, ingredient := range target {
+		candidates := unpaired[ingredient.IngredientID]
+		if len(candidates) == 0 {
+			sqlQuery := `
+				INSERT INTO recipe_ingredients AS t (recipe_id, ingredient_id, quantity, measurement)
+				VALUES ($1, $2, $3, NULLIF($4, ''))
+				RETURNING t.recipe_ingredient_id, row_to_json(t)`
+			added, ok := auditRows(c, tx, audit.ActionCreate, audit.EntityRecipeIngredient, sqlQuery, recipeID, ingredient.IngredientID, ingredient.Quantity, ingredient.Measurement)
+			if !ok {
+				return nil, false
+			}
+			entries = append(entries, added...)
+			continue
+		}
+		r := candidates[0]
+		unpaired[ingredient.IngredientID] = candidates[1:]
+		paired[r.id] = true
+		if r.ingredient == ingredient {
+			continue
+		}
+		sqlQuery := `
+			UPDATE recipe_ingredients AS t SET quantity = $2, measurement = NULLIF($3, '')
+			WHERE t.recipe_ingredient_id = $1
+			RETURNING t.recipe_ingredient_id, row_to_json(t)`
+		updated, ok := auditUpdate(c, tx, audit.EntityRecipeIngredient, r.id, sqlQuery, r.id, ingredient.Quantity, ingredient.Measurement)
+		if !ok {
+			return nil, false
+		}
+		entries = append(entries, updated...)
+	}
+	for _, r := range current {
+		if paired[r.id] {
+			continue
+		}
+		sqlQuery := `DELETE FROM recipe_ingredients t WHERE t.recipe_ingredient_id = $1 RETURNING t.recipe_ingredient_id, row_to_json(t)`
+		removed, ok := auditRows(c, tx, audit.ActionDelete, audit.EntityRecipeIngredient, sqlQuery, r.id)
+		if !ok {
+			return nil, false
+		}
+		entries = append(entries, removed...)
+	}
+	return entries, true
+}
+
+// revertSteps changes the live steps of a recipe to match target, pairing
+// steps by their number as revisions.Diff does. Paired steps whose
+// description differs are updated, unpaired steps deleted and missing steps
+// inserted. It returns the audit entries of the changes, or writes the error
+// response and returns false when the handler should stop.
+func revertSteps(c *gin.Context, tx *sql.Tx, recipeID int, target []models.RevisionStep) ([]models.AuditEntry, bool) {
+	type row struct {
+		id   int
+		step models.RevisionStep
+	}
+	sqlQuery := `
+		SELECT recipe_step_id, step_number, step_description
+		FROM recipe_steps
+		WHERE recipe_id = $1 AND deleted_at IS NULL
+		ORDER BY step_number, recipe_step_id
+		FOR UPDATE`
+	rows, err := tx.QueryContext(c.Request.Context(), sqlQuery, recipeID)
+	if err != nil {
+		c.Error(apierror.Internal("Error querying the database").WithCause(err))
+		return nil, false
+	}
+	var current []row
+	for rows.Next() {
+		var r row
+		if err := rows.Scan(&r.id, &r.step.StepNumber, &r.step.StepDescription); err != nil {
+			rows.Close()
+			c.Error(apierror.Internal("Error scanning rows").WithCause(err))
+			return nil, false
+		}
+		current = append(current, r)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		c.Error(apierror.Internal("Error querying the database").WithCause(err))
+		return nil, false
+	}
+
+	unpaired := map[int][]row{}
+	for _, r := range current {
+		unpaired[r.step.StepNumber] = append(unpaired[r.step.StepNumber], r)
+	}
+	paired := map[int]bool{}
+	var entries []models.AuditEntry
+	for _, step := range target {
+		candidates := unpaired[step.StepNumber]
+		if len(candidates) == 0 {
+			sqlQuery := `
+				INSERT INTO recipe_steps AS t (recipe_id, step_number, step_description)
+				VALUES ($1, $2, $3)
+				RETURNING t.recipe_step_id, row_to_json(t)`
+			added, ok := auditRows(c, tx, audit.ActionCreate, audit.EntityRecipeStep, sqlQuery, recipeID, step.StepNumber, step.StepDescription)
+			if !ok {
+				return nil, false
+			}
+			entries = append(entries, added...)
+			continue
+		}
+		r := candidates[0]
+		unpaired[step.StepNumber] = candidates[1:]
+		paired[r.id] = true
+		if r.step == step {
+			continue
+		}
+		sqlQuery := `
+			UPDATE recipe_steps AS t SET step_description = $2
+			WHERE t.recipe_step_id = $1
+			RETURNING t.recipe_step_id, row_to_json(t)`
+		updated, ok := auditUpdate(c, tx, audit.EntityRecipeStep, r.id, sqlQuery, r.id, step.StepDescription)
+		if !ok {
+			return nil, false
+		}
+		entries = append(entries, updated...)
+	}
+	for _, r := range current {
+		if paired[r.id] {
+			continue
+		}
+		sqlQuery := `DELETE FROM recipe_steps t WHERE t.recipe_step_id = $1 RETURNING t.recipe_step_id, row_to_json(t)`
+		removed, ok := auditRows(c, tx, audit.ActionDelete, audit.EntityRecipeStep, sqlQuery, r.id)
+		if !ok {
+			return nil, false
+		}
+		entries = append(entries, removed...)
+	}
+	return entries, true
+}
+
+// auditUpdate runs a statement updating the entity entityID, as auditRows
+// does, and adds the entity's state before the update to its audit entry.
+func auditUpdate(c *gin.Context, tx *sql.Tx, entityType string, entityID int, sqlQuery string, args ...interface{}) ([]models.AuditEntry, bool) {
+	before, ok := snapshotForAudit(c, tx, entityType, entityID)
+	if !ok {
+		return nil, false
+	}
+	entries, ok := auditRows(c, tx, audit.ActionUpdate, entityType, sqlQuery, args...)
+	for i := range entries {
+		entries[i].Before = before
+	}
+	return entries, ok
+}
+
+// auditRows runs a statement returning the ID and JSON row of each row it
+// inserted, updated or deleted, and describes each of them as an audit entry
+// for action. It writes the error response and returns false when the handler
+// should stop.
+func auditRows(c *gin.Context, tx *sql.Tx, action, entityType, sqlQuery string, args ...interface{}) ([]models.AuditEntry, bool) {
+	rows, err := tx.QueryContext(c.Request.Context(), sqlQuery, args...)
+	if err != nil {
+		c.Error(apierror.FromDB(err, "Error executing SQL statement"))
+		return nil, false
+	}
+	defer rows.Close()
+
+	var entries []models.AuditEntry
+	for rows.Next() {
+		var entityID int
+		var snapshot []byte
+		if err := rows.Scan(&entityID, &snapshot); err != nil {
+			c.Error(apierror.Internal("Error scanning rows").WithCause(err))
+			return nil, false
+		}
+		entry := auditEntry(c, action, entityType, entityID, nil)
+		if action == audit.ActionDelete {
+			entry.Before = snapshot
+		} else {
+			entry.After = snapshot
+		}
+		entries = append(entries, entry)
+	}
+	if err := rows.Err(); err != nil {
+		c.Error(apierror.FromDB(err, "Error executing SQL statement"))
+		return nil, false
+	}
+	return entries, true
+}
@@ -1,15 +1,26 @@
 package controllers
 
 import (
+	"backend/apierror"
+	"backend/audit"
+	"backend/db"
 	"backend/models" // Import your models package where you have your struct definitions
+	"context"
 	"database/sql"
 	"net/http"
 	"strconv"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	_ "github.com/lib/pq" // Import the PostgreSQL driver
 )
 
+// insertRecipeStep adds a step to a recipe, returning its ID and creation time.
+var insertRecipeStep = db.Register(`
+	INSERT INTO recipe_steps (recipe_id, step_number, step_description)
+	VALUES ($1, $2, $3)
+	RETURNING recipe_step_id, created_at`)
+
 // CreateRecipeStep creates a new recipe step.
 // CreateRecipeStep godoc
 // @Summary Create a new recipe step
@@ -18,9 +29,15 @@ import (
 // @Accept json
 // @Produce json
 // @Param recipe_step body models.RecipeStepRequest true "Add recipe step"
+// @Param Idempotency-Key header string false "Unique key making retries of this request replay its first response"
 // @Success 201 {object} models.RecipeStep
-// @Failure 400 {object} map[string]interface{}
-// @Failure 500 {object} map[string]interface{}
+// @Failure 400 {object} apierror.Problem
+// @Failure 401 {object} apierror.Problem
+// @Failure 403 {object} apierror.Problem
+// @Failure 404 {object} apierror.Problem
+// @Failure 409 {object} apierror.Problem "Request with this idempotency key in progress"
+// @Failure 422 {object} apierror.Problem "Validation failed"
+// @Failure 500 {object} apierror.Problem
 // @Router /recipe-steps [post]
 func CreateRecipeStep(c *gin.Context, db *sql.DB) {
 	// 1. Define a variable to hold the recipe step data.
@@ -28,26 +45,38 @@ func CreateRecipeStep(c *gin.Context, db *sql.DB) {
 
 	// 2. Bind the request JSON to the recipe step struct.
 	if err := c.ShouldBindJSON(&recipeStep); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		c.Error(apierror.FromBinding(err))
+		return
+	}
+
+	// Only those who may edit the recipe may add steps to it.
+	if _, ok := authorizeRecipe(c, db, recipeStep.RecipeID, true); !ok {
 		return
 	}
 
 	// 3. Perform validation and save the recipe step to the database.
-	sqlQuery := `
-		INSERT INTO recipe_steps (recipe_id, step_number, step_description)
-		VALUES ($1, $2, $3)
-		RETURNING recipe_step_id`
+	// Apply the change and its audit entry in one transaction.
+	tx, err := db.BeginTx(c.Request.Context(), nil)
+	if err != nil {
+		c.Error(apierror.Internal("Error starting transaction").WithCause(err))
+		return
+	}
+	defer tx.Rollback()
 
-	stmt, err := db.Prepare(sqlQuery)
+	stmt, err := insertRecipeStep.In(c.Request.Context(), tx)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error preparing SQL statement"})
+		c.Error(apierror.Internal("Error preparing SQL statement").WithCause(err))
 		return
 	}
-	defer stmt.Close()
 	var recipeStepID int
-	err = stmt.QueryRow(recipeStep.RecipeID, recipeStep.StepNumber, recipeStep.StepDescription).Scan(&recipeStepID)
+	var createdAt time.Time
+	err = stmt.QueryRowContext(c.Request.Context(), recipeStep.RecipeID, recipeStep.StepNumber, recipeStep.StepDescription).Scan(&recipeStepID, &createdAt)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error executing SQL statement"})
+		c.Error(apierror.FromDB(err, "Error executing SQL statement"))
+		return
+	}
+
+	if !commitAudited(c, tx, audit.ActionCreate, audit.EntityRecipeStep, recipeStepID, nil) {
 		return
 	}
 
@@ -57,11 +86,17 @@ func CreateRecipeStep(c *gin.Context, db *sql.DB) {
 		RecipeID:        recipeStep.RecipeID,
 		StepNumber:      recipeStep.StepNumber,
 		StepDescription: recipeStep.StepDescription,
+		Version:         1,
+		CreatedAt:       createdAt,
+		UpdatedAt:       createdAt,
 	}
 
 	c.JSON(http.StatusCreated, createdRecipeStep)
 }
 
+// deleteRecipeStep deletes a recipe step.
+var deleteRecipeStep = db.Register(`DELETE FROM recipe_steps WHERE recipe_step_id = $1`)
+
 // DeleteRecipeStep deletes a recipe step.
 // DeleteRecipeStep godoc
 // @Summary Delete a recipe step
@@ -70,30 +105,56 @@ func CreateRecipeStep(c *gin.Context, db *sql.DB) {
 // @Accept json
 // @Produce json
 // @Param id path int true "Recipe Step ID"
+// @Param If-Match header string false "ETag of the version being changed; the request fails with 412 if it is outdated"
 // @Success 204 "No Content"
-// @Failure 400 {object} map[string]interface{}
-// @Failure 500 {object} map[string]interface{}
+// @Failure 400 {object} apierror.Problem
+// @Failure 401 {object} apierror.Problem
+// @Failure 403 {object} apierror.Problem
+// @Failure 404 {object} apierror.Problem
+// @Failure 412 {object} apierror.Problem "Resource was modified"
+// @Failure 500 {object} apierror.Problem
 // @Router /recipe-steps/{id} [delete]
 func DeleteRecipeStep(c *gin.Context, db *sql.DB) {
 	// 1. Get the recipe step ID from the URL parameter.
 	recipeStepID, err := strconv.Atoi(c.Param("id"))
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid recipe step ID"})
+		c.Error(apierror.BadRequest("Invalid recipe step ID"))
+		return
+	}
+
+	// Only those who may edit the recipe may remove its steps.
+	if !authorizeRecipeStepEdit(c, db, recipeStepID) {
 		return
 	}
 
 	// 2. Perform validation and delete the recipe step from the database.
-	sqlQuery := `DELETE FROM recipe_steps WHERE recipe_step_id = $1`
+	// Apply the change and its audit entry in one transaction.
+	tx, err := db.BeginTx(c.Request.Context(), nil)
+	if err != nil {
+		c.Error(apierror.Internal("Error starting transaction").WithCause(err))
+		return
+	}
+	defer tx.Rollback()
+	before, ok := snapshotForAudit(c, tx, audit.EntityRecipeStep, recipeStepID)
+	if !ok {
+		return
+	}
+	if !checkIfMatch(c, tx, "recipe_steps", "recipe_step_id", recipeStepID) {
+		return
+	}
 
-	stmt, err := db.Prepare(sqlQuery)
+	stmt, err := deleteRecipeStep.In(c.Request.Context(), tx)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error preparing SQL statement"})
+		c.Error(apierror.Internal("Error preparing SQL statement").WithCause(err))
 		return
 	}
-	defer stmt.Close()
-	_, err = stmt.Exec(recipeStepID)
+	_, err = stmt.ExecContext(c.Request.Context(), recipeStepID)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error executing SQL statement"})
+		c.Error(apierror.FromDB(err, "Error executing SQL statement"))
+		return
+	}
+
+	if !commitAudited(c, tx, audit.ActionDelete, audit.EntityRecipeStep, recipeStepID, before) {
 		return
 	}
 
@@ -101,6 +162,59 @@ func DeleteRecipeStep(c *gin.Context, db *sql.DB) {
 	c.JSON(http.StatusNoContent, nil)
 }
 
+// GetRecipeSteps returns all recipe steps.
+// GetRecipeSteps godoc
+// @Summary Get all recipe steps
+// @Description Get all recipe steps of the recipes the caller may see
+// @Tags recipe_steps
+// @Accept json
+// @Produce json
+// @Param updated_since query string false "Only rows changed at or after this RFC 3339 time"
+// @Param sort query string false "Sort by created_at or updated_at; prefix with - for newest first" Enums(created_at, -created_at, updated_at, -updated_at)
+// @Param If-None-Match header string false "ETag held by the client; unchanged resources return 304"
+// @Success 200 {array} models.RecipeStep
+// @Success 304 "Not Modified"
+// @Failure 400 {object} apierror.Problem "Invalid filter or sort"
+// @Failure 500 {object} apierror.Problem
+// @Router /recipe-steps [get]
+func GetRecipeSteps(c *gin.Context, db *sql.DB) {
+	// 1. Define a variable to hold the recipe steps.
+	var recipeSteps []models.RecipeStep
+
+	// 2. Query the database for all steps of visible recipes.
+	opts, ok := parseListOptions(c, "rs", "recipe_step_id", "rs.recipe_id, rs.step_number")
+	if !ok {
+		return
+	}
+	who := callerOf(c)
+	sqlQuery := `
+		SELECT rs.recipe_step_id, rs.recipe_id, rs.step_number, rs.step_description, rs.version, rs.created_at, rs.updated_at
+		FROM recipe_steps rs
+		JOIN recipes r ON r.recipe_id = rs.recipe_id
+		WHERE rs.deleted_at IS NULL AND ` + recipeViewable("$1", "$2") + ` AND ` + updatedSince("rs", "$3") + `
+		ORDER BY ` + opts.orderBy
+	rows, err := db.QueryContext(c.Request.Context(), sqlQuery, who.userID, who.householdID, opts.updatedSince)
+	if err != nil {
+		c.Error(apierror.Internal("Error querying the database").WithCause(err))
+		return
+	}
+	defer rows.Close()
+
+	// 3. Iterate over the rows and add each recipe step to the slice.
+	for rows.Next() {
+		var recipeStep models.RecipeStep
+		err := rows.Scan(&recipeStep.RecipeStepID, &recipeStep.RecipeID, &recipeStep.StepNumber, &recipeStep.StepDescription, &recipeStep.Version, &recipeStep.CreatedAt, &recipeStep.UpdatedAt)
+		if err != nil {
+			c.Error(apierror.Internal("Error scanning rows").WithCause(err))
+			return
+		}
+		recipeSteps = append(recipeSteps, recipeStep)
+	}
+
+	// 4. Return a JSON response with the recipe steps.
+	respondWithListETag(c, recipeSteps)
+}
+
 // GetRecipeStep retrieves a single recipe step by ID.
 // GetRecipeStep godoc
 // @Summary Get a recipe step by ID
@@ -109,33 +223,51 @@ func DeleteRecipeStep(c *gin.Context, db *sql.DB) {
 // @Accept json
 // @Produce json
 // @Param id path int true "Recipe Step ID"
+// @Param If-None-Match header string false "ETag held by the client; unchanged resources return 304"
 // @Success 200 {object} models.RecipeStep
-// @Failure 400 {object} map[string]interface{}
-// @Failure 404 {object} map[string]interface{}
-// @Failure 500 {object} map[string]interface{}
+// @Success 304 "Not Modified"
+// @Failure 400 {object} apierror.Problem
+// @Failure 404 {object} apierror.Problem
+// @Failure 500 {object} apierror.Problem
 // @Router /recipe-steps/{id} [get]
 func GetRecipeStep(c *gin.Context, db *sql.DB) {
 	// 1. Get the recipe step ID from the URL parameter.
 	recipeStepID, err := strconv.Atoi(c.Param("id"))
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid recipe step ID"})
+		c.Error(apierror.BadRequest("Invalid recipe step ID"))
 		return
 	}
 
 	// 2. Fetch the recipe step from the database by ID.
-	sqlQuery := `SELECT recipe_step_id, recipe_id, step_number, step_description FROM recipe_steps WHERE recipe_step_id = $1`
+	sqlQuery := `SELECT recipe_step_id, recipe_id, step_number, step_description, version, created_at, updated_at FROM recipe_steps WHERE recipe_step_id = $1 AND deleted_at IS NULL`
 
 	var recipeStep models.RecipeStep
-	err = db.QueryRow(sqlQuery, recipeStepID).Scan(&recipeStep.RecipeStepID, &recipeStep.RecipeID, &recipeStep.StepNumber, &recipeStep.StepDescription)
+	err = db.QueryRowContext(c.Request.Context(), sqlQuery, recipeStepID).Scan(&recipeStep.RecipeStepID, &recipeStep.RecipeID, &recipeStep.StepNumber, &recipeStep.StepDescription, &recipeStep.Version, &recipeStep.CreatedAt, &recipeStep.UpdatedAt)
+	if err == sql.ErrNoRows {
+		c.Error(apierror.NotFound("Recipe step not found"))
+		return
+	}
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Recipe step not found"})
+		c.Error(apierror.FromDB(err, "Error fetching recipe step"))
+		return
+	}
+
+	// The step is only visible to those who may see its recipe.
+	if _, ok := authorizeRecipe(c, db, recipeStep.RecipeID, false); !ok {
 		return
 	}
 
-	// 3. Return a JSON response with the recipe step.
-	c.JSON(http.StatusOK, recipeStep)
+	// 3. Return a JSON response with the recipe step, tagged with its version.
+	respondWithETag(c, etag(recipeStep.Version), recipeStep)
 }
 
+// updateRecipeStep replaces the fields of a recipe step, returning its version and timestamps.
+var updateRecipeStep = db.Register(`
+	UPDATE recipe_steps
+	SET recipe_id = $1, step_number = $2, step_description = $3
+	WHERE recipe_step_id = $4
+	RETURNING version, created_at, updated_at`)
+
 // UpdateRecipeStep updates a recipe step by ID.
 // UpdateRecipeStep godoc
 // @Summary Update a recipe step
@@ -144,44 +276,71 @@ func GetRecipeStep(c *gin.Context, db *sql.DB) {
 // @Accept json
 // @Produce json
 // @Param id path int true "Recipe Step ID"
-// @Param recipe_step body models.RecipeStep true "Update recipe step"
+// @Param recipe_step body models.RecipeStepRequest true "Update recipe step"
+// @Param If-Match header string false "ETag of the version being changed; the request fails with 412 if it is outdated"
 // @Success 200 {object} models.RecipeStep
-// @Failure 400 {object} map[string]interface{}
-// @Failure 404 {object} map[string]interface{}
-// @Failure 500 {object} map[string]interface{}
+// @Failure 400 {object} apierror.Problem
+// @Failure 401 {object} apierror.Problem
+// @Failure 403 {object} apierror.Problem
+// @Failure 404 {object} apierror.Problem
+// @Failure 422 {object} apierror.Problem "Validation failed"
+// @Failure 412 {object} apierror.Problem "Resource was modified"
+// @Failure 500 {object} apierror.Problem
 // @Router /recipe-steps/{id} [put]
 func UpdateRecipeStep(c *gin.Context, db *sql.DB) {
 	// 1. Get the recipe step ID from the URL parameter.
 	recipeStepID, err := strconv.Atoi(c.Param("id"))
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid recipe step ID"})
+		c.Error(apierror.BadRequest("Invalid recipe step ID"))
+		return
+	}
+
+	// The caller must be able to edit both the current and the target recipe.
+	if !authorizeRecipeStepEdit(c, db, recipeStepID) {
 		return
 	}
 
 	// 2. Define a variable to hold the recipe step data.
-	var recipeStep models.RecipeStep
+	var recipeStep models.RecipeStepRequest
 
-	// 3. Bind the request JSON to the recipe step struct.
-	if err := c.ShouldBindJSON(&recipeStep); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	// 3. Bind the request JSON to the recipe step struct; patches apply to the stored step.
+	if !bindUpdate(c, &recipeStep, func() error { return loadRecipeStepRequest(c.Request.Context(), db, recipeStepID, &recipeStep) }) {
+		return
+	}
+	if _, ok := authorizeRecipe(c, db, recipeStep.RecipeID, true); !ok {
 		return
 	}
 
 	// 4. Perform validation and update the recipe step in the database.
-	sqlQuery := `
-		UPDATE recipe_steps
-		SET recipe_id = $1, step_number = $2, step_description = $3
-		WHERE recipe_step_id = $4`
+	// Apply the change and its audit entry in one transaction.
+	tx, err := db.BeginTx(c.Request.Context(), nil)
+	if err != nil {
+		c.Error(apierror.Internal("Error starting transaction").WithCause(err))
+		return
+	}
+	defer tx.Rollback()
+	before, ok := snapshotForAudit(c, tx, audit.EntityRecipeStep, recipeStepID)
+	if !ok {
+		return
+	}
+	if !checkIfMatch(c, tx, "recipe_steps", "recipe_step_id", recipeStepID) {
+		return
+	}
 
-	stmt, err := db.Prepare(sqlQuery)
+	stmt, err := updateRecipeStep.In(c.Request.Context(), tx)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error preparing SQL statement"})
+		c.Error(apierror.Internal("Error preparing SQL statement").WithCause(err))
 		return
 	}
-	defer stmt.Close()
-	_, err = stmt.Exec(recipeStep.RecipeID, recipeStep.StepNumber, recipeStep.StepDescription, recipeStepID)
+	var version int
+	var createdAt, updatedAt time.Time
+	err = stmt.QueryRowContext(c.Request.Context(), recipeStep.RecipeID, recipeStep.StepNumber, recipeStep.StepDescription, recipeStepID).Scan(&version, &createdAt, &updatedAt)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error executing SQL statement"})
+		c.Error(apierror.FromDB(err, "Error executing SQL statement"))
+		return
+	}
+
+	if !commitAudited(c, tx, audit.ActionUpdate, audit.EntityRecipeStep, recipeStepID, before) {
 		return
 	}
 
@@ -191,7 +350,59 @@ func UpdateRecipeStep(c *gin.Context, db *sql.DB) {
 		RecipeID:        recipeStep.RecipeID,
 		StepNumber:      recipeStep.StepNumber,
 		StepDescription: recipeStep.StepDescription,
+		Version:         version,
+		CreatedAt:       createdAt,
+		UpdatedAt:       updatedAt,
 	}
 
+	c.Header("ETag", etag(version))
 	c.JSON(http.StatusOK, updatedRecipeStep)
 }
+
+// PatchRecipeStep partially updates a recipe step by ID.
+// PatchRecipeStep godoc
+// @Summary Partially update a recipe step
+// @Description Apply a JSON Merge Patch (RFC 7396) or JSON Patch (RFC 6902) to a recipe step; only the supplied fields change
+// @Tags recipe_steps
+// @Accept application/merge-patch+json,application/json-patch+json
+// @Produce json
+// @Param id path int true "Recipe Step ID"
+// @Param patch body models.RecipeStepRequest true "Fields to change"
+// @Param If-Match header string false "ETag of the version being changed; the request fails with 412 if it is outdated"
+// @Success 200 {object} models.RecipeStep
+// @Failure 400 {object} apierror.Problem "Invalid recipe step ID or patch document"
+// @Failure 401 {object} apierror.Problem "Authentication required"
+// @Failure 403 {object} apierror.Problem "Not allowed to modify the recipe"
+// @Failure 404 {object} apierror.Problem "Recipe step not found"
+// @Failure 409 {object} apierror.Problem "JSON Patch test failed"
+// @Failure 415 {object} apierror.Problem "Unsupported patch format"
+// @Failure 422 {object} apierror.Problem "Validation failed"
+// @Failure 412 {object} apierror.Problem "Resource was modified"
+// @Failure 500 {object} apierror.Problem "Internal server error"
+// @Router /recipe-steps/{id} [patch]
+func PatchRecipeStep(c *gin.Context, db *sql.DB) {
+	UpdateRecipeStep(c, db)
+}
+
+// loadRecipeStepRequest fills req with the stored values of a recipe step.
+func loadRecipeStepRequest(ctx context.Context, db *sql.DB, recipeStepID int, req *models.RecipeStepRequest) error {
+	sqlQuery := `SELECT recipe_step_id, recipe_id, step_number, step_description FROM recipe_steps WHERE recipe_step_id = $1 AND deleted_at IS NULL`
+	return db.QueryRowContext(ctx, sqlQuery, recipeStepID).Scan(&req.RecipeStepID, &req.RecipeID, &req.StepNumber, &req.StepDescription)
+}
+
+// authorizeRecipeStepEdit checks that the caller may edit the recipe a recipe
+// step belongs to. It returns false once a response has been written.
+func authorizeRecipeStepEdit(c *gin.Context, db *sql.DB, recipeStepID int) bool {
+	recipeID, err := parentRecipeID(c.Request.Context(), db, "recipe_steps", "recipe_step_id", recipeStepID)
+	if err == sql.ErrNoRows {
+		c.Error(apierror.NotFound("Recipe step not found"))
+		return false
+	}
+	if err != nil {
+		c.Error(apierror.Internal("Error fetching recipe step").WithCause(err))
+		return false
+	}
+
+	_, ok := authorizeRecipe(c, db, recipeID, true)
+	return ok
+}
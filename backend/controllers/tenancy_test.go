@@ -0,0 +1,136 @@
+package controllers_test
+
+import (
+	"backend/apitest"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// newTenant returns a new user acting in a household of their own.
+func newTenant(tb testing.TB, router *gin.Engine) apitest.Caller {
+	tb.Helper()
+	caller := apitest.NewCaller()
+	var household struct {
+		HouseholdID int `json:"household_id"`
+	}
+	caller.Must(tb, router, http.MethodPost, "/households", map[string]string{"household_name": fmt.Sprintf("Household of %d", caller.UserID)}, http.StatusCreated, &household)
+	caller.HouseholdID = household.HouseholdID
+	return caller
+}
+
+func TestTenantIsolation(t *testing.T) {
+	database := apitest.DB(t)
+	router := apitest.Router(t, database)
+	owner := newTenant(t, router)
+	other := newTenant(t, router)
+
+	// The owner's household holds a recipe with an ingredient and a step, and
+	// a recipe and an ingredient in the trash. Public recipes stay within
+	// their household too.
+	var recipe, trashedRecipe struct {
+		RecipeID int `json:"recipe_id"`
+	}
+	var ingredient, trashedIngredient struct {
+		IngredientID int `json:"ingredient_id"`
+	}
+	var recipeIngredient struct {
+		RecipeIngredientID int `json:"recipe_ingredient_id"`
+	}
+	var step struct {
+		RecipeStepID int `json:"recipe_step_id"`
+	}
+	owner.Must(t, router, http.MethodPost, "/recipes", map[string]interface{}{"recipe_name": "Soup", "cook_time": 30, "visibility": "public"}, http.StatusCreated, &recipe)
+	owner.Must(t, router, http.MethodPost, "/recipes", map[string]interface{}{"recipe_name": "Stew", "cook_time": 60}, http.StatusCreated, &trashedRecipe)
+	owner.Must(t, router, http.MethodPost, "/ingredients", map[string]string{"ingredient_name": "Leek"}, http.StatusCreated, &ingredient)
+	owner.Must(t, router, http.MethodPost, "/ingredients", map[string]string{"ingredient_name": "Turnip"}, http.StatusCreated, &trashedIngredient)
+	owner.Must(t, router, http.MethodPost, "/recipe-ingredients", map[string]interface{}{"recipe_id": recipe.RecipeID, "ingredient_id": ingredient.IngredientID, "quantity": 2, "measurement": "g"}, http.StatusCreated, &recipeIngredient)
+	owner.Must(t, router, http.MethodPost, "/recipe-steps", map[string]interface{}{"recipe_id": recipe.RecipeID, "step_number": 1, "step_description": "Simmer"}, http.StatusCreated, &step)
+	owner.Must(t, router, http.MethodPut, fmt.Sprintf("/recipes/%d", recipe.RecipeID), map[string]interface{}{"recipe_name": "Leek soup", "cook_time": 30, "visibility": "public"}, http.StatusOK, nil)
+	owner.Must(t, router, http.MethodDelete, fmt.Sprintf("/recipes/%d", trashedRecipe.RecipeID), nil, http.StatusNoContent, nil)
+	owner.Must(t, router, http.MethodDelete, fmt.Sprintf("/ingredients/%d", trashedIngredient.IngredientID), nil, http.StatusNoContent, nil)
+
+	recipePath := fmt.Sprintf("/recipes/%d", recipe.RecipeID)
+	ingredientPath := fmt.Sprintf("/ingredients/%d", ingredient.IngredientID)
+	recipeIngredientPath := fmt.Sprintf("/recipe-ingredients/%d", recipeIngredient.RecipeIngredientID)
+	stepPath := fmt.Sprintf("/recipe-steps/%d", step.RecipeStepID)
+	recipeBody := map[string]interface{}{"recipe_name": "Taken", "cook_time": 1}
+	ingredientBody := map[string]string{"ingredient_name": "Taken"}
+	recipeIngredientBody := map[string]interface{}{"recipe_id": recipe.RecipeID, "ingredient_id": ingredient.IngredientID, "quantity": 1, "measurement": "g"}
+	stepBody := map[string]interface{}{"recipe_id": recipe.RecipeID, "step_number": 2, "step_description": "Taken"}
+
+	tests := []struct {
+		method string
+		path   string
+		body   interface{}
+	}{
+		{http.MethodGet, recipePath, nil},
+		{http.MethodPut, recipePath, recipeBody},
+		{http.MethodPatch, recipePath, map[string]string{"recipe_name": "Taken"}},
+		{http.MethodPost, recipePath + "/fork", nil},
+		{http.MethodGet, recipePath + "/revisions", nil},
+		{http.MethodGet, recipePath + "/revisions/1", nil},
+		{http.MethodGet, recipePath + "/revisions/1/diff/2", nil},
+		{http.MethodPost, recipePath + "/revisions/1/revert", nil},
+		{http.MethodPost, fmt.Sprintf("/recipes/%d/restore", trashedRecipe.RecipeID), nil},
+		{http.MethodGet, ingredientPath, nil},
+		{http.MethodPut, ingredientPath, ingredientBody},
+		{http.MethodPost, fmt.Sprintf("/ingredients/%d/restore", trashedIngredient.IngredientID), nil},
+		{http.MethodPost, "/recipe-ingredients", recipeIngredientBody},
+		{http.MethodGet, recipeIngredientPath, nil},
+		{http.MethodPut, recipeIngredientPath, recipeIngredientBody},
+		{http.MethodPost, "/recipe-steps", stepBody},
+		{http.MethodGet, stepPath, nil},
+		{http.MethodPut, stepPath, stepBody},
+		// Deletes go last, so that the requests above find the rows in place.
+		{http.MethodDelete, recipeIngredientPath, nil},
+		{http.MethodDelete, stepPath, nil},
+		{http.MethodDelete, ingredientPath, nil},
+		{http.MethodDelete, recipePath, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.method+" "+tt.path, func(t *testing.T) {
+			if w := other.Do(t, router, tt.method, tt.path, tt.body); w.Code != http.StatusNotFound {
+				t.Errorf("status = %d, want %d: %s", w.Code, http.StatusNotFound, w.Body.String())
+			}
+		})
+	}
+
+	t.Run("lists", func(t *testing.T) {
+		var recipes []struct {
+			RecipeID int `json:"recipe_id"`
+		}
+		var ingredients []struct {
+			IngredientID int `json:"ingredient_id"`
+		}
+		var trash []struct {
+			EntityType string `json:"entity_type"`
+			EntityID   int    `json:"entity_id"`
+		}
+		other.Must(t, router, http.MethodGet, "/recipes", nil, http.StatusOK, &recipes)
+		other.Must(t, router, http.MethodGet, "/ingredients", nil, http.StatusOK, &ingredients)
+		other.Must(t, router, http.MethodGet, "/trash", nil, http.StatusOK, &trash)
+		for _, r := range recipes {
+			if r.RecipeID == recipe.RecipeID {
+				t.Errorf("GET /recipes lists recipe %d of another household", r.RecipeID)
+			}
+		}
+		for _, i := range ingredients {
+			if i.IngredientID == ingredient.IngredientID {
+				t.Errorf("GET /ingredients lists ingredient %d of another household", i.IngredientID)
+			}
+		}
+		for _, item := range trash {
+			if item.EntityID == trashedRecipe.RecipeID || item.EntityID == trashedIngredient.IngredientID {
+				t.Errorf("GET /trash lists %s %d of another household", item.EntityType, item.EntityID)
+			}
+		}
+	})
+
+	// None of the requests above reached the owner's data.
+	for _, path := range []string{recipePath, ingredientPath, recipeIngredientPath, stepPath} {
+		owner.Must(t, router, http.MethodGet, path, nil, http.StatusOK, nil)
+	}
+}
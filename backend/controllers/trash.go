@@ -0,0 +1,439 @@
+package controllers
+
+import (
+	"backend/apierror"
+	"backend/audit"
+	"backend/auth"
+	"backend/middleware"
+	"backend/models"
+	"backend/policy"
+	"backend/tracing"
+	"context"
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// TrashRetention is how long deleted recipes and ingredients stay in the
+// trash before they are purged, configured at startup.
+var TrashRetention = 30 * 24 * time.Hour
+
+// trashDependent describes rows that reference a trashable entity.
+type trashDependent struct {
+	entityType, table, idColumn, column string
+}
+
+// trashable describes an entity that is moved to the trash when deleted.
+type trashable struct {
+	entityType, table, idColumn string
+	// dependents trashed along with the entity are purged with it.
+	dependents []trashDependent
+	// cleanup statements run before the entity is purged, with its ID as $1.
+	cleanup []string
+}
+
+// Entities kept in the trash, in the order they are purged.
+var trashables = []trashable{
+	{
+		entityType: audit.EntityRecipe, table: "recipes", idColumn: "recipe_id",
+		dependents: []trashDependent{
+			{audit.EntityRecipeIngredient, "recipe_ingredients", "recipe_ingredient_id", "recipe_id"},
+			{audit.EntityRecipeStep, "recipe_steps", "recipe_step_id", "recipe_id"},
+		},
+		cleanup: []string{
+			`DELETE FROM recipe_collaborators WHERE recipe_id = $1`,
+			`DELETE FROM recipe_revisions WHERE recipe_id = $1`,
+			// Forks outlive the recipe they were copied from.
+			`UPDATE recipes SET forked_from = NULL WHERE forked_from = $1`,
+		},
+	},
+	{
+		entityType: audit.EntityIngredient, table: "ingredients", idColumn: "ingredient_id",
+		dependents: []trashDependent{
+			{audit.EntityRecipeIngredient, "recipe_ingredients", "recipe_ingredient_id", "ingredient_id"},
+		},
+		// Approved proposals keep their history without pointing at the ingredient.
+		cleanup: []string{`UPDATE ingredient_proposals SET ingredient_id = NULL WHERE ingredient_id = $1`},
+	},
+}
+
+// curatesCatalog reports whether the policy lets the caller manage the shared
+// ingredient catalog. Only curators see and restore catalog ingredients in the trash.
+func curatesCatalog(c *gin.Context) bool {
+	return middleware.Can(c, policy.ResourceIngredients, policy.ActionDelete)
+}
+
+// GetTrash lists the deleted recipes and ingredients the caller can restore.
+// GetTrash godoc
+// @Summary Get the trash
+// @Description Get the deleted recipes the caller owns and the deleted ingredients of the current household, most recently deleted first. Catalog ingredients are listed to catalog curators only
+// @Tags trash
+// @Accept json
+// @Produce json
+// @Success 200 {array} models.TrashItem
+// @Failure 401 {object} apierror.Problem
+// @Failure 403 {object} apierror.Problem
+// @Failure 500 {object} apierror.Problem
+// @Router /trash [get]
+func GetTrash(c *gin.Context, db *sql.DB) {
+	// 1. Query the database for the caller's trashed recipes and ingredients.
+	if _, ok := middleware.RequireIdentity(c); !ok {
+		return
+	}
+	who := callerOf(c)
+	sqlQuery := `
+		SELECT 'recipe', r.recipe_id, r.recipe_name, r.deleted_at
+		FROM recipes r
+		WHERE r.deleted_at IS NOT NULL AND ` + inTenant("r", "$2") + ` AND r.owner_id = $1
+		UNION ALL
+		SELECT 'ingredient', i.ingredient_id, i.ingredient_name, i.deleted_at
+		FROM ingredients i
+		WHERE i.deleted_at IS NOT NULL AND ` + inTenant("i", "$2") + ` AND (i.household_id IS NOT NULL OR $3)
+		ORDER BY 4 DESC`
+	rows, err := db.QueryContext(c.Request.Context(), sqlQuery, who.userID, who.householdID, curatesCatalog(c))
+	if err != nil {
+		c.Error(apierror.Internal("Error querying the database").WithCause(err))
+		return
+	}
+	defer rows.Close()
+
+	// 2. Iterate over the rows and add each item to the slice.
+	items := []models.TrashItem{}
+	for rows.Next() {
+		var item models.TrashItem
+		if err := rows.Scan(&item.EntityType, &item.EntityID, &item.Name, &item.DeletedAt); err != nil {
+			c.Error(apierror.Internal("Error scanning rows").WithCause(err))
+			return
+		}
+		item.PurgeAt = item.DeletedAt.Add(TrashRetention)
+		items = append(items, item)
+	}
+
+	// 3. Return a JSON response with the items.
+	c.JSON(http.StatusOK, items)
+}
+
+// RestoreRecipe takes a recipe out of the trash by ID.
+// RestoreRecipe godoc
+// @Summary Restore a deleted recipe
+// @Description Take a recipe out of the trash, together with the ingredients and steps deleted along with it
+// @Tags trash
+// @Accept json
+// @Produce json
+// @Param id path int true "Recipe ID"
+// @Param Idempotency-Key header string false "Unique key making retries of this request replay its first response"
+// @Success 200 {object} models.Recipe
+// @Failure 400 {object} apierror.Problem
+// @Failure 401 {object} apierror.Problem
+// @Failure 403 {object} apierror.Problem
+// @Failure 404 {object} apierror.Problem "Recipe not in the trash"
+// @Failure 409 {object} apierror.Problem "Request with this idempotency key in progress"
+// @Failure 422 {object} apierror.Problem "Idempotency key reused for a different request"
+// @Failure 500 {object} apierror.Problem
+// @Router /recipes/{id}/restore [post]
+func RestoreRecipe(c *gin.Context, db *sql.DB) {
+	// 1. Get the recipe ID from the URL parameter.
+	recipeID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.Error(apierror.BadRequest("Invalid recipe ID"))
+		return
+	}
+
+	userID, ok := middleware.RequireIdentity(c)
+	if !ok {
+		return
+	}
+	who := callerOf(c)
+	if !requireWriter(c, who) {
+		return
+	}
+
+	// 2. Find the recipe in the caller's trash, locking it for the restore.
+	tx, err := db.BeginTx(c.Request.Context(), nil)
+	if err != nil {
+		c.Error(apierror.Internal("Error starting transaction").WithCause(err))
+		return
+	}
+	defer tx.Rollback()
+
+	sqlQuery := `
+		SELECT r.owner_id, r.deleted_at
+		FROM recipes r
+		WHERE r.recipe_id = $1 AND r.deleted_at IS NOT NULL AND ` + inTenant("r", "$2") + `
+		FOR UPDATE`
+	var ownerID sql.NullInt64
+	var deletedAt time.Time
+	err = tx.QueryRowContext(c.Request.Context(), sqlQuery, recipeID, who.householdID).Scan(&ownerID, &deletedAt)
+	if err == sql.ErrNoRows {
+		c.Error(apierror.NotFound("Recipe not found in the trash"))
+		return
+	}
+	if err != nil {
+		c.Error(apierror.Internal("Error querying the database").WithCause(err))
+		return
+	}
+	if !ownerID.Valid || int(ownerID.Int64) != userID {
+		c.Error(apierror.Forbidden("Only the recipe owner can perform this action"))
+		return
+	}
+
+	// 3. Restore the recipe and what was deleted along with it.
+	before, ok := snapshotForAudit(c, tx, audit.EntityRecipe, recipeID)
+	if !ok {
+		return
+	}
+	sqlQuery = `
+		UPDATE recipes SET deleted_at = NULL
+		WHERE recipe_id = $1
+		RETURNING recipe_id, recipe_name, recipe_description, cook_time, owner_id, household_id, visibility, version, created_at, updated_at, forked_from`
+	var recipe models.Recipe
+	err = tx.QueryRowContext(c.Request.Context(), sqlQuery, recipeID).
+		Scan(&recipe.RecipeID, &recipe.RecipeName, &recipe.RecipeDescription, &recipe.CookTime, &recipe.OwnerID, &recipe.HouseholdID, &recipe.Visibility, &recipe.Version, &recipe.CreatedAt, &recipe.UpdatedAt, &recipe.ForkedFrom)
+	if err != nil {
+		c.Error(apierror.FromDB(err, "Error restoring recipe"))
+		return
+	}
+	if !restoreDependents(c, tx, audit.EntityRecipeIngredient, "recipe_ingredients", "recipe_ingredient_id", "recipe_id", recipeID, deletedAt) ||
+		!restoreDependents(c, tx, audit.EntityRecipeStep, "recipe_steps", "recipe_step_id", "recipe_id", recipeID, deletedAt) {
+		return
+	}
+
+	if !commitAudited(c, tx, audit.ActionRestore, audit.EntityRecipe, recipeID, before) {
+		return
+	}
+
+	// 4. Return a JSON response with the restored recipe.
+	c.Header("ETag", etag(recipe.Version))
+	c.JSON(http.StatusOK, recipe)
+}
+
+// RestoreIngredient takes an ingredient out of the trash by ID.
+// RestoreIngredient godoc
+// @Summary Restore a deleted ingredient
+// @Description Take an ingredient out of the trash, together with the recipe ingredients deleted along with it
+// @Tags trash
+// @Accept json
+// @Produce json
+// @Param id path int true "Ingredient ID"
+// @Param Idempotency-Key header string false "Unique key making retries of this request replay its first response"
+// @Success 200 {object} models.Ingredient
+// @Failure 400 {object} apierror.Problem
+// @Failure 403 {object} apierror.Problem
+// @Failure 404 {object} apierror.Problem "Ingredient not in the trash"
+// @Failure 409 {object} apierror.Problem "Request with this idempotency key in progress"
+// @Failure 422 {object} apierror.Problem "Idempotency key reused for a different request"
+// @Failure 500 {object} apierror.Problem
+// @Router /ingredients/{id}/restore [post]
+func RestoreIngredient(c *gin.Context, db *sql.DB) {
+	// 1. Get the ingredient ID from the URL parameter.
+	ingredientID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.Error(apierror.BadRequest("Invalid ingredient ID"))
+		return
+	}
+
+	// Only ingredients of the caller's own tenant can be restored, and catalog
+	// ingredients only by curators.
+	who := callerOf(c)
+	if !requireWriter(c, who) {
+		return
+	}
+
+	// 2. Find the ingredient in the tenant's trash, locking it for the restore.
+	tx, err := db.BeginTx(c.Request.Context(), nil)
+	if err != nil {
+		c.Error(apierror.Internal("Error starting transaction").WithCause(err))
+		return
+	}
+	defer tx.Rollback()
+
+	sqlQuery := `
+		SELECT i.deleted_at
+		FROM ingredients i
+		WHERE i.ingredient_id = $1 AND i.deleted_at IS NOT NULL AND ` + inTenant("i", "$2") + ` AND (i.household_id IS NOT NULL OR $3)
+		FOR UPDATE`
+	var deletedAt time.Time
+	err = tx.QueryRowContext(c.Request.Context(), sqlQuery, ingredientID, who.householdID, curatesCatalog(c)).Scan(&deletedAt)
+	if err == sql.ErrNoRows {
+		c.Error(apierror.NotFound("Ingredient not found in the trash"))
+		return
+	}
+	if err != nil {
+		c.Error(apierror.Internal("Error querying the database").WithCause(err))
+		return
+	}
+
+	// 3. Restore the ingredient and the recipe ingredients deleted along with it.
+	before, ok := snapshotForAudit(c, tx, audit.EntityIngredient, ingredientID)
+	if !ok {
+		return
+	}
+	sqlQuery = `
+		UPDATE ingredients SET deleted_at = NULL
+		WHERE ingredient_id = $1
+		RETURNING ingredient_id, ingredient_name, COALESCE(ingredient_description, ''), owner_id, household_id, version, created_at, updated_at`
+	var ingredient models.Ingredient
+	err = tx.QueryRowContext(c.Request.Context(), sqlQuery, ingredientID).
+		Scan(&ingredient.IngredientID, &ingredient.IngredientName, &ingredient.IngredientDescription, &ingredient.OwnerID, &ingredient.HouseholdID, &ingredient.Version, &ingredient.CreatedAt, &ingredient.UpdatedAt)
+	if err != nil {
+		c.Error(apierror.FromDB(err, "Error restoring ingredient"))
+		return
+	}
+	if !restoreDependents(c, tx, audit.EntityRecipeIngredient, "recipe_ingredients", "recipe_ingredient_id", "ingredient_id", ingredientID, deletedAt) {
+		return
+	}
+
+	if !commitAudited(c, tx, audit.ActionRestore, audit.EntityIngredient, ingredientID, before) {
+		return
+	}
+
+	// 4. Return a JSON response with the restored ingredient.
+	c.Header("ETag", etag(ingredient.Version))
+	c.JSON(http.StatusOK, ingredient)
+}
+
+// RunTrashPurge purges the trash every interval until ctx is done.
+func RunTrashPurge(ctx context.Context, db *sql.DB, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		runCtx, span := tracing.Start(ctx, "trash purge")
+		if purged, err := PurgeTrash(runCtx, db); err != nil {
+			slog.Error("trash purge failed", "error", err)
+		} else if purged > 0 {
+			slog.Info("trash purge removed items", "count", purged)
+		}
+		span.End()
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// PurgeTrash permanently deletes the recipes and ingredients that have been in
+// the trash for longer than TrashRetention, along with their dependents, and
+// returns how many were removed. Items that cannot be removed yet, such as
+// ingredients still used by recipes in the trash, are left for a later purge.
+// Rows referencing an item that were not trashed along with it keep it in the
+// trash too. The audit entries of a purge are recorded for audit.SystemActorID
+// under a job ID shared by the whole purge, in place of a request ID.
+func PurgeTrash(ctx context.Context, db *sql.DB) (int, error) {
+	cutoff := time.Now().Add(-TrashRetention)
+	purged := 0
+
+	token, err := auth.NewToken()
+	if err != nil {
+		return purged, fmt.Errorf("error generating job ID: %v", err)
+	}
+	jobID := "trash-purge-" + token[:32]
+
+	for _, t := range trashables {
+		sqlQuery := fmt.Sprintf(`SELECT %s FROM %s WHERE deleted_at < $1`, t.idColumn, t.table)
+		rows, err := db.QueryContext(ctx, sqlQuery, cutoff)
+		if err != nil {
+			return purged, fmt.Errorf("error listing expired %s: %v", t.table, err)
+		}
+		var ids []int
+		for rows.Next() {
+			var id int
+			if err := rows.Scan(&id); err != nil {
+				rows.Close()
+				return purged, fmt.Errorf("error scanning expired %s: %v", t.table, err)
+			}
+			ids = append(ids, id)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return purged, fmt.Errorf("error listing expired %s: %v", t.table, err)
+		}
+
+		for _, id := range ids {
+			if err := purgeOne(ctx, db, t, id, jobID); err != nil {
+				slog.Warn("trash purge skipped item", "job_id", jobID, "entity_type", t.entityType, "entity_id", id, "error", err)
+				continue
+			}
+			purged++
+		}
+	}
+	return purged, nil
+}
+
+// purgeOne permanently deletes a trashed entity and its trashed dependents in
+// one transaction, recording each of them in the audit log as purged by the
+// system in the job jobID.
+func purgeOne(ctx context.Context, db *sql.DB, t trashable, id int, jobID string) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// A restore may have taken the entity out of the trash in the meantime.
+	sqlQuery := fmt.Sprintf(`SELECT deleted_at FROM %s WHERE %s = $1 AND deleted_at IS NOT NULL FOR UPDATE`, t.table, t.idColumn)
+	var deletedAt time.Time
+	err = tx.QueryRowContext(ctx, sqlQuery, id).Scan(&deletedAt)
+	if err == sql.ErrNoRows {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	before, err := audit.Snapshot(ctx, tx, t.entityType, id)
+	if err != nil {
+		return err
+	}
+	actorID := audit.SystemActorID
+	entries := []models.AuditEntry{{ActorID: &actorID, Action: audit.ActionPurge, EntityType: t.entityType, EntityID: id, Before: before, RequestID: jobID}}
+
+	for _, d := range t.dependents {
+		sqlQuery := fmt.Sprintf(`DELETE FROM %s t WHERE t.%s = $1 AND t.deleted_at = $2 RETURNING t.%s, row_to_json(t)`, d.table, d.column, d.idColumn)
+		rows, err := tx.QueryContext(ctx, sqlQuery, id, deletedAt)
+		if err != nil {
+			return err
+		}
+		for rows.Next() {
+			entry := models.AuditEntry{ActorID: &actorID, Action: audit.ActionPurge, EntityType: d.entityType, RequestID: jobID}
+			var snapshot []byte
+			if err := rows.Scan(&entry.EntityID, &snapshot); err != nil {
+				rows.Close()
+				return err
+			}
+			entry.Before = snapshot
+			entries = append(entries, entry)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+	}
+	for _, sqlQuery := range t.cleanup {
+		if _, err := tx.ExecContext(ctx, sqlQuery, id); err != nil {
+			return err
+		}
+	}
+
+	sqlQuery = fmt.Sprintf(`DELETE FROM %s WHERE %s = $1`, t.table, t.idColumn)
+	if _, err := tx.ExecContext(ctx, sqlQuery, id); err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		if err := audit.Record(ctx, tx, entry); err != nil {
+			return err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	invalidateCachedEntity(ctx, t.entityType)
+	return nil
+}
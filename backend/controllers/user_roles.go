@@ -0,0 +1,62 @@
+package controllers
+
+import (
+	"backend/apierror"
+	"backend/models"
+	"backend/policy"
+	"database/sql"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// UpdateUserRole assigns a global policy role to a user.
+// UpdateUserRole godoc
+// @Summary Assign a role to a user
+// @Description Set a user's global role, e.g. admin or user
+// @Tags user_roles
+// @Accept json
+// @Produce json
+// @Param id path int true "User ID"
+// @Param role body models.UserRoleRequest true "Role"
+// @Success 200 {object} models.UserRole
+// @Failure 400 {object} apierror.Problem
+// @Failure 403 {object} apierror.Problem
+// @Failure 422 {object} apierror.Problem "Validation failed"
+// @Failure 500 {object} apierror.Problem
+// @Router /users/{id}/role [put]
+func UpdateUserRole(c *gin.Context, db *sql.DB) {
+	// 1. Extract the user ID from the URL parameter.
+	userID, err := strconv.Atoi(c.Param("id"))
+	if err != nil || userID <= 0 {
+		c.Error(apierror.BadRequest("Invalid user ID"))
+		return
+	}
+
+	// 2. Bind the request JSON to the role struct.
+	var roleReq models.UserRoleRequest
+	if err := c.ShouldBindJSON(&roleReq); err != nil {
+		c.Error(apierror.FromBinding(err))
+		return
+	}
+
+	// 3. Save the role; regular users need no row.
+	var sqlQuery string
+	if roleReq.Role == policy.RoleUser {
+		sqlQuery = `DELETE FROM user_roles WHERE user_id = $1`
+		_, err = db.ExecContext(c.Request.Context(), sqlQuery, userID)
+	} else {
+		sqlQuery = `
+			INSERT INTO user_roles (user_id, role) VALUES ($1, $2)
+			ON CONFLICT (user_id) DO UPDATE SET role = EXCLUDED.role`
+		_, err = db.ExecContext(c.Request.Context(), sqlQuery, userID, roleReq.Role)
+	}
+	if err != nil {
+		c.Error(apierror.FromDB(err, "Error updating user role"))
+		return
+	}
+
+	// 4. Return a JSON response with the role.
+	c.JSON(http.StatusOK, models.UserRole{UserID: userID, Role: roleReq.Role})
+}
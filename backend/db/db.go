@@ -2,55 +2,76 @@
 package db
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
-	"log"
+	"log/slog"
 	"os"
 
 	"github.com/joho/godotenv"
-	_ "github.com/lib/pq"
+	"github.com/lib/pq"
 )
 
-// InitDB initializes the database connection
-func InitDB() (*sql.DB, error) {
+// ChangesChannel is the notification channel announcing changes to recipes,
+// ingredients, recipe ingredients and recipe steps. Payloads are JSON objects
+// with the entity type, its ID and the operation: insert, update or delete.
+const ChangesChannel = "entity_changes"
+
+// ConnString returns the connection string of the database named by the
+// DB_* environment variables.
+func ConnString() string {
+	return fmt.Sprintf("host=%s port=%s user=%s "+
+		"password=%s dbname=%s sslmode=disable",
+		os.Getenv("DB_HOST"), os.Getenv("DB_PORT"), os.Getenv("DB_USER"), os.Getenv("DB_PASSWORD"), os.Getenv("DB_NAME"))
+}
+
+// InitDB initializes the database connection. The hooks run around every
+// statement, e.g. to time or trace it.
+func InitDB(hooks ...QueryHook) (*sql.DB, error) {
 	// Load .env file
 	if err := godotenv.Load(); err != nil {
-		log.Fatal("Error loading .env file")
+		return nil, fmt.Errorf("error loading .env file: %v", err)
 	}
 
-	// Get environment variables
-	host := os.Getenv("DB_HOST")
-	port := os.Getenv("DB_PORT")
-	user := os.Getenv("DB_USER")
-	password := os.Getenv("DB_PASSWORD")
-	dbname := os.Getenv("DB_NAME")
-
-	// Build the connection string
-	connStr := fmt.Sprintf("host=%s port=%s user=%s "+
-		"password=%s dbname=%s sslmode=disable",
-		host, port, user, password, dbname)
-
-	// Open a DB connection
-	db, err := sql.Open("postgres", connStr)
+	// Open a DB connection, running the hooks around every statement
+	connector, err := pq.NewConnector(ConnString())
 	if err != nil {
-		return nil, err // Return an error if the connection fails
+		return nil, err // Return an error if the connection string is invalid
 	}
+	db := sql.OpenDB(instrument(connector, hooks))
 
-	// Check the connection
-	err = db.Ping()
-	if err != nil {
-		return nil, err // Return an error if the ping fails
+	// Size the pool, then wait for the database to come up
+	if err := configurePool(db); err != nil {
+		db.Close()
+		return nil, err
+	}
+	if err := waitForDatabase(db); err != nil {
+		db.Close()
+		return nil, err
 	}
 
+	// Apply the schema and prepare the registered statements
+	if err := ApplySchema(db); err != nil {
+		return nil, err
+	}
+
+	slog.Info("connected to database", "host", os.Getenv("DB_HOST"), "dbname", os.Getenv("DB_NAME"))
+	return db, nil // Return the database instance and no error
+}
+
+// ApplySchema creates or updates the tables, indexes and triggers the service
+// needs, then prepares the statements registered with Register. Every step is
+// idempotent, so it runs on every start.
+func ApplySchema(db *sql.DB) error {
 	execQuery := func(query string) error {
-		_, err := db.Exec(query)
+		_, err := db.ExecContext(context.Background(), query)
 		if err != nil {
 			return fmt.Errorf("error executing query: %v", err)
 		}
 		return nil
 	}
 
-	tables := []string{
+	schema := []string{
 		`CREATE TABLE IF NOT EXISTS ingredients (
             ingredient_id SERIAL PRIMARY KEY,
             ingredient_name VARCHAR(255) NOT NULL,
@@ -77,15 +98,193 @@ func InitDB() (*sql.DB, error) {
             step_description TEXT NOT NULL,
             FOREIGN KEY (recipe_id) REFERENCES recipes(recipe_id)
         );`,
+		`CREATE TABLE IF NOT EXISTS households (
+            household_id SERIAL PRIMARY KEY,
+            household_name VARCHAR(255) NOT NULL
+        );`,
+		`CREATE TABLE IF NOT EXISTS household_members (
+            household_id INT NOT NULL,
+            user_id INT NOT NULL,
+            role VARCHAR(16) NOT NULL,
+            PRIMARY KEY (household_id, user_id),
+            FOREIGN KEY (household_id) REFERENCES households(household_id)
+        );`,
+		`CREATE TABLE IF NOT EXISTS household_invitations (
+            invitation_id SERIAL PRIMARY KEY,
+            household_id INT NOT NULL,
+            token_hash CHAR(64) NOT NULL UNIQUE,
+            role VARCHAR(16) NOT NULL,
+            created_by INT NOT NULL,
+            expires_at TIMESTAMPTZ NOT NULL,
+            accepted_by INT,
+            accepted_at TIMESTAMPTZ,
+            FOREIGN KEY (household_id) REFERENCES households(household_id)
+        );`,
+		`CREATE TABLE IF NOT EXISTS api_keys (
+            api_key_id SERIAL PRIMARY KEY,
+            user_id INT NOT NULL,
+            name VARCHAR(255) NOT NULL,
+            key_prefix VARCHAR(32) NOT NULL,
+            key_hash CHAR(64) NOT NULL UNIQUE,
+            scopes TEXT[] NOT NULL,
+            created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+            last_used_at TIMESTAMPTZ,
+            revoked_at TIMESTAMPTZ
+        );`,
+		`CREATE TABLE IF NOT EXISTS user_roles (
+            user_id INT PRIMARY KEY,
+            role VARCHAR(32) NOT NULL
+        );`,
+		`CREATE TABLE IF NOT EXISTS ingredient_proposals (
+            proposal_id SERIAL PRIMARY KEY,
+            ingredient_name VARCHAR(255) NOT NULL,
+            ingredient_description TEXT,
+            proposed_by INT NOT NULL,
+            status VARCHAR(16) NOT NULL,
+            reviewed_by INT,
+            ingredient_id INT,
+            created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+            reviewed_at TIMESTAMPTZ,
+            FOREIGN KEY (ingredient_id) REFERENCES ingredients(ingredient_id)
+        );`,
+		// Ownership and visibility. Rows created before owners existed stay public.
+		`ALTER TABLE recipes ADD COLUMN IF NOT EXISTS owner_id INT;`,
+		`ALTER TABLE recipes ADD COLUMN IF NOT EXISTS visibility VARCHAR(16) NOT NULL DEFAULT 'public';`,
+		`ALTER TABLE ingredients ADD COLUMN IF NOT EXISTS owner_id INT;`,
+		// Tenant scoping. Rows without a household are personal data or the shared ingredient catalog.
+		`ALTER TABLE recipes ADD COLUMN IF NOT EXISTS household_id INT REFERENCES households(household_id);`,
+		`ALTER TABLE ingredients ADD COLUMN IF NOT EXISTS household_id INT REFERENCES households(household_id);`,
+		`CREATE TABLE IF NOT EXISTS recipe_collaborators (
+            recipe_id INT NOT NULL,
+            user_id INT NOT NULL,
+            PRIMARY KEY (recipe_id, user_id),
+            FOREIGN KEY (recipe_id) REFERENCES recipes(recipe_id)
+        );`,
+		// Quantities are measured in a unit and may be fractional.
+		`ALTER TABLE recipe_ingredients ADD COLUMN IF NOT EXISTS measurement VARCHAR(16);`,
+		// Changing the column type rewrites the table, so it only runs while quantity is still an INT.
+		`DO $$
+        BEGIN
+            IF EXISTS (SELECT 1 FROM information_schema.columns
+                WHERE table_schema = current_schema() AND table_name = 'recipe_ingredients'
+                    AND column_name = 'quantity' AND data_type <> 'numeric') THEN
+                ALTER TABLE recipe_ingredients ALTER COLUMN quantity TYPE NUMERIC(12, 3);
+            END IF;
+        END;
+        $$;`,
+		`CREATE TABLE IF NOT EXISTS audit_log (
+            audit_id BIGSERIAL PRIMARY KEY,
+            actor_id INT,
+            action VARCHAR(16) NOT NULL,
+            entity_type VARCHAR(32) NOT NULL,
+            entity_id INT NOT NULL,
+            before JSONB,
+            after JSONB,
+            request_id VARCHAR(128),
+            created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+        );`,
+		`CREATE INDEX IF NOT EXISTS audit_log_entity_idx ON audit_log (entity_type, entity_id);`,
+		`CREATE INDEX IF NOT EXISTS audit_log_actor_idx ON audit_log (actor_id);`,
+		// The audit log is append-only: entries can never be changed or removed.
+		`CREATE OR REPLACE FUNCTION audit_log_append_only() RETURNS trigger AS $$
+        BEGIN
+            RAISE EXCEPTION 'audit_log is append-only';
+        END;
+        $$ LANGUAGE plpgsql;`,
+		`DROP TRIGGER IF EXISTS audit_log_append_only ON audit_log;`,
+		`CREATE TRIGGER audit_log_append_only
+            BEFORE UPDATE OR DELETE ON audit_log
+            FOR EACH ROW EXECUTE FUNCTION audit_log_append_only();`,
+		// Every change to a recipe, its ingredients or steps stores the whole recipe as a revision.
+		`CREATE TABLE IF NOT EXISTS recipe_revisions (
+            recipe_id INT NOT NULL,
+            revision INT NOT NULL,
+            document JSONB NOT NULL,
+            actor_id INT,
+            request_id VARCHAR(128),
+            created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+            PRIMARY KEY (recipe_id, revision),
+            FOREIGN KEY (recipe_id) REFERENCES recipes(recipe_id)
+        );`,
+		// Forks remember the recipe they were copied from.
+		`ALTER TABLE recipes ADD COLUMN IF NOT EXISTS forked_from INT REFERENCES recipes(recipe_id);`,
+		`CREATE INDEX IF NOT EXISTS recipes_forked_from_idx ON recipes (forked_from);`,
+		// Idempotency keys remember the response to a POST request so retries replay it.
+		`CREATE TABLE IF NOT EXISTS idempotency_keys (
+            user_id INT NOT NULL,
+            idempotency_key VARCHAR(255) NOT NULL,
+            fingerprint CHAR(64) NOT NULL,
+            status INT,
+            headers JSONB,
+            body BYTEA,
+            created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+            PRIMARY KEY (user_id, idempotency_key)
+        );`,
+		`CREATE INDEX IF NOT EXISTS idempotency_keys_created_at_idx ON idempotency_keys (created_at);`,
+		// Row versions back ETags and If-Match; every update bumps the version
+		// and records when the row changed.
+		`CREATE OR REPLACE FUNCTION bump_version() RETURNS trigger AS $$
+        BEGIN
+            NEW.version := OLD.version + 1;
+            NEW.updated_at := NOW();
+            RETURN NEW;
+        END;
+        $$ LANGUAGE plpgsql;`,
+	}
+	for _, table := range []string{"recipes", "ingredients", "recipe_ingredients", "recipe_steps"} {
+		schema = append(schema,
+			`ALTER TABLE `+table+` ADD COLUMN IF NOT EXISTS version INT NOT NULL DEFAULT 1;`,
+			`ALTER TABLE `+table+` ADD COLUMN IF NOT EXISTS created_at TIMESTAMPTZ NOT NULL DEFAULT NOW();`,
+			`ALTER TABLE `+table+` ADD COLUMN IF NOT EXISTS updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW();`,
+			`CREATE INDEX IF NOT EXISTS `+table+`_updated_at_idx ON `+table+` (updated_at);`,
+			// Deleted rows stay in the trash, hidden from normal queries, until restored or purged.
+			`ALTER TABLE `+table+` ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMPTZ;`,
+			`CREATE INDEX IF NOT EXISTS `+table+`_deleted_at_idx ON `+table+` (deleted_at) WHERE deleted_at IS NOT NULL;`,
+			`DROP TRIGGER IF EXISTS `+table+`_bump_version ON `+table+`;`,
+			`CREATE TRIGGER `+table+`_bump_version
+            BEFORE UPDATE ON `+table+`
+            FOR EACH ROW EXECUTE FUNCTION bump_version();`,
+		)
 	}
 
-	for _, qry := range tables {
+	// Every change to recipes and their parts is announced on ChangesChannel
+	// once committed, so that other instances can drop what they hold of it.
+	schema = append(schema, `CREATE OR REPLACE FUNCTION notify_entity_change() RETURNS trigger AS $$
+        DECLARE
+            changed RECORD;
+        BEGIN
+            IF TG_OP = 'DELETE' THEN
+                changed := OLD;
+            ELSE
+                changed := NEW;
+            END IF;
+            PERFORM pg_notify('`+ChangesChannel+`', json_build_object(
+                'entity', TG_ARGV[0],
+                'id', (to_jsonb(changed) ->> TG_ARGV[1])::int,
+                'op', lower(TG_OP))::text);
+            RETURN NULL;
+        END;
+        $$ LANGUAGE plpgsql;`)
+	for _, t := range []struct{ table, entity, idColumn string }{
+		{"recipes", "recipe", "recipe_id"},
+		{"ingredients", "ingredient", "ingredient_id"},
+		{"recipe_ingredients", "recipe_ingredient", "recipe_ingredient_id"},
+		{"recipe_steps", "recipe_step", "recipe_step_id"},
+	} {
+		schema = append(schema,
+			`DROP TRIGGER IF EXISTS `+t.table+`_notify_change ON `+t.table+`;`,
+			`CREATE TRIGGER `+t.table+`_notify_change
+            AFTER INSERT OR UPDATE OR DELETE ON `+t.table+`
+            FOR EACH ROW EXECUTE FUNCTION notify_entity_change('`+t.entity+`', '`+t.idColumn+`');`,
+		)
+	}
+
+	for _, qry := range schema {
 		if err := execQuery(qry); err != nil {
-			return nil, err
+			return err
 		}
 	}
 
-	fmt.Println("Successfully connected!")
-	return db, nil // Return the database instance and no error
-
+	// Prepare the statements registered by the controllers once for the pool
+	return prepareStatements(context.Background(), db)
 }
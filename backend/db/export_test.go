@@ -0,0 +1,17 @@
+package db
+
+// PrepareStatements exposes prepareStatements to the benchmarks.
+var PrepareStatements = prepareStatements
+
+// UnprepareStatements closes the prepared statements, so that each request
+// prepares its statements again, as before the registry.
+func UnprepareStatements() {
+	statementsMu.Lock()
+	defer statementsMu.Unlock()
+
+	for _, s := range statements {
+		if old := s.stmt.Swap(nil); old != nil {
+			old.Close()
+		}
+	}
+}
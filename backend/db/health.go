@@ -0,0 +1,46 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"github.com/lib/pq"
+)
+
+// Tables lists the tables InitDB creates. Their presence shows the schema has
+// been applied.
+var Tables = []string{
+	"ingredients", "recipes", "recipe_ingredients", "recipe_steps",
+	"households", "household_members", "household_invitations",
+	"api_keys", "user_roles", "ingredient_proposals", "recipe_collaborators",
+	"audit_log", "recipe_revisions", "idempotency_keys",
+}
+
+// CheckSchema returns an error naming the tables of the schema that are
+// missing from the database.
+func CheckSchema(ctx context.Context, db *sql.DB) error {
+	sqlQuery := `SELECT t FROM unnest($1::text[]) AS t WHERE to_regclass(t) IS NULL`
+	rows, err := db.QueryContext(ctx, sqlQuery, pq.Array(Tables))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	var missing []string
+	for rows.Next() {
+		var table string
+		if err := rows.Scan(&table); err != nil {
+			return err
+		}
+		missing = append(missing, table)
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("missing tables: %s", strings.Join(missing, ", "))
+	}
+	return nil
+}
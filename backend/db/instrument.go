@@ -0,0 +1,292 @@
+package db
+
+import (
+	"context"
+	"database/sql/driver"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// QueryHook is called as a statement starts, with its SQL and command, and
+// returns a function called with the outcome once the database answered.
+type QueryHook func(ctx context.Context, query, operation string) func(err error)
+
+// instrument wraps a database connector so that hooks run around every
+// statement run on its connections.
+func instrument(connector driver.Connector, hooks []QueryHook) driver.Connector {
+	return &instrumentedConnector{Connector: connector, hooks: hooks}
+}
+
+// Statement timeouts by operation class. Zero means no limit. Statements of
+// other classes, such as schema changes, are only bounded by their context.
+var (
+	// ReadTimeout bounds SELECT statements.
+	ReadTimeout = 5 * time.Second
+	// WriteTimeout bounds INSERT, UPDATE and DELETE statements, and those
+	// starting with a WITH clause, which may write.
+	WriteTimeout = 10 * time.Second
+)
+
+// statementTimeout returns the timeout of a statement's operation class.
+func statementTimeout(operation string) time.Duration {
+	switch operation {
+	case "select":
+		return ReadTimeout
+	case "insert", "update", "delete", "with":
+		return WriteTimeout
+	default:
+		return 0
+	}
+}
+
+// startQuery bounds a statement by its timeout and runs hooks for it
+// starting. It returns the context to run the statement with, the function
+// releasing that context once the statement's results have been read, and a
+// function reporting its outcome to the hooks. The latter returns the error
+// to report: statements stopped because their context ended report the
+// context's error, so that callers can tell timeouts and cancellations apart
+// from failures.
+func startQuery(ctx context.Context, hooks []QueryHook, query string) (context.Context, context.CancelFunc, func(error) error) {
+	op := operation(query)
+	cancel := context.CancelFunc(func() {})
+	if timeout := statementTimeout(op); timeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+	}
+
+	done := make([]func(error), len(hooks))
+	for i, hook := range hooks {
+		done[i] = hook(ctx, query, op)
+	}
+	return ctx, cancel, func(err error) error {
+		if err != nil && ctx.Err() != nil {
+			err = fmt.Errorf("%w: %v", ctx.Err(), err)
+		}
+		for _, d := range done {
+			d(err)
+		}
+		return err
+	}
+}
+
+// operation returns the SQL command of a statement, such as "select".
+func operation(query string) string {
+	fields := strings.Fields(query)
+	if len(fields) == 0 {
+		return "other"
+	}
+	switch keyword := strings.ToLower(fields[0]); keyword {
+	case "select", "insert", "update", "delete", "with", "begin", "commit", "rollback",
+		"create", "alter", "drop", "listen", "unlisten", "notify":
+		return keyword
+	default:
+		return "other"
+	}
+}
+
+// instrumentedConnector opens instrumented connections.
+type instrumentedConnector struct {
+	driver.Connector
+	hooks []QueryHook
+}
+
+func (c *instrumentedConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	conn, err := c.Connector.Connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &instrumentedConn{Conn: conn, hooks: c.hooks}, nil
+}
+
+// instrumentedConn runs the hooks around the statements run on a connection. The optional
+// interfaces it implements fall back to driver.ErrSkip, or a no-op, when the
+// wrapped connection lacks them, so database/sql behaves as without it.
+type instrumentedConn struct {
+	driver.Conn
+	hooks []QueryHook
+}
+
+func (c *instrumentedConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	queryer, ok := c.Conn.(driver.QueryerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+	ctx, cancel, done := startQuery(ctx, c.hooks, query)
+	rows, err := queryer.QueryContext(ctx, query, args)
+	return boundedRows(rows, done(err), cancel)
+}
+
+func (c *instrumentedConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	execer, ok := c.Conn.(driver.ExecerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+	ctx, cancel, done := startQuery(ctx, c.hooks, query)
+	defer cancel()
+	result, err := execer.ExecContext(ctx, query, args)
+	return result, done(err)
+}
+
+func (c *instrumentedConn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	var stmt driver.Stmt
+	var err error
+	if preparer, ok := c.Conn.(driver.ConnPrepareContext); ok {
+		stmt, err = preparer.PrepareContext(ctx, query)
+	} else {
+		stmt, err = c.Conn.Prepare(query)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &instrumentedStmt{Stmt: stmt, query: query, hooks: c.hooks}, nil
+}
+
+func (c *instrumentedConn) Prepare(query string) (driver.Stmt, error) {
+	return c.PrepareContext(context.Background(), query)
+}
+
+func (c *instrumentedConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	if beginner, ok := c.Conn.(driver.ConnBeginTx); ok {
+		return beginner.BeginTx(ctx, opts)
+	}
+	return c.Conn.Begin()
+}
+
+func (c *instrumentedConn) Ping(ctx context.Context) error {
+	if pinger, ok := c.Conn.(driver.Pinger); ok {
+		return pinger.Ping(ctx)
+	}
+	return nil
+}
+
+func (c *instrumentedConn) ResetSession(ctx context.Context) error {
+	if resetter, ok := c.Conn.(driver.SessionResetter); ok {
+		return resetter.ResetSession(ctx)
+	}
+	return nil
+}
+
+func (c *instrumentedConn) IsValid() bool {
+	if validator, ok := c.Conn.(driver.Validator); ok {
+		return validator.IsValid()
+	}
+	return true
+}
+
+// instrumentedStmt runs the hooks around the executions of a prepared statement.
+type instrumentedStmt struct {
+	driver.Stmt
+	query string
+	hooks []QueryHook
+}
+
+func (s *instrumentedStmt) QueryContext(ctx context.Context, args []driver.NamedValue) (driver.Rows, error) {
+	ctx, cancel, done := startQuery(ctx, s.hooks, s.query)
+	var rows driver.Rows
+	var err error
+	if queryer, ok := s.Stmt.(driver.StmtQueryContext); ok {
+		rows, err = queryer.QueryContext(ctx, args)
+	} else {
+		var values []driver.Value
+		if values, err = namedValues(args); err == nil {
+			rows, err = s.Stmt.Query(values)
+		}
+	}
+	return boundedRows(rows, done(err), cancel)
+}
+
+func (s *instrumentedStmt) ExecContext(ctx context.Context, args []driver.NamedValue) (driver.Result, error) {
+	ctx, cancel, done := startQuery(ctx, s.hooks, s.query)
+	defer cancel()
+	var result driver.Result
+	var err error
+	if execer, ok := s.Stmt.(driver.StmtExecContext); ok {
+		result, err = execer.ExecContext(ctx, args)
+	} else {
+		var values []driver.Value
+		if values, err = namedValues(args); err == nil {
+			result, err = s.Stmt.Exec(values)
+		}
+	}
+	return result, done(err)
+}
+
+// boundedRows returns the results of a statement, keeping its context alive
+// until they have been read.
+func boundedRows(rows driver.Rows, err error, cancel context.CancelFunc) (driver.Rows, error) {
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	return &cancelingRows{Rows: rows, cancel: cancel}, nil
+}
+
+// cancelingRows releases the context of the statement it reads once closed.
+type cancelingRows struct {
+	driver.Rows
+	cancel context.CancelFunc
+}
+
+func (r *cancelingRows) Close() error {
+	defer r.cancel()
+	return r.Rows.Close()
+}
+
+// The optional interfaces of rows fall back to what database/sql assumes when
+// the wrapped rows lack them.
+
+func (r *cancelingRows) HasNextResultSet() bool {
+	if sets, ok := r.Rows.(driver.RowsNextResultSet); ok {
+		return sets.HasNextResultSet()
+	}
+	return false
+}
+
+func (r *cancelingRows) NextResultSet() error {
+	if sets, ok := r.Rows.(driver.RowsNextResultSet); ok {
+		return sets.NextResultSet()
+	}
+	return io.EOF
+}
+
+func (r *cancelingRows) ColumnTypeScanType(index int) reflect.Type {
+	if types, ok := r.Rows.(driver.RowsColumnTypeScanType); ok {
+		return types.ColumnTypeScanType(index)
+	}
+	return reflect.TypeOf(new(interface{})).Elem()
+}
+
+func (r *cancelingRows) ColumnTypeDatabaseTypeName(index int) string {
+	if types, ok := r.Rows.(driver.RowsColumnTypeDatabaseTypeName); ok {
+		return types.ColumnTypeDatabaseTypeName(index)
+	}
+	return ""
+}
+
+func (r *cancelingRows) ColumnTypeLength(index int) (int64, bool) {
+	if types, ok := r.Rows.(driver.RowsColumnTypeLength); ok {
+		return types.ColumnTypeLength(index)
+	}
+	return 0, false
+}
+
+func (r *cancelingRows) ColumnTypePrecisionScale(index int) (int64, int64, bool) {
+	if types, ok := r.Rows.(driver.RowsColumnTypePrecisionScale); ok {
+		return types.ColumnTypePrecisionScale(index)
+	}
+	return 0, 0, false
+}
+
+// namedValues converts positional arguments for drivers predating contexts.
+func namedValues(args []driver.NamedValue) ([]driver.Value, error) {
+	values := make([]driver.Value, len(args))
+	for i, arg := range args {
+		if arg.Name != "" {
+			return nil, driver.ErrSkip
+		}
+		values[i] = arg.Value
+	}
+	return values, nil
+}
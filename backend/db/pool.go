@@ -0,0 +1,141 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"os"
+	"strconv"
+	"time"
+)
+
+// Connection pool defaults, overridden by DB_MAX_OPEN_CONNS,
+// DB_MAX_IDLE_CONNS, DB_CONN_MAX_LIFETIME and DB_CONN_MAX_IDLE_TIME.
+const (
+	defaultMaxOpenConns    = 25
+	defaultMaxIdleConns    = 10
+	defaultConnMaxLifetime = 30 * time.Minute
+	defaultConnMaxIdleTime = 5 * time.Minute
+)
+
+// Startup retry defaults. InitDB keeps trying to reach the database, backing
+// off exponentially, for up to DB_CONNECT_MAX_WAIT.
+const (
+	defaultConnectMaxWait = time.Minute
+	initialConnectBackoff = 250 * time.Millisecond
+	maxConnectBackoff     = 8 * time.Second
+	connectAttemptTimeout = 5 * time.Second
+)
+
+// configurePool sizes the connection pool of db from the environment.
+func configurePool(db *sql.DB) error {
+	maxOpen, err := envInt("DB_MAX_OPEN_CONNS", defaultMaxOpenConns)
+	if err != nil {
+		return err
+	}
+	maxIdle, err := envInt("DB_MAX_IDLE_CONNS", defaultMaxIdleConns)
+	if err != nil {
+		return err
+	}
+	maxLifetime, err := envDuration("DB_CONN_MAX_LIFETIME", defaultConnMaxLifetime)
+	if err != nil {
+		return err
+	}
+	maxIdleTime, err := envDuration("DB_CONN_MAX_IDLE_TIME", defaultConnMaxIdleTime)
+	if err != nil {
+		return err
+	}
+
+	db.SetMaxOpenConns(maxOpen)
+	db.SetMaxIdleConns(maxIdle)
+	db.SetConnMaxLifetime(maxLifetime)
+	db.SetConnMaxIdleTime(maxIdleTime)
+	slog.Info("configured database pool", "max_open_conns", maxOpen, "max_idle_conns", maxIdle,
+		"conn_max_lifetime", maxLifetime.String(), "conn_max_idle_time", maxIdleTime.String())
+	return nil
+}
+
+// waitForDatabase pings db until it answers, backing off exponentially
+// between attempts, so that the service can start before the database does.
+// It gives up after DB_CONNECT_MAX_WAIT.
+func waitForDatabase(db *sql.DB) error {
+	maxWait, err := envDuration("DB_CONNECT_MAX_WAIT", defaultConnectMaxWait)
+	if err != nil {
+		return err
+	}
+
+	deadline := time.Now().Add(maxWait)
+	backoff := initialConnectBackoff
+	for attempt := 1; ; attempt++ {
+		ctx, cancel := context.WithTimeout(context.Background(), connectAttemptTimeout)
+		err := db.PingContext(ctx)
+		cancel()
+		if err == nil {
+			return nil
+		}
+
+		if time.Now().Add(backoff).After(deadline) {
+			return fmt.Errorf("database unreachable after %d attempts: %v", attempt, err)
+		}
+		slog.Warn("database unreachable, retrying", "attempt", attempt, "retry_in", backoff.String(), "error", err)
+		time.Sleep(backoff)
+		backoff = min(backoff*2, maxConnectBackoff)
+	}
+}
+
+// RunPoolStatsLogger logs the connection pool statistics of db every
+// interval until ctx is done.
+func RunPoolStatsLogger(ctx context.Context, db *sql.DB, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		stats := db.Stats()
+		slog.Info("database pool stats",
+			"open", stats.OpenConnections,
+			"in_use", stats.InUse,
+			"idle", stats.Idle,
+			"max_open", stats.MaxOpenConnections,
+			"wait_count", stats.WaitCount,
+			"wait_duration", stats.WaitDuration.String(),
+			"max_idle_closed", stats.MaxIdleClosed,
+			"max_idle_time_closed", stats.MaxIdleTimeClosed,
+			"max_lifetime_closed", stats.MaxLifetimeClosed,
+		)
+	}
+}
+
+// envInt reads a non-negative integer from the environment, or returns def
+// when the variable is unset.
+func envInt(name string, def int) (int, error) {
+	value := os.Getenv(name)
+	if value == "" {
+		return def, nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("%s: %q is not a non-negative integer", name, value)
+	}
+	return n, nil
+}
+
+// envDuration reads a non-negative duration from the environment, or returns
+// def when the variable is unset.
+func envDuration(name string, def time.Duration) (time.Duration, error) {
+	value := os.Getenv(name)
+	if value == "" {
+		return def, nil
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("%s: %q is not a duration", name, value)
+	}
+	return d, nil
+}
@@ -0,0 +1,71 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// Statement is a query prepared once, when the database is initialized,
+// rather than on every request. database/sql prepares it again on each
+// connection it runs on, including those replacing lost connections, and
+// keeps it prepared there, so that running it costs a single round trip.
+type Statement struct {
+	query string
+	stmt  atomic.Pointer[sql.Stmt]
+}
+
+var (
+	statementsMu sync.Mutex
+	statements   []*Statement
+)
+
+// Register adds query to the statements InitDB prepares. It is meant to be
+// called when packages initialize, e.g. from package-level variables.
+func Register(query string) *Statement {
+	statementsMu.Lock()
+	defer statementsMu.Unlock()
+
+	s := &Statement{query: query}
+	statements = append(statements, s)
+	return s
+}
+
+// In returns the statement bound to tx. The statement is closed with the
+// transaction. Statements not prepared yet are prepared in tx.
+func (s *Statement) In(ctx context.Context, tx *sql.Tx) (*sql.Stmt, error) {
+	if stmt := s.stmt.Load(); stmt != nil {
+		return tx.StmtContext(ctx, stmt), nil
+	}
+	return tx.PrepareContext(ctx, s.query)
+}
+
+// QueryRow runs the statement on db outside of a transaction. Statements not
+// prepared yet are sent along with their arguments, as db.QueryRowContext does.
+func (s *Statement) QueryRow(ctx context.Context, db *sql.DB, args ...interface{}) *sql.Row {
+	if stmt := s.stmt.Load(); stmt != nil {
+		return stmt.QueryRowContext(ctx, args...)
+	}
+	return db.QueryRowContext(ctx, s.query, args...)
+}
+
+// prepareStatements prepares every registered statement on db, which also
+// checks them against the schema before the service takes requests.
+func prepareStatements(ctx context.Context, db *sql.DB) error {
+	statementsMu.Lock()
+	defer statementsMu.Unlock()
+
+	for _, s := range statements {
+		stmt, err := db.PrepareContext(ctx, s.query)
+		if err != nil {
+			return fmt.Errorf("error preparing statement %q: %v", strings.Join(strings.Fields(s.query), " "), err)
+		}
+		if old := s.stmt.Swap(stmt); old != nil {
+			old.Close()
+		}
+	}
+	return nil
+}
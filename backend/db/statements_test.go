@@ -0,0 +1,79 @@
+package db_test
+
+import (
+	"backend/apitest"
+	"backend/db"
+	"context"
+	"database/sql"
+	"fmt"
+	"net/http"
+	"testing"
+)
+
+// The benchmarks run the recipe endpoints against the test database, once
+// with every statement prepared per request and once with the statements of
+// the registry, e.g.
+//
+//	TEST_DATABASE_URL=postgres://... go test -run '^$' -bench Recipe ./db
+
+// recipeBody is the recipe the benchmarks create and update.
+var recipeBody = map[string]interface{}{"recipe_name": "Benchmark", "cook_time": 10}
+
+// compareStatements runs bench with statements prepared per request and with
+// the registry's statements.
+func compareStatements(b *testing.B, database *sql.DB, bench func(b *testing.B)) {
+	b.Run("prepare", func(b *testing.B) {
+		db.UnprepareStatements()
+		bench(b)
+	})
+	b.Run("registry", func(b *testing.B) {
+		if err := db.PrepareStatements(context.Background(), database); err != nil {
+			b.Fatal(err)
+		}
+		bench(b)
+	})
+}
+
+func BenchmarkCreateRecipe(b *testing.B) {
+	database := apitest.DB(b)
+	router := apitest.Router(b, database)
+	caller := apitest.NewCaller()
+	compareStatements(b, database, func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			caller.Must(b, router, http.MethodPost, "/recipes", recipeBody, http.StatusCreated, nil)
+		}
+	})
+}
+
+func BenchmarkGetRecipe(b *testing.B) {
+	database := apitest.DB(b)
+	router := apitest.Router(b, database)
+	caller := apitest.NewCaller()
+	var recipe struct {
+		RecipeID int `json:"recipe_id"`
+	}
+	caller.Must(b, router, http.MethodPost, "/recipes", recipeBody, http.StatusCreated, &recipe)
+	path := fmt.Sprintf("/recipes/%d", recipe.RecipeID)
+	compareStatements(b, database, func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			caller.Must(b, router, http.MethodGet, path, nil, http.StatusOK, nil)
+		}
+	})
+}
+
+func BenchmarkUpdateRecipe(b *testing.B) {
+	database := apitest.DB(b)
+	router := apitest.Router(b, database)
+	caller := apitest.NewCaller()
+	var recipe struct {
+		RecipeID int `json:"recipe_id"`
+	}
+	caller.Must(b, router, http.MethodPost, "/recipes", recipeBody, http.StatusCreated, &recipe)
+	path := fmt.Sprintf("/recipes/%d", recipe.RecipeID)
+	compareStatements(b, database, func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			body := map[string]interface{}{"recipe_name": fmt.Sprintf("Benchmark %d", i), "cook_time": 10}
+			caller.Must(b, router, http.MethodPut, path, body, http.StatusOK, nil)
+		}
+	})
+}
@@ -0,0 +1,80 @@
+// Package logging sets up structured JSON logging with log/slog. The level can
+// be changed while the service runs, and attributes carrying secrets are
+// redacted whatever logs them.
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+)
+
+// Level is the minimum level logged. It may be changed at any time.
+var Level = new(slog.LevelVar)
+
+// redacted replaces the values of sensitive attributes.
+const redacted = "[REDACTED]"
+
+// sensitiveKeys are attribute keys, lower-cased, whose values are never logged.
+var sensitiveKeys = map[string]bool{
+	"authorization": true,
+	"cookie":        true,
+	"set-cookie":    true,
+	"password":      true,
+	"secret":        true,
+	"token":         true,
+	"api_key":       true,
+	"key_hash":      true,
+}
+
+// Setup makes a JSON logger writing to w the default logger, for both
+// log/slog and the log package, logging at level and above.
+func Setup(w io.Writer, level string) error {
+	if err := SetLevel(level); err != nil {
+		return err
+	}
+	handler := slog.NewJSONHandler(w, &slog.HandlerOptions{Level: Level, ReplaceAttr: redact})
+	slog.SetDefault(slog.New(handler))
+	return nil
+}
+
+// SetLevel changes the minimum level logged, given as debug, info, warn or error.
+func SetLevel(level string) error {
+	var l slog.Level
+	if err := l.UnmarshalText([]byte(level)); err != nil {
+		return fmt.Errorf("invalid log level %q", level)
+	}
+	Level.Set(l)
+	return nil
+}
+
+// LevelName returns the current minimum level, lower-cased.
+func LevelName() string {
+	return strings.ToLower(Level.Level().String())
+}
+
+// redact hides the values of sensitive attributes, including those nested in groups.
+func redact(groups []string, attr slog.Attr) slog.Attr {
+	if sensitiveKeys[strings.ToLower(attr.Key)] {
+		return slog.String(attr.Key, redacted)
+	}
+	return attr
+}
+
+// loggerKey is the context key holding the request-scoped logger.
+type loggerKey struct{}
+
+// WithLogger returns a copy of ctx carrying logger.
+func WithLogger(ctx context.Context, logger *slog.Logger) context.Context {
+	return context.WithValue(ctx, loggerKey{}, logger)
+}
+
+// FromContext returns the logger carried by ctx, or the default logger.
+func FromContext(ctx context.Context) *slog.Logger {
+	if logger, ok := ctx.Value(loggerKey{}).(*slog.Logger); ok {
+		return logger
+	}
+	return slog.Default()
+}
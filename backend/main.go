@@ -1,11 +1,31 @@
 package main
 
 import (
+	"backend/cache"
+	"backend/changefeed"
+	"backend/controllers"
 	"backend/db"
 	_ "backend/docs"
+	"backend/logging"
+	"backend/metrics"
+	"backend/middleware"
+	"backend/policy"
 	"backend/routes"
+	"backend/tracing"
+	"backend/validation"
+	"context"
 	"fmt"
-	"log"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"runtime/debug"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
 
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
@@ -14,42 +34,263 @@ import (
 )
 
 func main() {
-	// Initialize Gin router
-	router := gin.Default()
+	// Log JSON to stdout, at LOG_LEVEL and above; the level can be changed at runtime
+	level := os.Getenv("LOG_LEVEL")
+	if level == "" {
+		level = "info"
+	}
+	if err := logging.Setup(os.Stdout, level); err != nil {
+		fatal("Error reading LOG_LEVEL", err)
+	}
+
+	// Initialize Gin router, logging requests and recovering from panics as JSON
+	router := gin.New()
+	router.Use(gin.CustomRecoveryWithWriter(io.Discard, func(c *gin.Context, recovered interface{}) {
+		middleware.Logger(c).Error("panic while handling request", "panic", recovered, "stack", string(debug.Stack()))
+		c.AbortWithStatus(http.StatusInternalServerError)
+	}))
+
+	// Export traces with OTEL_TRACES_EXPORTER: none (the default), otlp, console or file
+	shutdownTracing, err := tracing.Setup(context.Background(), os.Getenv("OTEL_TRACES_EXPORTER"))
+	if err != nil {
+		fatal("Error setting up tracing", err)
+	}
+
+	// Bound statements by DB_READ_TIMEOUT and DB_WRITE_TIMEOUT; 0 means no limit
+	for name, timeout := range map[string]*time.Duration{"DB_READ_TIMEOUT": &db.ReadTimeout, "DB_WRITE_TIMEOUT": &db.WriteTimeout} {
+		if value := os.Getenv(name); value != "" {
+			if *timeout, err = time.ParseDuration(value); err != nil || *timeout < 0 {
+				fatal("Error reading "+name, fmt.Errorf("%q is not a duration", value))
+			}
+		}
+	}
 
-	// Connect to the database
-	database, err := db.InitDB()
+	// Connect to the database, timing and tracing every statement
+	database, err := db.InitDB(metrics.ObserveQuery, tracing.TraceQuery)
 	if err != nil {
-		log.Fatalf("Error initializing database: %v", err)
+		fatal("Error initializing database", err)
+	}
+
+	// Load the authorization policy, falling back to the built-in one
+	authz := policy.Default()
+	if policyFile := os.Getenv("POLICY_FILE"); policyFile != "" {
+		authz, err = policy.Load(policyFile)
+		if err != nil {
+			fatal("Error loading policy", err)
+		}
+	}
+
+	// Register the validation rules used by request models
+	if err := validation.Register(); err != nil {
+		fatal("Error registering validation rules", err)
+	}
+
+	// Choose what deleting a recipe or ingredient does to the rows using it
+	if controllers.RecipeDeleteMode, err = controllers.ParseDeleteMode(os.Getenv("RECIPE_DELETE_MODE")); err != nil {
+		fatal("Error reading RECIPE_DELETE_MODE", err)
+	}
+	if controllers.IngredientDeleteMode, err = controllers.ParseDeleteMode(os.Getenv("INGREDIENT_DELETE_MODE")); err != nil {
+		fatal("Error reading INGREDIENT_DELETE_MODE", err)
+	}
+
+	// Keep deleted recipes and ingredients in the trash for the retention window, purging them in the background
+	if retention := os.Getenv("TRASH_RETENTION"); retention != "" {
+		if controllers.TrashRetention, err = time.ParseDuration(retention); err != nil {
+			fatal("Error reading TRASH_RETENTION", err)
+		}
+	}
+	purgeInterval := time.Hour
+	if interval := os.Getenv("TRASH_PURGE_INTERVAL"); interval != "" {
+		if purgeInterval, err = time.ParseDuration(interval); err != nil || purgeInterval <= 0 {
+			fatal("Error reading TRASH_PURGE_INTERVAL", fmt.Errorf("%q is not a positive duration", interval))
+		}
+	}
+
+	// Only the authenticating proxies in TRUSTED_PROXIES may identify callers by header
+	if proxies := os.Getenv("TRUSTED_PROXIES"); proxies != "" {
+		if middleware.TrustedProxies, err = middleware.ParseTrustedProxies(proxies); err != nil {
+			fatal("Error reading TRUSTED_PROXIES", err)
+		}
+	}
+
+	// Cache recipe and ingredient reads in process unless CACHE_BACKEND=none
+	switch backend := os.Getenv("CACHE_BACKEND"); backend {
+	case "", "lru":
+		size := 1000
+		if value := os.Getenv("CACHE_SIZE"); value != "" {
+			if size, err = strconv.Atoi(value); err != nil || size <= 0 {
+				fatal("Error reading CACHE_SIZE", fmt.Errorf("%q is not a positive integer", value))
+			}
+		}
+		controllers.ResponseCache = cache.NewLRU(size)
+	case "none":
+	default:
+		fatal("Error reading CACHE_BACKEND", fmt.Errorf("unknown cache backend %q", backend))
+	}
+	if ttl := os.Getenv("CACHE_TTL"); ttl != "" {
+		if controllers.ResponseCacheTTL, err = time.ParseDuration(ttl); err != nil || controllers.ResponseCacheTTL <= 0 {
+			fatal("Error reading CACHE_TTL", fmt.Errorf("%q is not a positive duration", ttl))
+		}
+	}
+	if maxAge := os.Getenv("CACHE_MAX_AGE"); maxAge != "" {
+		if controllers.CacheMaxAge, err = time.ParseDuration(maxAge); err != nil || controllers.CacheMaxAge < 0 {
+			fatal("Error reading CACHE_MAX_AGE", fmt.Errorf("%q is not a duration", maxAge))
+		}
+	}
+
+	// Give in-flight requests this long to finish when shutting down
+	shutdownTimeout := 30 * time.Second
+	if timeout := os.Getenv("SHUTDOWN_TIMEOUT"); timeout != "" {
+		if shutdownTimeout, err = time.ParseDuration(timeout); err != nil || shutdownTimeout <= 0 {
+			fatal("Error reading SHUTDOWN_TIMEOUT", fmt.Errorf("%q is not a positive duration", timeout))
+		}
+	}
+
+	// Fail readiness for this long before draining, so load balancers notice first
+	drainDelay := 5 * time.Second
+	if delay := os.Getenv("SHUTDOWN_DRAIN_DELAY"); delay != "" {
+		if drainDelay, err = time.ParseDuration(delay); err != nil || drainDelay < 0 {
+			fatal("Error reading SHUTDOWN_DRAIN_DELAY", fmt.Errorf("%q is not a duration", delay))
+		}
+	}
+
+	// Log connection pool statistics every DB_STATS_INTERVAL
+	poolStatsInterval := time.Minute
+	if interval := os.Getenv("DB_STATS_INTERVAL"); interval != "" {
+		if poolStatsInterval, err = time.ParseDuration(interval); err != nil || poolStatsInterval <= 0 {
+			fatal("Error reading DB_STATS_INTERVAL", fmt.Errorf("%q is not a positive duration", interval))
+		}
+	}
+
+	// Learn about changes made by every instance from the database, so that
+	// cached responses are dropped everywhere
+	feed := changefeed.New()
+	cacheChanges, unsubscribeCache := feed.Subscribe(256)
+
+	// Run background jobs until the server shuts down
+	background, stopBackground := context.WithCancel(context.Background())
+	var jobs sync.WaitGroup
+	for _, job := range []func(context.Context){
+		func(ctx context.Context) { controllers.RunTrashPurge(ctx, database, purgeInterval) },
+		func(ctx context.Context) { middleware.RunIdempotencyKeyPurge(ctx, database, time.Hour) },
+		func(ctx context.Context) { db.RunPoolStatsLogger(ctx, database, poolStatsInterval) },
+		func(ctx context.Context) { feed.Run(ctx, db.ConnString(), db.ChangesChannel) },
+		func(ctx context.Context) { controllers.RunCacheInvalidation(ctx, cacheChanges) },
+	} {
+		jobs.Add(1)
+		go func(job func(context.Context)) {
+			defer jobs.Done()
+			job(background)
+		}(job)
 	}
-	defer database.Close()
 
 	// Serve Swagger UI files
 	router.Static("/docs", "./docs")
 	url := ginSwagger.URL("/docs/swagger.json")
 	router.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler, url))
 
+	// Health checks and metrics bypass the middleware below
+	routes.SetupHealthRoutes(router, database)
+	metrics.RegisterDB(database)
+	router.GET("/metrics", gin.WrapH(metrics.Handler()))
+
 	// CORS for https://foo.com and https://github.com origins, allowing:
-	// - GET and POST methods
-	// - "Authorization" and "Content-Type" headers
+	// - GET, POST, PUT, PATCH and DELETE methods
+	// - "Authorization", "Content-Type", "X-Household-ID", "X-Request-ID", "Idempotency-Key" and W3C trace context headers
 	// - Credentials share
 	router.Use(cors.New(cors.Config{
 		AllowOrigins:     []string{"https://foo.com", "https://github.com"},
-		AllowMethods:     []string{"GET", "POST"},
-		AllowHeaders:     []string{"Authorization", "Content-Type"},
+		AllowMethods:     []string{"GET", "POST", "PUT", "PATCH", "DELETE"},
+		AllowHeaders:     []string{"Authorization", "Content-Type", middleware.HouseholdIDHeader, middleware.RequestIDHeader, middleware.IdempotencyKeyHeader, "If-Match", "If-None-Match", "traceparent", "tracestate"},
+		ExposeHeaders:    []string{middleware.RequestIDHeader, middleware.IdempotentReplayedHeader, "ETag", "Retry-After"},
 		AllowCredentials: true,
 	}))
 
+	// Trace, count and time every request by its route
+	router.Use(tracing.HTTP())
+	router.Use(metrics.HTTP())
+
+	// Tag every request with an ID and a logger, log it once handled and render errors as problem details, then resolve the caller and the household it acts in, and replay retried POST requests
+	router.Use(middleware.RequestID())
+	router.Use(middleware.AccessLog())
+	router.Use(middleware.Errors())
+	router.Use(middleware.Identity(database))
+	router.Use(middleware.Household(database))
+	router.Use(middleware.Policy(authz))
+	router.Use(middleware.Idempotency(database))
+
 	// Routes
 	routes.SetupIngredientsRoutes(router, database)
 	routes.SetupRecipeRoutes(router, database)
 	routes.SetupRecipeIngredientsRoutes(router, database)
 	routes.SetupRecipeStepsRoutes(router, database)
+	routes.SetupHouseholdRoutes(router, database)
+	routes.SetupAPIKeyRoutes(router, database)
+	routes.SetupIngredientProposalRoutes(router, database)
+	routes.SetupUserRoleRoutes(router, database)
+	routes.SetupAuditRoutes(router, database)
+	routes.SetupTrashRoutes(router, database)
+	routes.SetupAdminRoutes(router)
+
+	// Serve with timeouts, so slow clients cannot hold connections forever
+	server := &http.Server{
+		Handler:           router,
+		ReadHeaderTimeout: 10 * time.Second,
+		ReadTimeout:       30 * time.Second,
+		WriteTimeout:      60 * time.Second,
+		IdleTimeout:       120 * time.Second,
+	}
+	listener, err := net.Listen("tcp", ":8080")
+	if err != nil {
+		fatal("Error starting server", err)
+	}
+	slog.Info("Server is running", "url", fmt.Sprintf("http://localhost:%d", listener.Addr().(*net.TCPAddr).Port))
+
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- server.Serve(listener)
+	}()
 
-	// Run the server
-	if err := router.Run(":8080"); err != nil {
-		log.Fatalf("Error starting server: %v", err)
+	// Run until SIGINT or SIGTERM, then drain in-flight requests before closing the database
+	signals, stopSignals := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stopSignals()
+	failed := false
+	select {
+	case err := <-serveErr:
+		slog.Error("Error serving", "error", err)
+		failed = true
+	case <-signals.Done():
+		slog.Info("Shutting down, waiting for in-flight requests", "timeout", shutdownTimeout.String())
+		// Fail readiness first, so load balancers stop routing traffic here before the listener closes
+		controllers.SetShuttingDown()
+		time.Sleep(drainDelay)
 	}
+	stopSignals()
+
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := server.Shutdown(ctx); err != nil {
+		slog.Error("Error draining connections", "error", err)
+	}
+	stopBackground()
+	// Ending the subscription closes its channel, which also ends the cache
+	// invalidation
+	unsubscribeCache()
+	jobs.Wait()
+	if err := database.Close(); err != nil {
+		slog.Error("Error closing database", "error", err)
+	}
+	if err := shutdownTracing(ctx); err != nil {
+		slog.Error("Error flushing traces", "error", err)
+	}
+	if failed {
+		os.Exit(1)
+	}
+	slog.Info("Server stopped")
+}
 
-	fmt.Println("Server is running on http://localhost:8080")
+// fatal logs err and exits.
+func fatal(msg string, err error) {
+	slog.Error(msg, "error", err)
+	os.Exit(1)
 }
@@ -0,0 +1,22 @@
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var cacheLookups = promauto.NewCounterVec(prometheus.CounterOpts{
+	Namespace: namespace,
+	Name:      "cache_lookups_total",
+	Help:      "Response cache lookups, by cache namespace and result.",
+}, []string{"cache", "result"})
+
+// ObserveCacheLookup counts a lookup in the response cache namespace as a hit
+// or a miss.
+func ObserveCacheLookup(namespace string, hit bool) {
+	result := "miss"
+	if hit {
+		result = "hit"
+	}
+	cacheLookups.WithLabelValues(namespace, result).Inc()
+}
@@ -0,0 +1,78 @@
+package metrics
+
+import (
+	"context"
+	"database/sql"
+	"log/slog"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// domainTimeout bounds the queries counting domain objects on each scrape.
+const domainTimeout = 2 * time.Second
+
+var queryDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+	Namespace: namespace,
+	Name:      "db_query_duration_seconds",
+	Help:      "Time until the database answered a statement, by SQL operation and outcome.",
+	Buckets:   []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5},
+}, []string{"operation", "outcome"})
+
+// RegisterDB exports the connection pool statistics of db and the number of
+// recipes and ingredients it holds.
+func RegisterDB(db *sql.DB) {
+	prometheus.MustRegister(collectors.NewDBStatsCollector(db, "postgres"))
+	prometheus.MustRegister(&domainCollector{db: db})
+}
+
+// ObserveQuery is a db.QueryHook timing statements. Statements are labelled
+// by their SQL command only, which keeps the label set bounded.
+func ObserveQuery(ctx context.Context, query, operation string) func(error) {
+	start := time.Now()
+	return func(err error) {
+		outcome := "ok"
+		if err != nil {
+			outcome = "error"
+		}
+		queryDuration.WithLabelValues(operation, outcome).Observe(time.Since(start).Seconds())
+	}
+}
+
+// domainCollector counts recipes and ingredients, live and in the trash, on
+// every scrape.
+type domainCollector struct {
+	db *sql.DB
+}
+
+var domainObjects = prometheus.NewDesc(
+	prometheus.BuildFQName(namespace, "", "domain_objects"),
+	"Number of recipes and ingredients, by entity type and state.",
+	[]string{"entity_type", "state"}, nil,
+)
+
+func (d *domainCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- domainObjects
+}
+
+func (d *domainCollector) Collect(ch chan<- prometheus.Metric) {
+	ctx, cancel := context.WithTimeout(context.Background(), domainTimeout)
+	defer cancel()
+
+	for _, entity := range []struct{ entityType, table string }{
+		{"recipe", "recipes"},
+		{"ingredient", "ingredients"},
+	} {
+		sqlQuery := `SELECT COUNT(*) FILTER (WHERE deleted_at IS NULL), COUNT(*) FILTER (WHERE deleted_at IS NOT NULL) FROM ` + entity.table
+		var live, trashed float64
+		if err := d.db.QueryRowContext(ctx, sqlQuery).Scan(&live, &trashed); err != nil {
+			// Skip the gauges rather than failing the whole scrape.
+			slog.Error("metrics: error counting domain objects", "table", entity.table, "error", err)
+			continue
+		}
+		ch <- prometheus.MustNewConstMetric(domainObjects, prometheus.GaugeValue, live, entity.entityType, "live")
+		ch <- prometheus.MustNewConstMetric(domainObjects, prometheus.GaugeValue, trashed, entity.entityType, "trashed")
+	}
+}
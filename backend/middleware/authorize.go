@@ -0,0 +1,66 @@
+package middleware
+
+import (
+	"backend/apierror"
+	"backend/policy"
+
+	"github.com/gin-gonic/gin"
+)
+
+// policyKey is the gin context key holding the authorization policy.
+const policyKey = "policy"
+
+// Policy makes the authorization policy available to every request.
+func Policy(p *policy.Policy) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Set(policyKey, p)
+		c.Next()
+	}
+}
+
+// Roles returns the policy roles of the caller: their global role and, when
+// acting in a household, their household role.
+func Roles(c *gin.Context) []string {
+	role := c.GetString(roleKey)
+	if role == "" {
+		role = policy.RoleAnonymous
+	}
+	roles := []string{role}
+
+	if _, householdRole, ok := CurrentHousehold(c); ok {
+		roles = append(roles, policy.HouseholdRolePrefix+householdRole)
+	}
+	return roles
+}
+
+// Evaluate evaluates the policy for the caller. Requests are denied if no
+// policy has been installed.
+func Evaluate(c *gin.Context, resource, action string) policy.Decision {
+	value, _ := c.Get(policyKey)
+	p, ok := value.(*policy.Policy)
+	if !ok {
+		return policy.Decision{Resource: resource, Action: action, Reason: policy.ReasonNoMatchingRole}
+	}
+	return p.Evaluate(Roles(c), resource, action)
+}
+
+// Can reports whether the caller may perform action on resource.
+func Can(c *gin.Context, resource, action string) bool {
+	return Evaluate(c, resource, action).Allowed
+}
+
+// Authorize rejects requests the policy denies with 403 Forbidden and a
+// machine-readable reason.
+func Authorize(resource, action string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		decision := Evaluate(c, resource, action)
+		if !decision.Allowed {
+			abortWith(c, apierror.Forbidden("You are not allowed to "+action+" "+resource).
+				With("reason", decision.Reason).
+				With("resource", decision.Resource).
+				With("action", decision.Action))
+			return
+		}
+		c.Next()
+	}
+}
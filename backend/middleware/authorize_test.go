@@ -0,0 +1,70 @@
+package middleware
+
+import (
+	"backend/policy"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestAuthorize(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name          string
+		role          string // global role; empty for anonymous callers
+		householdRole string // empty outside of households
+		resource      string
+		action        string
+		want          int
+	}{
+		{name: "anonymous reads recipes", resource: policy.ResourceRecipes, action: policy.ActionRead, want: http.StatusNoContent},
+		{name: "anonymous creates recipes", resource: policy.ResourceRecipes, action: policy.ActionCreate, want: http.StatusForbidden},
+		{name: "user reads user roles", role: policy.RoleUser, resource: policy.ResourceUserRoles, action: policy.ActionRead, want: http.StatusForbidden},
+		{name: "user changes user roles", role: policy.RoleUser, resource: policy.ResourceUserRoles, action: policy.ActionUpdate, want: http.StatusForbidden},
+		{name: "admin changes user roles", role: policy.RoleAdmin, resource: policy.ResourceUserRoles, action: policy.ActionUpdate, want: http.StatusNoContent},
+		{name: "household editor creates ingredients", role: policy.RoleUser, householdRole: "editor", resource: policy.ResourceIngredients, action: policy.ActionCreate, want: http.StatusNoContent},
+		{name: "household viewer creates ingredients", role: policy.RoleUser, householdRole: "viewer", resource: policy.ResourceIngredients, action: policy.ActionCreate, want: http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := gin.New()
+			router.Use(Errors(), Policy(policy.Default()), func(c *gin.Context) {
+				if tt.role != "" {
+					c.Set(userIDKey, 1)
+					c.Set(roleKey, tt.role)
+				}
+				if tt.householdRole != "" {
+					c.Set(householdIDKey, 1)
+					c.Set(householdRoleKey, tt.householdRole)
+				}
+			})
+			router.GET("/", Authorize(tt.resource, tt.action), func(c *gin.Context) {
+				c.Status(http.StatusNoContent)
+			})
+
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+			if w.Code != tt.want {
+				t.Errorf("status = %d, want %d: %s", w.Code, tt.want, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestAuthorizeWithoutPolicy(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(Errors())
+	router.GET("/", Authorize(policy.ResourceRecipes, policy.ActionRead), func(c *gin.Context) {
+		c.Status(http.StatusNoContent)
+	})
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	if w.Code != http.StatusForbidden {
+		t.Errorf("status = %d, want %d", w.Code, http.StatusForbidden)
+	}
+}
@@ -0,0 +1,57 @@
+package middleware
+
+import (
+	"backend/apierror"
+	"errors"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Errors renders the last error a handler reported with c.Error as problem
+// details. Errors that are not API errors are reported as internal errors.
+// It must run before any handler that reports errors.
+func Errors() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Next()
+		renderError(c)
+	}
+}
+
+// renderError writes the last error reported with c.Error as problem details,
+// unless a response has been written already.
+func renderError(c *gin.Context) {
+	if len(c.Errors) == 0 || c.Writer.Written() {
+		return
+	}
+
+	err := c.Errors.Last().Err
+	var apiErr *apierror.Error
+	if !errors.As(err, &apiErr) {
+		apiErr = apierror.Internal("Internal server error").WithCause(err)
+	}
+	// Failures caused by the database being slow or out of reach are
+	// reported as such, whatever the handler made of them.
+	if apiErr.Code == apierror.CodeInternal {
+		if transient := apierror.Transient(apiErr.Cause); transient != nil {
+			transient.Cause = apiErr
+			apiErr = transient
+		}
+	}
+	switch apiErr.Code {
+	case apierror.CodeInternal:
+		Logger(c).Error("internal error", "error", apiErr)
+	case apierror.CodeTimeout, apierror.CodeUnavailable:
+		Logger(c).Warn("database did not complete the request", "error", apiErr)
+		c.Header("Retry-After", "1")
+	}
+
+	problem := apierror.NewProblem(apiErr, c.Request.URL.Path, CurrentRequestID(c))
+	c.Header("Content-Type", apierror.ContentType)
+	c.JSON(problem.Status, problem)
+}
+
+// abortWith stops the request and reports err to the Errors middleware.
+func abortWith(c *gin.Context, err *apierror.Error) {
+	_ = c.Error(err)
+	c.Abort()
+}
@@ -0,0 +1,73 @@
+package middleware
+
+import (
+	"backend/apierror"
+	"database/sql"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// HouseholdIDHeader selects the household a request acts in. Without it the
+// request acts on the caller's personal data.
+const HouseholdIDHeader = "X-Household-ID"
+
+// Gin context keys holding the caller's household and role in it.
+const (
+	householdIDKey   = "householdID"
+	householdRoleKey = "householdRole"
+)
+
+// Household resolves the household selected by the request to the caller's
+// membership and stores it in the context. It must run after Identity.
+func Household(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// 1. Read the household header, if any.
+		householdIDStr := c.GetHeader(HouseholdIDHeader)
+		if householdIDStr == "" {
+			c.Next()
+			return
+		}
+
+		householdID, err := strconv.Atoi(householdIDStr)
+		if err != nil {
+			abortWith(c, apierror.BadRequest("Invalid household ID"))
+			return
+		}
+
+		// 2. Only identified callers can act in a household.
+		userID, ok := CurrentUserID(c)
+		if !ok {
+			abortWith(c, apierror.Unauthorized("Authentication required"))
+			return
+		}
+
+		// 3. Resolve the caller's membership.
+		sqlQuery := `SELECT role FROM household_members WHERE household_id = $1 AND user_id = $2`
+		var role string
+		err = db.QueryRowContext(c.Request.Context(), sqlQuery, householdID, userID).Scan(&role)
+		if err == sql.ErrNoRows {
+			abortWith(c, apierror.Forbidden("You are not a member of this household"))
+			return
+		}
+		if err != nil {
+			abortWith(c, apierror.Internal("Error checking household membership").WithCause(err))
+			return
+		}
+
+		// 4. Store the household in the request context.
+		c.Set(householdIDKey, householdID)
+		c.Set(householdRoleKey, role)
+		c.Next()
+	}
+}
+
+// CurrentHousehold returns the household the request acts in and the caller's
+// role in it, and whether the request is scoped to a household at all.
+func CurrentHousehold(c *gin.Context) (int, string, bool) {
+	householdID, ok := c.Get(householdIDKey)
+	if !ok {
+		return 0, "", false
+	}
+	return householdID.(int), c.GetString(householdRoleKey), true
+}
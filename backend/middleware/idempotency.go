@@ -0,0 +1,236 @@
+package middleware
+
+import (
+	"backend/apierror"
+	"backend/tracing"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// IdempotencyKeyHeader lets clients retry a POST request safely: requests
+// repeating a key get the response of the first one instead of running again.
+const IdempotencyKeyHeader = "Idempotency-Key"
+
+// IdempotentReplayedHeader marks responses replayed for a repeated key.
+const IdempotentReplayedHeader = "Idempotent-Replayed"
+
+// IdempotencyKeyTTL is how long a key is remembered. Afterwards it may be
+// reused for a new request.
+var IdempotencyKeyTTL = 24 * time.Hour
+
+// idempotencyWriteTimeout bounds storing a response or releasing a key. Both
+// run after the handler and must finish even if the client has gone away.
+const idempotencyWriteTimeout = 5 * time.Second
+
+// maxIdempotencyKeyLength bounds idempotency keys accepted from clients.
+const maxIdempotencyKeyLength = 255
+
+// replayedHeaders are the response headers stored and replayed with a response.
+var replayedHeaders = []string{"Content-Type", "ETag", "Location"}
+
+// Idempotency honors IdempotencyKeyHeader on POST requests. The first request
+// with a key runs and its response is stored along with a fingerprint of the
+// request; retries with the same key and fingerprint get the stored response,
+// while reusing the key for a different request is rejected with 422. Keys are
+// scoped to the caller. Requests that fail without a response, or with a
+// server error, release the key so they can be retried. It must run after
+// Identity and Household.
+func Idempotency(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// 1. Only identified callers' POST requests carrying a key are deduplicated.
+		key := c.GetHeader(IdempotencyKeyHeader)
+		userID, identified := CurrentUserID(c)
+		if c.Request.Method != http.MethodPost || key == "" || !identified {
+			c.Next()
+			return
+		}
+		if !printable(key, maxIdempotencyKeyLength) {
+			abortWith(c, apierror.BadRequest("Invalid idempotency key"))
+			return
+		}
+
+		// 2. Fingerprint the request, keeping the body readable for the handler.
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			abortWith(c, apierror.BadRequest("Error reading request body"))
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+		fingerprint := requestFingerprint(c, body)
+
+		// 3. Claim the key, unless an earlier request holds it.
+		sqlQuery := `
+			INSERT INTO idempotency_keys (user_id, idempotency_key, fingerprint)
+			VALUES ($1, $2, $3)
+			ON CONFLICT (user_id, idempotency_key) DO UPDATE
+			SET fingerprint = EXCLUDED.fingerprint, status = NULL, headers = NULL, body = NULL, created_at = NOW()
+			WHERE idempotency_keys.created_at < NOW() - $4 * INTERVAL '1 second'
+			RETURNING user_id`
+		err = db.QueryRowContext(c.Request.Context(), sqlQuery, userID, key, fingerprint, IdempotencyKeyTTL.Seconds()).Scan(&userID)
+		if err == sql.ErrNoRows {
+			replay(c, db, userID, key, fingerprint)
+			return
+		}
+		if err != nil {
+			abortWith(c, apierror.Internal("Error checking idempotency key").WithCause(err))
+			return
+		}
+
+		// 4. Run the request, capturing its response, and store it under the key.
+		recorder := &responseRecorder{ResponseWriter: c.Writer}
+		c.Writer = recorder
+		stored := false
+		defer func() {
+			if !stored {
+				releaseIdempotencyKey(c, db, userID, key)
+			}
+		}()
+
+		c.Next()
+		// Errors reported by the handler are rendered here rather than by
+		// Errors, so that they are stored and replayed like other responses.
+		renderError(c)
+
+		if !recorder.Written() || recorder.Status() >= http.StatusInternalServerError {
+			return
+		}
+		headers := map[string]string{}
+		for _, name := range replayedHeaders {
+			if value := recorder.Header().Get(name); value != "" {
+				headers[name] = value
+			}
+		}
+		encoded, err := json.Marshal(headers)
+		if err != nil {
+			Logger(c).Error("error encoding idempotent response headers", "error", err)
+			return
+		}
+		sqlQuery = `UPDATE idempotency_keys SET status = $3, headers = $4, body = $5 WHERE user_id = $1 AND idempotency_key = $2`
+		ctx, cancel := detachedContext(c)
+		defer cancel()
+		if _, err := db.ExecContext(ctx, sqlQuery, userID, key, recorder.Status(), string(encoded), recorder.body.Bytes()); err != nil {
+			Logger(c).Error("error storing idempotent response", "error", err)
+			return
+		}
+		stored = true
+	}
+}
+
+// replay answers a request whose key is already taken with the stored
+// response of the first request.
+func replay(c *gin.Context, db *sql.DB, userID int, key, fingerprint string) {
+	sqlQuery := `SELECT fingerprint, status, headers, body FROM idempotency_keys WHERE user_id = $1 AND idempotency_key = $2`
+
+	var storedFingerprint string
+	var status sql.NullInt64
+	var headers, body []byte
+	err := db.QueryRowContext(c.Request.Context(), sqlQuery, userID, key).Scan(&storedFingerprint, &status, &headers, &body)
+	if err == sql.ErrNoRows {
+		// The first request failed and released the key in the meantime.
+		abortWith(c, apierror.Conflict("The request with this idempotency key failed; retry it"))
+		return
+	}
+	if err != nil {
+		abortWith(c, apierror.Internal("Error checking idempotency key").WithCause(err))
+		return
+	}
+
+	if storedFingerprint != fingerprint {
+		abortWith(c, apierror.IdempotencyKeyReused("Idempotency key was already used for a different request"))
+		return
+	}
+	if !status.Valid {
+		abortWith(c, apierror.Conflict("A request with this idempotency key is still in progress"))
+		return
+	}
+
+	var stored map[string]string
+	if err := json.Unmarshal(headers, &stored); err != nil {
+		abortWith(c, apierror.Internal("Error reading idempotent response").WithCause(err))
+		return
+	}
+	for name, value := range stored {
+		c.Header(name, value)
+	}
+	c.Header(IdempotentReplayedHeader, "true")
+	c.Data(int(status.Int64), stored["Content-Type"], body)
+	c.Abort()
+}
+
+// releaseIdempotencyKey forgets a key whose request produced no response worth
+// replaying, so that it can be retried.
+func releaseIdempotencyKey(c *gin.Context, db *sql.DB, userID int, key string) {
+	sqlQuery := `DELETE FROM idempotency_keys WHERE user_id = $1 AND idempotency_key = $2 AND status IS NULL`
+	ctx, cancel := detachedContext(c)
+	defer cancel()
+	if _, err := db.ExecContext(ctx, sqlQuery, userID, key); err != nil {
+		Logger(c).Error("error releasing idempotency key", "error", err)
+	}
+}
+
+// detachedContext returns a context carrying the request's values, such as its
+// trace, that is not cancelled when the client disconnects: a key left
+// claimed would block retries until it expires.
+func detachedContext(c *gin.Context) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(context.WithoutCancel(c.Request.Context()), idempotencyWriteTimeout)
+}
+
+// requestFingerprint identifies a request by its target, the household it acts
+// in and its body.
+func requestFingerprint(c *gin.Context, body []byte) string {
+	hash := sha256.New()
+	for _, part := range []string{c.Request.Method, c.Request.URL.RequestURI(), c.GetHeader(HouseholdIDHeader)} {
+		hash.Write([]byte(part))
+		hash.Write([]byte{0})
+	}
+	hash.Write(body)
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// RunIdempotencyKeyPurge forgets expired idempotency keys every interval until
+// ctx is done.
+func RunIdempotencyKeyPurge(ctx context.Context, db *sql.DB, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		runCtx, span := tracing.Start(ctx, "idempotency key purge")
+		sqlQuery := `DELETE FROM idempotency_keys WHERE created_at < NOW() - $1 * INTERVAL '1 second'`
+		if _, err := db.ExecContext(runCtx, sqlQuery, IdempotencyKeyTTL.Seconds()); err != nil {
+			slog.Error("idempotency key purge failed", "error", err)
+		}
+		span.End()
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// responseRecorder copies the response body written by handlers.
+type responseRecorder struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *responseRecorder) Write(data []byte) (int, error) {
+	w.body.Write(data)
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *responseRecorder) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
@@ -0,0 +1,239 @@
+package middleware
+
+import (
+	"backend/apierror"
+	"backend/auth"
+	"backend/policy"
+	"database/sql"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lib/pq"
+)
+
+// UserIDHeader carries the ID of the calling user. It is set by the
+// authenticating proxy in front of the API, and only trusted on requests
+// coming from one of TrustedProxies.
+const UserIDHeader = "X-User-ID"
+
+// TrustedProxies are the networks of the authenticating proxies allowed to set
+// UserIDHeader. The header is removed from requests coming from anywhere else,
+// so that clients cannot claim an identity themselves. Without trusted
+// proxies, callers are only identified by API keys.
+var TrustedProxies []netip.Prefix
+
+// ParseTrustedProxies parses a comma-separated list of IP addresses and CIDR
+// networks, such as "10.0.0.0/8, 192.168.1.10".
+func ParseTrustedProxies(value string) ([]netip.Prefix, error) {
+	var proxies []netip.Prefix
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		if !strings.Contains(entry, "/") {
+			addr, err := netip.ParseAddr(entry)
+			if err != nil {
+				return nil, fmt.Errorf("invalid trusted proxy %q", entry)
+			}
+			entry = netip.PrefixFrom(addr.Unmap(), addr.Unmap().BitLen()).String()
+		}
+		prefix, err := netip.ParsePrefix(entry)
+		if err != nil {
+			return nil, fmt.Errorf("invalid trusted proxy %q", entry)
+		}
+		proxies = append(proxies, prefix.Masked())
+	}
+	return proxies, nil
+}
+
+// fromTrustedProxy reports whether the request's direct peer is one of
+// TrustedProxies. Forwarding headers are ignored, since clients set them freely.
+func fromTrustedProxy(r *http.Request) bool {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return false
+	}
+	addr, err := netip.ParseAddr(host)
+	if err != nil {
+		return false
+	}
+	addr = addr.Unmap()
+	for _, proxy := range TrustedProxies {
+		if proxy.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// APIKeyPrefix starts every API key, so keys are recognisable in the
+// Authorization header and in secret scanners.
+const APIKeyPrefix = "iwct_"
+
+// Gin context keys holding the caller's identity.
+const (
+	userIDKey   = "userID"
+	roleKey     = "role"
+	apiKeyIDKey = "apiKeyID"
+	scopesKey   = "scopes"
+)
+
+// Identity resolves the caller from the request and stores it in the context.
+// Machine clients authenticate with "Authorization: Bearer <api key>"; other
+// callers are identified by UserIDHeader, as set by a trusted proxy. Requests
+// without an identity continue as anonymous callers.
+func Identity(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// 1. Only trusted proxies may vouch for the caller; drop the header otherwise.
+		if !fromTrustedProxy(c.Request) {
+			c.Request.Header.Del(UserIDHeader)
+		}
+
+		// 2. An API key takes precedence over the user ID header.
+		if authorization := c.GetHeader("Authorization"); authorization != "" {
+			authenticateAPIKey(c, db, authorization)
+			return
+		}
+
+		// 3. Read the user ID header, if any.
+		userIDStr := c.GetHeader(UserIDHeader)
+		if userIDStr == "" {
+			c.Next()
+			return
+		}
+
+		// 4. Reject malformed identities instead of treating them as anonymous.
+		userID, err := strconv.Atoi(userIDStr)
+		if err != nil || userID <= 0 {
+			abortWith(c, apierror.Unauthorized("Invalid user ID"))
+			return
+		}
+
+		// 5. Store the caller and their role in the request context.
+		if !setUser(c, db, userID) {
+			return
+		}
+		c.Next()
+	}
+}
+
+// authenticateAPIKey resolves a bearer API key to its user and scopes and
+// records its use.
+func authenticateAPIKey(c *gin.Context, db *sql.DB, authorization string) {
+	key, ok := strings.CutPrefix(authorization, "Bearer ")
+	if !ok || !strings.HasPrefix(key, APIKeyPrefix) {
+		abortWith(c, apierror.Unauthorized("Invalid authorization header"))
+		return
+	}
+
+	sqlQuery := `
+		UPDATE api_keys
+		SET last_used_at = NOW()
+		WHERE key_hash = $1 AND revoked_at IS NULL
+		RETURNING api_key_id, user_id, scopes`
+
+	var apiKeyID, userID int
+	var scopes []string
+	err := db.QueryRowContext(c.Request.Context(), sqlQuery, auth.HashToken(key)).Scan(&apiKeyID, &userID, pq.Array(&scopes))
+	if err == sql.ErrNoRows {
+		abortWith(c, apierror.Unauthorized("Invalid or revoked API key"))
+		return
+	}
+	if err != nil {
+		abortWith(c, apierror.Internal("Error checking API key").WithCause(err))
+		return
+	}
+
+	if !setUser(c, db, userID) {
+		return
+	}
+	c.Set(apiKeyIDKey, apiKeyID)
+	c.Set(scopesKey, scopes)
+	c.Next()
+}
+
+// setUser stores the caller and their global role in the context. Users
+// without an assigned role are regular users. It returns false once the
+// request has been aborted.
+func setUser(c *gin.Context, db *sql.DB, userID int) bool {
+	sqlQuery := `SELECT role FROM user_roles WHERE user_id = $1`
+
+	role := policy.RoleUser
+	err := db.QueryRowContext(c.Request.Context(), sqlQuery, userID).Scan(&role)
+	if err != nil && err != sql.ErrNoRows {
+		abortWith(c, apierror.Internal("Error checking user role").WithCause(err))
+		return false
+	}
+
+	c.Set(userIDKey, userID)
+	c.Set(roleKey, role)
+	return true
+}
+
+// CurrentUserID returns the caller's user ID and whether the caller is identified.
+func CurrentUserID(c *gin.Context) (int, bool) {
+	userID, ok := c.Get(userIDKey)
+	if !ok {
+		return 0, false
+	}
+	id, ok := userID.(int)
+	return id, ok
+}
+
+// RequireIdentity rejects anonymous callers with 401 Unauthorized.
+func RequireIdentity(c *gin.Context) (int, bool) {
+	userID, ok := CurrentUserID(c)
+	if !ok {
+		c.Error(apierror.Unauthorized("Authentication required"))
+		return 0, false
+	}
+	return userID, true
+}
+
+// UsingAPIKey reports whether the caller authenticated with an API key.
+func UsingAPIKey(c *gin.Context) bool {
+	_, ok := c.Get(apiKeyIDKey)
+	return ok
+}
+
+// HasScope reports whether the caller may act within scope. Only API keys are
+// limited by scopes; other callers hold every scope.
+func HasScope(c *gin.Context, scope string) bool {
+	if !UsingAPIKey(c) {
+		return true
+	}
+	for _, granted := range c.GetStringSlice(scopesKey) {
+		if granted == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// RequireScope rejects API keys lacking scope with 403 Forbidden.
+func RequireScope(scope string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !HasScope(c, scope) {
+			abortWith(c, apierror.Forbidden("API key is missing the "+scope+" scope"))
+			return
+		}
+		c.Next()
+	}
+}
+
+// RejectAPIKeys keeps API keys away from account management endpoints.
+func RejectAPIKeys() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if UsingAPIKey(c) {
+			abortWith(c, apierror.Forbidden("This endpoint cannot be used with an API key"))
+			return
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,101 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestParseTrustedProxies(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    []string
+		wantErr bool
+	}{
+		{value: "", want: nil},
+		{value: "10.0.0.0/8", want: []string{"10.0.0.0/8"}},
+		{value: "10.1.2.3/8, 192.168.1.10", want: []string{"10.0.0.0/8", "192.168.1.10/32"}},
+		{value: "::1", want: []string{"::1/128"}},
+		{value: "proxy.internal", wantErr: true},
+		{value: "10.0.0.0/33", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			got, err := ParseTrustedProxies(tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseTrustedProxies(%q) error = %v, want error %v", tt.value, err, tt.wantErr)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("ParseTrustedProxies(%q) = %v, want %v", tt.value, got, tt.want)
+			}
+			for i := range got {
+				if got[i].String() != tt.want[i] {
+					t.Errorf("ParseTrustedProxies(%q)[%d] = %v, want %v", tt.value, i, got[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestIdentityIgnoresUserIDHeaderFromUntrustedPeers(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	TrustedProxies = []netip.Prefix{netip.MustParsePrefix("10.0.0.0/8")}
+	defer func() { TrustedProxies = nil }()
+
+	for _, remoteAddr := range []string{"203.0.113.7:51000", "[2001:db8::1]:51000", "unix"} {
+		t.Run(remoteAddr, func(t *testing.T) {
+			router := gin.New()
+			// No statement runs for anonymous callers, so no database is needed.
+			router.Use(Identity(nil))
+			router.GET("/", func(c *gin.Context) {
+				if userID, ok := CurrentUserID(c); ok {
+					t.Errorf("caller identified as user %d", userID)
+				}
+				if header := c.GetHeader(UserIDHeader); header != "" {
+					t.Errorf("%s = %q reached the handler", UserIDHeader, header)
+				}
+				c.Status(http.StatusNoContent)
+			})
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.RemoteAddr = remoteAddr
+			req.Header.Set(UserIDHeader, "1")
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+			if w.Code != http.StatusNoContent {
+				t.Errorf("status = %d, want %d", w.Code, http.StatusNoContent)
+			}
+		})
+	}
+}
+
+func TestFromTrustedProxy(t *testing.T) {
+	TrustedProxies = []netip.Prefix{netip.MustParsePrefix("10.0.0.0/8"), netip.MustParsePrefix("::1/128")}
+	defer func() { TrustedProxies = nil }()
+
+	tests := []struct {
+		remoteAddr string
+		forwarded  string
+		want       bool
+	}{
+		{remoteAddr: "10.2.3.4:8080", want: true},
+		{remoteAddr: "[::ffff:10.2.3.4]:8080", want: true},
+		{remoteAddr: "[::1]:8080", want: true},
+		{remoteAddr: "192.0.2.1:8080", want: false},
+		// Forwarding headers are set by clients and never make a peer trusted.
+		{remoteAddr: "192.0.2.1:8080", forwarded: "10.2.3.4", want: false},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.RemoteAddr = tt.remoteAddr
+		if tt.forwarded != "" {
+			req.Header.Set("X-Forwarded-For", tt.forwarded)
+		}
+		if got := fromTrustedProxy(req); got != tt.want {
+			t.Errorf("fromTrustedProxy(%s, X-Forwarded-For %q) = %v, want %v", tt.remoteAddr, tt.forwarded, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,72 @@
+package middleware
+
+import (
+	"backend/auth"
+	"backend/logging"
+	"backend/tracing"
+	"log/slog"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RequestIDHeader carries the ID correlating a request across services and logs.
+const RequestIDHeader = "X-Request-ID"
+
+// requestIDKey is the gin context key holding the request ID.
+const requestIDKey = "requestID"
+
+// maxRequestIDLength bounds request IDs accepted from clients.
+const maxRequestIDLength = 128
+
+// RequestID propagates the client's request ID, or assigns a new one, echoes
+// it in the response and gives the request a logger tagged with it and, when
+// traced, the trace ID.
+func RequestID() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		requestID := c.GetHeader(RequestIDHeader)
+		if !validRequestID(requestID) {
+			token, err := auth.NewToken()
+			if err == nil {
+				requestID = token[:32]
+			}
+		}
+
+		c.Set(requestIDKey, requestID)
+		c.Header(RequestIDHeader, requestID)
+		logger := slog.Default().With("request_id", requestID)
+		if traceID := tracing.TraceID(c.Request.Context()); traceID != "" {
+			logger = logger.With("trace_id", traceID)
+		}
+		c.Request = c.Request.WithContext(logging.WithLogger(c.Request.Context(), logger))
+		c.Next()
+	}
+}
+
+// CurrentRequestID returns the ID of the current request.
+func CurrentRequestID(c *gin.Context) string {
+	return c.GetString(requestIDKey)
+}
+
+// Logger returns the logger of the current request.
+func Logger(c *gin.Context) *slog.Logger {
+	return logging.FromContext(c.Request.Context())
+}
+
+// validRequestID reports whether a client-supplied request ID is safe to reuse.
+func validRequestID(id string) bool {
+	return printable(id, maxRequestIDLength)
+}
+
+// printable reports whether s is a non-empty string of at most max printable
+// ASCII characters without spaces, safe to store and echo back.
+func printable(s string, max int) bool {
+	if s == "" || len(s) > max {
+		return false
+	}
+	for _, r := range s {
+		if r < '!' || r > '~' {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,42 @@
+package models
+
+import "time"
+
+// API key scopes.
+const (
+	ScopeRecipesRead      = "recipes:read"      // read recipes, their ingredients and steps
+	ScopeRecipesWrite     = "recipes:write"     // modify recipes, their ingredients and steps
+	ScopeIngredientsRead  = "ingredients:read"  // read the ingredient catalog
+	ScopeIngredientsWrite = "ingredients:write" // modify the ingredient catalog
+)
+
+// APIKeyScopes lists every scope an API key can be granted.
+var APIKeyScopes = []string{ScopeRecipesRead, ScopeRecipesWrite, ScopeIngredientsRead, ScopeIngredientsWrite}
+
+type APIKeyRequest struct {
+	Name   string   `json:"name" binding:"required,notblank,max=255"`
+	Scopes []string `json:"scopes" binding:"required,min=1,dive,scope"`
+}
+
+// APIKey gives a machine client access on behalf of a user.
+// The key itself is only returned when it is created.
+type APIKey struct {
+	APIKeyID   int        `json:"api_key_id" db:"api_key_id"`
+	Name       string     `json:"name" db:"name"`
+	KeyPrefix  string     `json:"key_prefix" db:"key_prefix"`
+	Key        string     `json:"key,omitempty"`
+	Scopes     []string   `json:"scopes" db:"scopes"`
+	CreatedAt  time.Time  `json:"created_at" db:"created_at"`
+	LastUsedAt *time.Time `json:"last_used_at" db:"last_used_at"`
+	RevokedAt  *time.Time `json:"revoked_at" db:"revoked_at"`
+}
+
+// ValidScope reports whether s is a known API key scope.
+func ValidScope(s string) bool {
+	for _, scope := range APIKeyScopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
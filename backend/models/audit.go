@@ -0,0 +1,19 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// AuditEntry records one change to an entity. Entries are never modified.
+type AuditEntry struct {
+	AuditID    int             `json:"audit_id" db:"audit_id"`
+	ActorID    *int            `json:"actor_id" db:"actor_id"`
+	Action     string          `json:"action" db:"action"`
+	EntityType string          `json:"entity_type" db:"entity_type"`
+	EntityID   int             `json:"entity_id" db:"entity_id"`
+	Before     json.RawMessage `json:"before" db:"before" swaggertype:"object"`
+	After      json.RawMessage `json:"after" db:"after" swaggertype:"object"`
+	RequestID  string          `json:"request_id" db:"request_id"`
+	CreatedAt  time.Time       `json:"created_at" db:"created_at"`
+}
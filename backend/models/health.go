@@ -0,0 +1,24 @@
+package models
+
+// Health statuses of the service and its components.
+const (
+	HealthOK       = "ok"
+	HealthDegraded = "degraded"
+	HealthFailing  = "failing"
+)
+
+// Health reports whether the service is alive or ready, and why.
+type Health struct {
+	Status string `json:"status"`
+	// Components is omitted from liveness checks, which do not look at dependencies.
+	Components map[string]ComponentHealth `json:"components,omitempty"`
+}
+
+// ComponentHealth is the status of one dependency of the service.
+type ComponentHealth struct {
+	Status string `json:"status"`
+	// Detail explains a status other than ok, or describes the component.
+	Detail string `json:"detail,omitempty"`
+	// DurationMS is how long the check took, in milliseconds.
+	DurationMS int64 `json:"duration_ms"`
+}
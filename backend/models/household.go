@@ -0,0 +1,55 @@
+package models
+
+import "time"
+
+// Household member roles.
+const (
+	HouseholdRoleOwner  = "owner"  // manages members and invitations
+	HouseholdRoleEditor = "editor" // creates and edits household data
+	HouseholdRoleViewer = "viewer" // reads household data
+)
+
+type HouseholdRequest struct {
+	HouseholdName string `json:"household_name" binding:"required,notblank,max=255"`
+}
+
+// Household is a group of users sharing a recipe box.
+type Household struct {
+	HouseholdID   int    `json:"household_id" db:"household_id"`
+	HouseholdName string `json:"household_name" db:"household_name"`
+	Role          string `json:"role,omitempty" db:"role"`
+}
+
+type HouseholdMemberRequest struct {
+	Role string `json:"role" binding:"required,household_role"`
+}
+
+// HouseholdMember links a user to a household with a role.
+type HouseholdMember struct {
+	HouseholdID int    `json:"household_id" db:"household_id"`
+	UserID      int    `json:"user_id" db:"user_id"`
+	Role        string `json:"role" db:"role"`
+}
+
+type HouseholdInvitationRequest struct {
+	Role string `json:"role" binding:"required,household_role"`
+}
+
+type AcceptInvitationRequest struct {
+	Token string `json:"token" binding:"required,len=64,hexadecimal"`
+}
+
+// HouseholdInvitation invites whoever holds the token to join a household.
+// The token is only returned when the invitation is created.
+type HouseholdInvitation struct {
+	InvitationID int       `json:"invitation_id" db:"invitation_id"`
+	HouseholdID  int       `json:"household_id" db:"household_id"`
+	Role         string    `json:"role" db:"role"`
+	Token        string    `json:"token,omitempty"`
+	ExpiresAt    time.Time `json:"expires_at" db:"expires_at"`
+}
+
+// ValidHouseholdRole reports whether r is a known household role.
+func ValidHouseholdRole(r string) bool {
+	return r == HouseholdRoleOwner || r == HouseholdRoleEditor || r == HouseholdRoleViewer
+}
@@ -1,12 +1,19 @@
 package models
 
+import "time"
+
 type IngredientRequest struct {
-	IngredientName        string `json:"ingredient_name" binding:"required"`
-	IngredientDescription string `json:"ingredient_description"`
+	IngredientName        string `json:"ingredient_name" binding:"required,notblank,max=255"`
+	IngredientDescription string `json:"ingredient_description" binding:"max=10000"`
 }
 
 type Ingredient struct {
-	IngredientID          int    `json:"ingredient_id" db:"ingredient_id"`
-	IngredientName        string `json:"ingredient_name" db:"ingredient_name"`
-	IngredientDescription string `json:"ingredient_description" db:"ingredient_description"`
+	IngredientID          int       `json:"ingredient_id" db:"ingredient_id"`
+	IngredientName        string    `json:"ingredient_name" db:"ingredient_name"`
+	IngredientDescription string    `json:"ingredient_description" db:"ingredient_description"`
+	OwnerID               *int      `json:"owner_id" db:"owner_id"`
+	HouseholdID           *int      `json:"household_id" db:"household_id"`
+	Version               int       `json:"version" db:"version"`
+	CreatedAt             time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt             time.Time `json:"updated_at" db:"updated_at"`
 }
@@ -0,0 +1,29 @@
+package models
+
+import "time"
+
+// Ingredient proposal statuses.
+const (
+	ProposalPending  = "pending"
+	ProposalApproved = "approved"
+	ProposalRejected = "rejected"
+)
+
+type IngredientProposalRequest struct {
+	IngredientName        string `json:"ingredient_name" binding:"required,notblank,max=255"`
+	IngredientDescription string `json:"ingredient_description" binding:"max=10000"`
+}
+
+// IngredientProposal is a user's suggested addition to the shared ingredient
+// catalog, awaiting review by an admin.
+type IngredientProposal struct {
+	ProposalID            int        `json:"proposal_id" db:"proposal_id"`
+	IngredientName        string     `json:"ingredient_name" db:"ingredient_name"`
+	IngredientDescription string     `json:"ingredient_description" db:"ingredient_description"`
+	ProposedBy            int        `json:"proposed_by" db:"proposed_by"`
+	Status                string     `json:"status" db:"status"`
+	ReviewedBy            *int       `json:"reviewed_by" db:"reviewed_by"`
+	IngredientID          *int       `json:"ingredient_id" db:"ingredient_id"`
+	CreatedAt             time.Time  `json:"created_at" db:"created_at"`
+	ReviewedAt            *time.Time `json:"reviewed_at" db:"reviewed_at"`
+}
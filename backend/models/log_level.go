@@ -0,0 +1,6 @@
+package models
+
+// LogLevel is the minimum level of the messages the service logs.
+type LogLevel struct {
+	Level string `json:"level" binding:"required,oneof=debug info warn error"`
+}
@@ -1,15 +1,48 @@
 package models
 
+import "time"
+
+// Recipe visibility levels.
+const (
+	VisibilityPrivate = "private" // only the owner
+	VisibilityShared  = "shared"  // the owner and collaborators
+	VisibilityPublic  = "public"  // everyone
+)
+
 type RecipeRequest struct {
-	RecipeName        string `json:"recipe_name" db:"recipe_name"`
-	RecipeDescription string `json:"recipe_description" db:"recipe_description"`
-	CookTime          int    `json:"cook_time" db:"cook_time"`
+	RecipeName        string `json:"recipe_name" db:"recipe_name" binding:"required,notblank,max=255"`
+	RecipeDescription string `json:"recipe_description" db:"recipe_description" binding:"max=10000"`
+	CookTime          int    `json:"cook_time" db:"cook_time" binding:"gte=0,lte=10080"` // minutes, at most a week
+	Visibility        string `json:"visibility" db:"visibility" binding:"omitempty,visibility"`
 }
 
 // Recipe defines the structure for a recipe.
 type Recipe struct {
-	RecipeID          int    `json:"recipe_id" db:"recipe_id"`
-	RecipeName        string `json:"recipe_name" db:"recipe_name"`
-	RecipeDescription string `json:"recipe_description" db:"recipe_description"`
-	CookTime          int    `json:"cook_time" db:"cook_time"`
+	RecipeID          int       `json:"recipe_id" db:"recipe_id"`
+	RecipeName        string    `json:"recipe_name" db:"recipe_name"`
+	RecipeDescription string    `json:"recipe_description" db:"recipe_description"`
+	CookTime          int       `json:"cook_time" db:"cook_time"`
+	OwnerID           *int      `json:"owner_id" db:"owner_id"`
+	HouseholdID       *int      `json:"household_id" db:"household_id"`
+	Visibility        string    `json:"visibility" db:"visibility"`
+	Version           int       `json:"version" db:"version"`
+	CreatedAt         time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt         time.Time `json:"updated_at" db:"updated_at"`
+	ForkedFrom        *int      `json:"forked_from" db:"forked_from"` // the recipe this one was forked from
+	// Origin and Variations are only filled in when fetching a single recipe,
+	// and only list recipes the caller may see.
+	Origin     *RecipeSummary  `json:"origin,omitempty"`
+	Variations []RecipeSummary `json:"variations,omitempty"`
+}
+
+// RecipeSummary identifies a related recipe.
+type RecipeSummary struct {
+	RecipeID   int    `json:"recipe_id" db:"recipe_id"`
+	RecipeName string `json:"recipe_name" db:"recipe_name"`
+	OwnerID    *int   `json:"owner_id" db:"owner_id"`
+}
+
+// ValidVisibility reports whether v is a known visibility level.
+func ValidVisibility(v string) bool {
+	return v == VisibilityPrivate || v == VisibilityShared || v == VisibilityPublic
 }
@@ -0,0 +1,11 @@
+package models
+
+type RecipeCollaboratorRequest struct {
+	UserID int `json:"user_id" binding:"required,gt=0"`
+}
+
+// RecipeCollaborator grants a user access to another user's shared recipe.
+type RecipeCollaborator struct {
+	RecipeID int `json:"recipe_id" db:"recipe_id"`
+	UserID   int `json:"user_id" db:"user_id"`
+}
@@ -1,16 +1,34 @@
 package models
 
+import "time"
+
+// Measurement units a recipe ingredient can be measured in.
+var MeasurementUnits = []string{"g", "kg", "ml", "l", "tsp", "tbsp", "cup", "oz", "lb", "pinch", "piece"}
+
 type RecipeIngredientRequest struct {
 	RecipeID     int     `json:"recipe_id" binding:"required"`
 	IngredientID int     `json:"ingredient_id" binding:"required"`
-	Quantity     float64 `json:"quantity" binding:"required"`
-	Measurement  string  `json:"measurement" binding:"required"`
+	Quantity     float64 `json:"quantity" binding:"required,gt=0,lte=100000"`
+	Measurement  string  `json:"measurement" binding:"required,unit"`
 }
 
 type RecipeIngredient struct {
-	RecipeID           int     `json:"recipe_id" db:"recipe_id"`
-	IngredientID       int     `json:"ingredient_id" db:"ingredient_id"`
-	RecipeIngredientID int     `json:"recipe_ingredient_id" db:"recipe_ingredient_id"`
-	Quantity           float64 `json:"quantity" db:"quantity"`
-	Measurement        string  `json:"measurement" db:"measurement"`
+	RecipeID           int       `json:"recipe_id" db:"recipe_id"`
+	IngredientID       int       `json:"ingredient_id" db:"ingredient_id"`
+	RecipeIngredientID int       `json:"recipe_ingredient_id" db:"recipe_ingredient_id"`
+	Quantity           float64   `json:"quantity" db:"quantity"`
+	Measurement        string    `json:"measurement" db:"measurement"`
+	Version            int       `json:"version" db:"version"`
+	CreatedAt          time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt          time.Time `json:"updated_at" db:"updated_at"`
+}
+
+// ValidUnit reports whether u is a known measurement unit.
+func ValidUnit(u string) bool {
+	for _, unit := range MeasurementUnits {
+		if u == unit {
+			return true
+		}
+	}
+	return false
 }
@@ -0,0 +1,74 @@
+package models
+
+import "time"
+
+// RecipeDocument is the full content of a recipe at one point in time: its
+// fields, ingredients and steps. Revisions store and compare documents.
+type RecipeDocument struct {
+	RecipeName        string               `json:"recipe_name"`
+	RecipeDescription string               `json:"recipe_description"`
+	CookTime          int                  `json:"cook_time"`
+	Visibility        string               `json:"visibility"`
+	Ingredients       []RevisionIngredient `json:"ingredients"`
+	Steps             []RevisionStep       `json:"steps"`
+}
+
+// RevisionIngredient is an ingredient of a recipe document.
+type RevisionIngredient struct {
+	IngredientID int     `json:"ingredient_id"`
+	Quantity     float64 `json:"quantity"`
+	Measurement  string  `json:"measurement"`
+}
+
+// RevisionStep is a step of a recipe document.
+type RevisionStep struct {
+	StepNumber      int    `json:"step_number"`
+	StepDescription string `json:"step_description"`
+}
+
+// RecipeRevision is a numbered snapshot of a recipe taken when it changed.
+type RecipeRevision struct {
+	RecipeID  int       `json:"recipe_id" db:"recipe_id"`
+	Revision  int       `json:"revision" db:"revision"`
+	ActorID   *int      `json:"actor_id" db:"actor_id"`
+	RequestID string    `json:"request_id" db:"request_id"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+	// Document is omitted from revision lists.
+	Document *RecipeDocument `json:"document,omitempty" db:"document"`
+}
+
+// FieldChange is a recipe field whose value differs between two revisions.
+type FieldChange struct {
+	Field string      `json:"field"`
+	From  interface{} `json:"from"`
+	To    interface{} `json:"to"`
+}
+
+// IngredientChange is an ingredient whose quantity or measurement differs
+// between two revisions.
+type IngredientChange struct {
+	IngredientID int                `json:"ingredient_id"`
+	From         RevisionIngredient `json:"from"`
+	To           RevisionIngredient `json:"to"`
+}
+
+// StepChange is a step whose description differs between two revisions.
+type StepChange struct {
+	StepNumber int    `json:"step_number"`
+	From       string `json:"from"`
+	To         string `json:"to"`
+}
+
+// RevisionDiff lists what changed from one revision of a recipe to another.
+type RevisionDiff struct {
+	RecipeID           int                  `json:"recipe_id"`
+	From               int                  `json:"from"`
+	To                 int                  `json:"to"`
+	Fields             []FieldChange        `json:"fields"`
+	AddedIngredients   []RevisionIngredient `json:"added_ingredients"`
+	RemovedIngredients []RevisionIngredient `json:"removed_ingredients"`
+	ChangedIngredients []IngredientChange   `json:"changed_ingredients"`
+	AddedSteps         []RevisionStep       `json:"added_steps"`
+	RemovedSteps       []RevisionStep       `json:"removed_steps"`
+	ChangedSteps       []StepChange         `json:"changed_steps"`
+}
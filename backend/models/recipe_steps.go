@@ -1,17 +1,22 @@
 package models
 
+import "time"
+
 type RecipeStepRequest struct {
 	RecipeStepID    int    `json:"recipe_step_id" db:"recipe_step_id"`
-	RecipeID        int    `json:"recipe_id" db:"recipe_id"`
-	StepNumber      int    `json:"step_number" db:"step_number"`
-	StepDescription string `json:"step_description" db:"step_description"`
+	RecipeID        int    `json:"recipe_id" db:"recipe_id" binding:"required"`
+	StepNumber      int    `json:"step_number" db:"step_number" binding:"required,gte=1,lte=1000"`
+	StepDescription string `json:"step_description" db:"step_description" binding:"required,notblank,max=10000"`
 }
 
 // RecipeStepRequest defines the structure for a recipe step request.
 type RecipeStep struct {
-	RecipeStepID    int    `json:"recipe_step_id" db:"recipe_step_id"`
-	RecipeID        int    `json:"recipe_id" db:"recipe_id"`
-	StepID          int    `json:"step_id" db:"step_id"`
-	StepNumber      int    `json:"step_number" db:"step_number"`
-	StepDescription string `json:"step_description" db:"step_description"`
+	RecipeStepID    int       `json:"recipe_step_id" db:"recipe_step_id"`
+	RecipeID        int       `json:"recipe_id" db:"recipe_id"`
+	StepID          int       `json:"step_id" db:"step_id"`
+	StepNumber      int       `json:"step_number" db:"step_number"`
+	StepDescription string    `json:"step_description" db:"step_description"`
+	Version         int       `json:"version" db:"version"`
+	CreatedAt       time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt       time.Time `json:"updated_at" db:"updated_at"`
 }
@@ -0,0 +1,13 @@
+package models
+
+import "time"
+
+// TrashItem is a deleted recipe or ingredient that can still be restored.
+type TrashItem struct {
+	EntityType string    `json:"entity_type" db:"entity_type"`
+	EntityID   int       `json:"entity_id" db:"entity_id"`
+	Name       string    `json:"name" db:"name"`
+	DeletedAt  time.Time `json:"deleted_at" db:"deleted_at"`
+	// PurgeAt is when the item is removed for good.
+	PurgeAt time.Time `json:"purge_at"`
+}
@@ -0,0 +1,11 @@
+package models
+
+type UserRoleRequest struct {
+	Role string `json:"role" binding:"required,notblank,max=32,ne=anonymous"`
+}
+
+// UserRole assigns a global policy role to a user.
+type UserRole struct {
+	UserID int    `json:"user_id" db:"user_id"`
+	Role   string `json:"role" db:"role"`
+}
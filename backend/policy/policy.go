@@ -0,0 +1,154 @@
+// Package policy decides which roles may perform which actions on which
+// resources. Policies are plain data so they can be loaded from a file and
+// evaluated without a database or HTTP server.
+package policy
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+// Global roles. Every caller has exactly one of these.
+const (
+	RoleAnonymous = "anonymous"
+	RoleUser      = "user"
+	RoleAdmin     = "admin"
+)
+
+// HouseholdRolePrefix prefixes the caller's household role, e.g. "household:editor",
+// for requests acting in a household.
+const HouseholdRolePrefix = "household:"
+
+// Actions on resources.
+const (
+	ActionRead   = "read"
+	ActionCreate = "create"
+	ActionUpdate = "update"
+	ActionDelete = "delete"
+	ActionReview = "review"
+)
+
+// Resources guarded by the policy.
+const (
+	ResourceRecipes             = "recipes"
+	ResourceRecipeIngredients   = "recipe_ingredients"
+	ResourceRecipeSteps         = "recipe_steps"
+	ResourceIngredients         = "ingredients"
+	ResourceIngredientProposals = "ingredient_proposals"
+	ResourceHouseholds          = "households"
+	ResourceAPIKeys             = "api_keys"
+	ResourceUserRoles           = "user_roles"
+	ResourceAudit               = "audit"
+	ResourceTrash               = "trash"
+	ResourceLogLevel            = "log_level"
+)
+
+// Wildcard matches any resource or action.
+const Wildcard = "*"
+
+// Reasons reported when a request is denied.
+const (
+	ReasonNotPermitted   = "role_not_permitted"
+	ReasonNoMatchingRole = "no_matching_role"
+)
+
+// Policy grants actions on resources to roles. Anything not granted is denied.
+type Policy struct {
+	// Roles maps a role to the actions it may perform on each resource.
+	Roles map[string]map[string][]string `json:"roles"`
+}
+
+// Decision is the outcome of evaluating a policy.
+type Decision struct {
+	Allowed  bool   `json:"allowed"`
+	Reason   string `json:"reason,omitempty"`
+	Resource string `json:"resource"`
+	Action   string `json:"action"`
+}
+
+// Evaluate decides whether a caller holding roles may perform action on resource.
+// The request is allowed if any of the roles allows it.
+func (p *Policy) Evaluate(roles []string, resource, action string) Decision {
+	decision := Decision{Resource: resource, Action: action, Reason: ReasonNoMatchingRole}
+
+	for _, role := range roles {
+		grants, ok := p.Roles[role]
+		if !ok {
+			continue
+		}
+		decision.Reason = ReasonNotPermitted
+
+		if allows(grants[resource], action) || allows(grants[Wildcard], action) {
+			return Decision{Allowed: true, Resource: resource, Action: action}
+		}
+	}
+
+	return decision
+}
+
+// allows reports whether actions contains action or the wildcard.
+func allows(actions []string, action string) bool {
+	for _, granted := range actions {
+		if granted == action || granted == Wildcard {
+			return true
+		}
+	}
+	return false
+}
+
+// Load reads a policy from a JSON file.
+func Load(path string) (*Policy, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading policy file: %v", err)
+	}
+	return Parse(data)
+}
+
+// Parse decodes a policy from JSON.
+func Parse(data []byte) (*Policy, error) {
+	var p Policy
+	if err := json.Unmarshal(data, &p); err != nil {
+		return nil, fmt.Errorf("error parsing policy: %v", err)
+	}
+	if len(p.Roles) == 0 {
+		return nil, fmt.Errorf("error parsing policy: no roles defined")
+	}
+	return &p, nil
+}
+
+// Default returns the built-in policy used when no policy file is configured.
+// Admins curate the shared ingredient catalog; users propose additions to it
+// and manage ingredients of their households.
+func Default() *Policy {
+	owned := []string{ActionRead, ActionCreate, ActionUpdate, ActionDelete}
+
+	return &Policy{Roles: map[string]map[string][]string{
+		RoleAnonymous: {
+			ResourceRecipes:           {ActionRead},
+			ResourceRecipeIngredients: {ActionRead},
+			ResourceRecipeSteps:       {ActionRead},
+			ResourceIngredients:       {ActionRead},
+		},
+		RoleUser: {
+			ResourceRecipes:             owned,
+			ResourceRecipeIngredients:   owned,
+			ResourceRecipeSteps:         owned,
+			ResourceIngredients:         {ActionRead},
+			ResourceIngredientProposals: {ActionRead, ActionCreate},
+			ResourceHouseholds:          owned,
+			ResourceAPIKeys:             owned,
+			ResourceTrash:               {ActionRead},
+		},
+		RoleAdmin: {
+			Wildcard: {Wildcard},
+		},
+		HouseholdRolePrefix + "owner": {
+			ResourceIngredients: owned,
+		},
+		HouseholdRolePrefix + "editor": {
+			ResourceIngredients: owned,
+		},
+	}}
+}
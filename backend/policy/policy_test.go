@@ -0,0 +1,159 @@
+package policy
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		wantErr bool
+	}{
+		{name: "valid", data: `{"roles": {"user": {"recipes": ["read"]}}}`},
+		{name: "invalid JSON", data: `{"roles": `, wantErr: true},
+		{name: "no roles", data: `{"roles": {}}`, wantErr: true},
+		{name: "missing roles", data: `{}`, wantErr: true},
+		{name: "wrong shape", data: `{"roles": {"user": ["read"]}}`, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := Parse([]byte(tt.data))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Parse() error = %v, want error %v", err, tt.wantErr)
+			}
+			if err == nil && p == nil {
+				t.Fatal("Parse() returned neither a policy nor an error")
+			}
+		})
+	}
+}
+
+func TestLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "policy.json")
+	if err := os.WriteFile(path, []byte(`{"roles": {"user": {"recipes": ["read"]}}}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	p, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if d := p.Evaluate([]string{RoleUser}, ResourceRecipes, ActionRead); !d.Allowed {
+		t.Errorf("loaded policy denies reading recipes: %+v", d)
+	}
+
+	if _, err := Load(filepath.Join(t.TempDir(), "missing.json")); err == nil {
+		t.Error("Load() of a missing file succeeded")
+	}
+}
+
+func TestEvaluate(t *testing.T) {
+	p := &Policy{Roles: map[string]map[string][]string{
+		RoleAnonymous:                 {ResourceRecipes: {ActionRead}},
+		RoleUser:                      {ResourceRecipes: {ActionRead, ActionCreate}, ResourceHouseholds: {Wildcard}},
+		RoleAdmin:                     {Wildcard: {Wildcard}},
+		HouseholdRolePrefix + "owner": {ResourceIngredients: {ActionUpdate}},
+	}}
+
+	tests := []struct {
+		name       string
+		roles      []string
+		resource   string
+		action     string
+		wantAllow  bool
+		wantReason string
+	}{
+		{name: "granted action", roles: []string{RoleUser}, resource: ResourceRecipes, action: ActionCreate, wantAllow: true},
+		{name: "action not granted", roles: []string{RoleUser}, resource: ResourceRecipes, action: ActionDelete, wantReason: ReasonNotPermitted},
+		{name: "resource not granted", roles: []string{RoleUser}, resource: ResourceUserRoles, action: ActionRead, wantReason: ReasonNotPermitted},
+		{name: "wildcard action", roles: []string{RoleUser}, resource: ResourceHouseholds, action: ActionDelete, wantAllow: true},
+		{name: "wildcard resource and action", roles: []string{RoleAdmin}, resource: ResourceUserRoles, action: ActionUpdate, wantAllow: true},
+		{name: "unknown role", roles: []string{"guest"}, resource: ResourceRecipes, action: ActionRead, wantReason: ReasonNoMatchingRole},
+		{name: "no roles", roles: nil, resource: ResourceRecipes, action: ActionRead, wantReason: ReasonNoMatchingRole},
+		{name: "anonymous read", roles: []string{RoleAnonymous}, resource: ResourceRecipes, action: ActionRead, wantAllow: true},
+		{name: "anonymous write", roles: []string{RoleAnonymous}, resource: ResourceRecipes, action: ActionCreate, wantReason: ReasonNotPermitted},
+		{name: "household role adds grants", roles: []string{RoleUser, HouseholdRolePrefix + "owner"}, resource: ResourceIngredients, action: ActionUpdate, wantAllow: true},
+		{name: "household role without grant", roles: []string{RoleUser, HouseholdRolePrefix + "owner"}, resource: ResourceIngredients, action: ActionDelete, wantReason: ReasonNotPermitted},
+		{name: "unknown household role", roles: []string{RoleUser, HouseholdRolePrefix + "viewer"}, resource: ResourceIngredients, action: ActionUpdate, wantReason: ReasonNotPermitted},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := p.Evaluate(tt.roles, tt.resource, tt.action)
+			if d.Allowed != tt.wantAllow {
+				t.Errorf("Evaluate(%v, %s, %s).Allowed = %v, want %v", tt.roles, tt.resource, tt.action, d.Allowed, tt.wantAllow)
+			}
+			if d.Reason != tt.wantReason {
+				t.Errorf("Evaluate(%v, %s, %s).Reason = %q, want %q", tt.roles, tt.resource, tt.action, d.Reason, tt.wantReason)
+			}
+			if d.Resource != tt.resource || d.Action != tt.action {
+				t.Errorf("Evaluate() reported %s on %s, want %s on %s", d.Action, d.Resource, tt.action, tt.resource)
+			}
+		})
+	}
+}
+
+func TestDefault(t *testing.T) {
+	p := Default()
+	owner, editor, viewer := HouseholdRolePrefix+"owner", HouseholdRolePrefix+"editor", HouseholdRolePrefix+"viewer"
+
+	tests := []struct {
+		roles     []string
+		resource  string
+		action    string
+		wantAllow bool
+	}{
+		// Anonymous callers only read public content.
+		{[]string{RoleAnonymous}, ResourceRecipes, ActionRead, true},
+		{[]string{RoleAnonymous}, ResourceIngredients, ActionRead, true},
+		{[]string{RoleAnonymous}, ResourceRecipes, ActionCreate, false},
+		{[]string{RoleAnonymous}, ResourceIngredientProposals, ActionCreate, false},
+		{[]string{RoleAnonymous}, ResourceTrash, ActionRead, false},
+
+		// Users manage their own content and propose catalog ingredients.
+		{[]string{RoleUser}, ResourceRecipes, ActionDelete, true},
+		{[]string{RoleUser}, ResourceHouseholds, ActionCreate, true},
+		{[]string{RoleUser}, ResourceAPIKeys, ActionDelete, true},
+		{[]string{RoleUser}, ResourceIngredientProposals, ActionCreate, true},
+		{[]string{RoleUser}, ResourceIngredientProposals, ActionReview, false},
+		{[]string{RoleUser}, ResourceIngredients, ActionCreate, false},
+		{[]string{RoleUser}, ResourceTrash, ActionRead, true},
+
+		// Admin-only resources.
+		{[]string{RoleUser}, ResourceAudit, ActionRead, false},
+		{[]string{RoleUser}, ResourceUserRoles, ActionUpdate, false},
+		{[]string{RoleUser}, ResourceLogLevel, ActionUpdate, false},
+		{[]string{RoleAdmin}, ResourceAudit, ActionRead, true},
+		{[]string{RoleAdmin}, ResourceUserRoles, ActionUpdate, true},
+		{[]string{RoleAdmin}, ResourceLogLevel, ActionUpdate, true},
+		{[]string{RoleAdmin}, ResourceIngredients, ActionDelete, true},
+		{[]string{RoleAdmin}, ResourceIngredientProposals, ActionReview, true},
+
+		// Household owners and editors manage the household's ingredients; viewers do not.
+		{[]string{RoleUser, owner}, ResourceIngredients, ActionCreate, true},
+		{[]string{RoleUser, editor}, ResourceIngredients, ActionDelete, true},
+		{[]string{RoleUser, viewer}, ResourceIngredients, ActionCreate, false},
+		{[]string{RoleUser, viewer}, ResourceIngredients, ActionRead, true},
+		{[]string{RoleUser, owner}, ResourceUserRoles, ActionRead, false},
+	}
+	for _, tt := range tests {
+		d := p.Evaluate(tt.roles, tt.resource, tt.action)
+		if d.Allowed != tt.wantAllow {
+			t.Errorf("Default().Evaluate(%v, %s, %s).Allowed = %v, want %v (reason %q)", tt.roles, tt.resource, tt.action, d.Allowed, tt.wantAllow, d.Reason)
+		}
+	}
+}
+
+// TestExampleMatchesDefault keeps policy.example.json a copy of Default, so
+// that deployments starting from the example lose no access.
+func TestExampleMatchesDefault(t *testing.T) {
+	example, err := Load(filepath.Join("..", "policy.example.json"))
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if want := Default(); !reflect.DeepEqual(example.Roles, want.Roles) {
+		t.Errorf("policy.example.json grants %v, Default() grants %v", example.Roles, want.Roles)
+	}
+}
@@ -0,0 +1,189 @@
+// Package revisions keeps the history of recipes. Whenever a recipe, its
+// ingredients or its steps change, the full recipe document is stored as a new
+// numbered revision in the transaction making the change.
+package revisions
+
+import (
+	"backend/models"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"reflect"
+)
+
+// Document reads the current document of a live recipe in tx, locking the
+// recipe for the rest of the transaction. It returns sql.ErrNoRows if the
+// recipe does not exist or is in the trash.
+func Document(ctx context.Context, tx *sql.Tx, recipeID int) (models.RecipeDocument, error) {
+	doc := models.RecipeDocument{Ingredients: []models.RevisionIngredient{}, Steps: []models.RevisionStep{}}
+
+	sqlQuery := `
+		SELECT recipe_name, COALESCE(recipe_description, ''), COALESCE(cook_time, 0), visibility
+		FROM recipes
+		WHERE recipe_id = $1 AND deleted_at IS NULL
+		FOR UPDATE`
+	err := tx.QueryRowContext(ctx, sqlQuery, recipeID).Scan(&doc.RecipeName, &doc.RecipeDescription, &doc.CookTime, &doc.Visibility)
+	if err != nil {
+		return doc, err
+	}
+
+	sqlQuery = `
+		SELECT ingredient_id, quantity, COALESCE(measurement, '')
+		FROM recipe_ingredients
+		WHERE recipe_id = $1 AND deleted_at IS NULL
+		ORDER BY recipe_ingredient_id`
+	rows, err := tx.QueryContext(ctx, sqlQuery, recipeID)
+	if err != nil {
+		return doc, err
+	}
+	for rows.Next() {
+		var ingredient models.RevisionIngredient
+		if err := rows.Scan(&ingredient.IngredientID, &ingredient.Quantity, &ingredient.Measurement); err != nil {
+			rows.Close()
+			return doc, err
+		}
+		doc.Ingredients = append(doc.Ingredients, ingredient)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return doc, err
+	}
+
+	sqlQuery = `
+		SELECT step_number, step_description
+		FROM recipe_steps
+		WHERE recipe_id = $1 AND deleted_at IS NULL
+		ORDER BY step_number, recipe_step_id`
+	rows, err = tx.QueryContext(ctx, sqlQuery, recipeID)
+	if err != nil {
+		return doc, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var step models.RevisionStep
+		if err := rows.Scan(&step.StepNumber, &step.StepDescription); err != nil {
+			return doc, err
+		}
+		doc.Steps = append(doc.Steps, step)
+	}
+	return doc, rows.Err()
+}
+
+// Record stores the current document of a recipe as its next revision, unless
+// it matches the latest revision. Recipes that no longer exist or are in the
+// trash are skipped.
+func Record(ctx context.Context, tx *sql.Tx, recipeID int, actorID *int, requestID string) error {
+	doc, err := Document(ctx, tx, recipeID)
+	if err == sql.ErrNoRows {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("error reading recipe %d for revision: %v", recipeID, err)
+	}
+
+	// Unchanged documents are skipped, so that several changes made in one
+	// transaction produce a single revision.
+	sqlQuery := `SELECT revision, document FROM recipe_revisions WHERE recipe_id = $1 ORDER BY revision DESC LIMIT 1`
+	var latest int
+	var latestDoc []byte
+	err = tx.QueryRowContext(ctx, sqlQuery, recipeID).Scan(&latest, &latestDoc)
+	if err != nil && err != sql.ErrNoRows {
+		return fmt.Errorf("error reading latest revision of recipe %d: %v", recipeID, err)
+	}
+	if err == nil {
+		var previous models.RecipeDocument
+		if err := json.Unmarshal(latestDoc, &previous); err == nil && reflect.DeepEqual(previous, doc) {
+			return nil
+		}
+	}
+
+	data, err := json.Marshal(doc)
+	if err != nil {
+		return fmt.Errorf("error encoding revision of recipe %d: %v", recipeID, err)
+	}
+	sqlQuery = `
+		INSERT INTO recipe_revisions (recipe_id, revision, document, actor_id, request_id)
+		VALUES ($1, $2, $3, $4, $5)`
+	if _, err := tx.ExecContext(ctx, sqlQuery, recipeID, latest+1, string(data), actorID, requestID); err != nil {
+		return fmt.Errorf("error writing revision of recipe %d: %v", recipeID, err)
+	}
+	return nil
+}
+
+// Diff lists the changes from one recipe document to another. Ingredients are
+// matched by ingredient and steps by step number.
+func Diff(from, to models.RecipeDocument) models.RevisionDiff {
+	diff := models.RevisionDiff{
+		Fields:             []models.FieldChange{},
+		AddedIngredients:   []models.RevisionIngredient{},
+		RemovedIngredients: []models.RevisionIngredient{},
+		ChangedIngredients: []models.IngredientChange{},
+		AddedSteps:         []models.RevisionStep{},
+		RemovedSteps:       []models.RevisionStep{},
+		ChangedSteps:       []models.StepChange{},
+	}
+
+	// 1. Compare the recipe fields.
+	fields := []struct {
+		name     string
+		from, to interface{}
+	}{
+		{"recipe_name", from.RecipeName, to.RecipeName},
+		{"recipe_description", from.RecipeDescription, to.RecipeDescription},
+		{"cook_time", from.CookTime, to.CookTime},
+		{"visibility", from.Visibility, to.Visibility},
+	}
+	for _, f := range fields {
+		if f.from != f.to {
+			diff.Fields = append(diff.Fields, models.FieldChange{Field: f.name, From: f.from, To: f.to})
+		}
+	}
+
+	// 2. Compare ingredients, pairing repeated ingredients in order.
+	remaining := map[int][]models.RevisionIngredient{}
+	for _, ingredient := range from.Ingredients {
+		remaining[ingredient.IngredientID] = append(remaining[ingredient.IngredientID], ingredient)
+	}
+	for _, ingredient := range to.Ingredients {
+		old := remaining[ingredient.IngredientID]
+		if len(old) == 0 {
+			diff.AddedIngredients = append(diff.AddedIngredients, ingredient)
+			continue
+		}
+		remaining[ingredient.IngredientID] = old[1:]
+		if old[0] != ingredient {
+			diff.ChangedIngredients = append(diff.ChangedIngredients, models.IngredientChange{IngredientID: ingredient.IngredientID, From: old[0], To: ingredient})
+		}
+	}
+	for _, ingredient := range from.Ingredients {
+		if old := remaining[ingredient.IngredientID]; len(old) > 0 {
+			diff.RemovedIngredients = append(diff.RemovedIngredients, old[0])
+			remaining[ingredient.IngredientID] = old[1:]
+		}
+	}
+
+	// 3. Compare steps by their number.
+	steps := map[int]string{}
+	for _, step := range from.Steps {
+		steps[step.StepNumber] = step.StepDescription
+	}
+	for _, step := range to.Steps {
+		old, ok := steps[step.StepNumber]
+		if !ok {
+			diff.AddedSteps = append(diff.AddedSteps, step)
+			continue
+		}
+		delete(steps, step.StepNumber)
+		if old != step.StepDescription {
+			diff.ChangedSteps = append(diff.ChangedSteps, models.StepChange{StepNumber: step.StepNumber, From: old, To: step.StepDescription})
+		}
+	}
+	for _, step := range from.Steps {
+		if _, ok := steps[step.StepNumber]; ok {
+			diff.RemovedSteps = append(diff.RemovedSteps, step)
+			delete(steps, step.StepNumber)
+		}
+	}
+	return diff
+}
@@ -0,0 +1,17 @@
+package routes
+
+import (
+	"backend/controllers"
+	"backend/middleware"
+	"backend/policy"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Define routes:
+func SetupAdminRoutes(router *gin.Engine) {
+	interactive := middleware.RejectAPIKeys()
+
+	router.GET("/admin/log-level", interactive, middleware.Authorize(policy.ResourceLogLevel, policy.ActionRead), controllers.GetLogLevel)
+	router.PUT("/admin/log-level", interactive, middleware.Authorize(policy.ResourceLogLevel, policy.ActionUpdate), controllers.UpdateLogLevel)
+}
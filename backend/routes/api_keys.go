@@ -0,0 +1,21 @@
+package routes
+
+import (
+	"backend/controllers"
+	"backend/middleware"
+	"backend/policy"
+	"database/sql"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Define routes:
+func SetupAPIKeyRoutes(router *gin.Engine, db *sql.DB) {
+	// API keys cannot be used to mint or revoke other keys
+	interactive := middleware.RejectAPIKeys()
+	can := func(action string) gin.HandlerFunc { return middleware.Authorize(policy.ResourceAPIKeys, action) }
+
+	router.GET("/api-keys", interactive, can(policy.ActionRead), func(c *gin.Context) { controllers.GetAPIKeys(c, db) })
+	router.POST("/api-keys", interactive, can(policy.ActionCreate), func(c *gin.Context) { controllers.CreateAPIKey(c, db) })
+	router.DELETE("/api-keys/:id", interactive, can(policy.ActionDelete), func(c *gin.Context) { controllers.RevokeAPIKey(c, db) })
+}
@@ -0,0 +1,17 @@
+package routes
+
+import (
+	"backend/controllers"
+	"backend/middleware"
+	"backend/policy"
+	"database/sql"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Define routes:
+func SetupAuditRoutes(router *gin.Engine, db *sql.DB) {
+	interactive := middleware.RejectAPIKeys()
+
+	router.GET("/audit", interactive, middleware.Authorize(policy.ResourceAudit, policy.ActionRead), func(c *gin.Context) { controllers.GetAuditLog(c, db) })
+}
@@ -0,0 +1,16 @@
+package routes
+
+import (
+	"backend/controllers"
+	"database/sql"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Define routes:
+// Health checks are open to anyone and must be set up before the middleware
+// resolving callers, so that probes never depend on it.
+func SetupHealthRoutes(router *gin.Engine, db *sql.DB) {
+	router.GET("/healthz", controllers.GetLiveness)
+	router.GET("/readyz", func(c *gin.Context) { controllers.GetReadiness(c, db) })
+}
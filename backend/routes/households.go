@@ -0,0 +1,25 @@
+package routes
+
+import (
+	"backend/controllers"
+	"backend/middleware"
+	"backend/policy"
+	"database/sql"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Define routes:
+func SetupHouseholdRoutes(router *gin.Engine, db *sql.DB) {
+	// Household membership is managed by people, not machine clients
+	interactive := middleware.RejectAPIKeys()
+	can := func(action string) gin.HandlerFunc { return middleware.Authorize(policy.ResourceHouseholds, action) }
+
+	router.GET("/households", interactive, can(policy.ActionRead), func(c *gin.Context) { controllers.GetHouseholds(c, db) })
+	router.POST("/households", interactive, can(policy.ActionCreate), func(c *gin.Context) { controllers.CreateHousehold(c, db) })
+	router.GET("/households/:id/members", interactive, can(policy.ActionRead), func(c *gin.Context) { controllers.GetHouseholdMembers(c, db) })
+	router.PUT("/households/:id/members/:user_id", interactive, can(policy.ActionUpdate), func(c *gin.Context) { controllers.UpdateHouseholdMember(c, db) })
+	router.DELETE("/households/:id/members/:user_id", interactive, can(policy.ActionDelete), func(c *gin.Context) { controllers.RemoveHouseholdMember(c, db) })
+	router.POST("/households/:id/invitations", interactive, can(policy.ActionCreate), func(c *gin.Context) { controllers.CreateHouseholdInvitation(c, db) })
+	router.POST("/household-invitations/accept", interactive, can(policy.ActionCreate), func(c *gin.Context) { controllers.AcceptHouseholdInvitation(c, db) })
+}
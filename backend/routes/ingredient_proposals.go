@@ -0,0 +1,23 @@
+package routes
+
+import (
+	"backend/controllers"
+	"backend/middleware"
+	"backend/policy"
+	"database/sql"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Define routes:
+func SetupIngredientProposalRoutes(router *gin.Engine, db *sql.DB) {
+	interactive := middleware.RejectAPIKeys()
+	can := func(action string) gin.HandlerFunc {
+		return middleware.Authorize(policy.ResourceIngredientProposals, action)
+	}
+
+	router.GET("/ingredient-proposals", interactive, can(policy.ActionRead), func(c *gin.Context) { controllers.GetIngredientProposals(c, db) })
+	router.POST("/ingredient-proposals", interactive, can(policy.ActionCreate), func(c *gin.Context) { controllers.CreateIngredientProposal(c, db) })
+	router.POST("/ingredient-proposals/:id/approve", interactive, can(policy.ActionReview), func(c *gin.Context) { controllers.ApproveIngredientProposal(c, db) })
+	router.POST("/ingredient-proposals/:id/reject", interactive, can(policy.ActionReview), func(c *gin.Context) { controllers.RejectIngredientProposal(c, db) })
+}
@@ -1,6 +1,10 @@
 package routes
 
 import (
+	"backend/controllers"
+	"backend/middleware"
+	"backend/models"
+	"backend/policy"
 	"database/sql"
 
 	"github.com/gin-gonic/gin"
@@ -8,29 +12,15 @@ import (
 
 // Define routes:
 func SetupIngredientsRoutes(router *gin.Engine, db *sql.DB) {
-	router.GET("/ingredients", getIngredients)
-	router.GET("/ingredients/:id", getIngredient)
-	router.POST("/ingredients", createIngredient)
-	router.PUT("/ingredients/:id", updateIngredient)
-	router.DELETE("/ingredients/:id", deleteIngredient)
-}
-
-func getIngredients(c *gin.Context) {
-
-}
-
-func getIngredient(c *gin.Context) {
-
-}
-
-func createIngredient(c *gin.Context) {
-
-}
-
-func updateIngredient(c *gin.Context) {
-
-}
-
-func deleteIngredient(c *gin.Context) {
-
+	read := middleware.RequireScope(models.ScopeIngredientsRead)
+	write := middleware.RequireScope(models.ScopeIngredientsWrite)
+	can := func(action string) gin.HandlerFunc { return middleware.Authorize(policy.ResourceIngredients, action) }
+
+	router.GET("/ingredients", read, can(policy.ActionRead), func(c *gin.Context) { controllers.GetAllIngredients(c, db) })
+	router.GET("/ingredients/:id", read, can(policy.ActionRead), func(c *gin.Context) { controllers.GetIngredient(c, db) })
+	router.POST("/ingredients", write, can(policy.ActionCreate), func(c *gin.Context) { controllers.CreateIngredient(c, db) })
+	router.PUT("/ingredients/:id", write, can(policy.ActionUpdate), func(c *gin.Context) { controllers.UpdateIngredient(c, db) })
+	router.PATCH("/ingredients/:id", write, can(policy.ActionUpdate), func(c *gin.Context) { controllers.PatchIngredient(c, db) })
+	router.DELETE("/ingredients/:id", write, can(policy.ActionDelete), func(c *gin.Context) { controllers.DeleteIngredient(c, db) })
+	router.POST("/ingredients/:id/restore", write, can(policy.ActionDelete), func(c *gin.Context) { controllers.RestoreIngredient(c, db) })
 }
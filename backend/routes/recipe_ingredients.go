@@ -1,6 +1,10 @@
 package routes
 
 import (
+	"backend/controllers"
+	"backend/middleware"
+	"backend/models"
+	"backend/policy"
 	"database/sql"
 
 	"github.com/gin-gonic/gin"
@@ -8,29 +12,16 @@ import (
 
 // Define routes:
 func SetupRecipeIngredientsRoutes(router *gin.Engine, db *sql.DB) {
-	router.GET("/recipe-ingredients", getIngredients)
-	router.GET("/recipe-ingredients/:id", getIngredient)
-	router.POST("/recipe-ingredients", createIngredient)
-	router.PUT("/recipe-ingredients/:id", updateIngredient)
-	router.DELETE("/recipe-ingredients/:id", deleteIngredient)
-}
-
-func getRecipeIngredients(c *gin.Context) {
-
-}
-
-func getRecipeIngredient(c *gin.Context) {
-
-}
-
-func createRecipeIngredient(c *gin.Context) {
-
-}
-
-func updateRecipeIngredient(c *gin.Context) {
-
-}
-
-func deleteRecipeIngredient(c *gin.Context) {
-
+	read := middleware.RequireScope(models.ScopeRecipesRead)
+	write := middleware.RequireScope(models.ScopeRecipesWrite)
+	can := func(action string) gin.HandlerFunc {
+		return middleware.Authorize(policy.ResourceRecipeIngredients, action)
+	}
+
+	router.GET("/recipe-ingredients", read, can(policy.ActionRead), func(c *gin.Context) { controllers.GetRecipeIngredients(c, db) })
+	router.GET("/recipe-ingredients/:id", read, can(policy.ActionRead), func(c *gin.Context) { controllers.GetRecipeIngredient(c, db) })
+	router.POST("/recipe-ingredients", write, can(policy.ActionCreate), func(c *gin.Context) { controllers.CreateRecipeIngredient(c, db) })
+	router.PUT("/recipe-ingredients/:id", write, can(policy.ActionUpdate), func(c *gin.Context) { controllers.UpdateRecipeIngredient(c, db) })
+	router.PATCH("/recipe-ingredients/:id", write, can(policy.ActionUpdate), func(c *gin.Context) { controllers.PatchRecipeIngredient(c, db) })
+	router.DELETE("/recipe-ingredients/:id", write, can(policy.ActionDelete), func(c *gin.Context) { controllers.DeleteRecipeIngredient(c, db) })
 }
@@ -1,6 +1,10 @@
 package routes
 
 import (
+	"backend/controllers"
+	"backend/middleware"
+	"backend/models"
+	"backend/policy"
 	"database/sql"
 
 	"github.com/gin-gonic/gin"
@@ -8,29 +12,14 @@ import (
 
 // Define routes:
 func SetupRecipeStepsRoutes(router *gin.Engine, db *sql.DB) {
-	router.GET("/steps", getSteps)
-	router.GET("/steps/:id", getStep)
-	router.POST("/steps", createStep)
-	router.PUT("/steps/:id", updateStep)
-	router.DELETE("/steps/:id", deleteStep)
-}
-
-func getSteps(c *gin.Context) {
-
-}
-
-func getStep(c *gin.Context) {
-
-}
-
-func createStep(c *gin.Context) {
-
-}
-
-func updateStep(c *gin.Context) {
-
-}
-
-func deleteStep(c *gin.Context) {
-
+	read := middleware.RequireScope(models.ScopeRecipesRead)
+	write := middleware.RequireScope(models.ScopeRecipesWrite)
+	can := func(action string) gin.HandlerFunc { return middleware.Authorize(policy.ResourceRecipeSteps, action) }
+
+	router.GET("/recipe-steps", read, can(policy.ActionRead), func(c *gin.Context) { controllers.GetRecipeSteps(c, db) })
+	router.GET("/recipe-steps/:id", read, can(policy.ActionRead), func(c *gin.Context) { controllers.GetRecipeStep(c, db) })
+	router.POST("/recipe-steps", write, can(policy.ActionCreate), func(c *gin.Context) { controllers.CreateRecipeStep(c, db) })
+	router.PUT("/recipe-steps/:id", write, can(policy.ActionUpdate), func(c *gin.Context) { controllers.UpdateRecipeStep(c, db) })
+	router.PATCH("/recipe-steps/:id", write, can(policy.ActionUpdate), func(c *gin.Context) { controllers.PatchRecipeStep(c, db) })
+	router.DELETE("/recipe-steps/:id", write, can(policy.ActionDelete), func(c *gin.Context) { controllers.DeleteRecipeStep(c, db) })
 }
@@ -1,35 +1,37 @@
 package routes
 
 import (
+	"backend/controllers"
+	"backend/middleware"
+	"backend/models"
+	"backend/policy"
 	"database/sql"
 
 	"github.com/gin-gonic/gin"
 )
 
 func SetupRecipeRoutes(router *gin.Engine, db *sql.DB) {
-	router.GET("/recipes", getRecipes)
-	router.GET("/recipes/:id", getRecipe)
-	router.POST("/recipes", createRecipe)
-	router.PUT("/recipes/:id", updateRecipe)
-	router.DELETE("/recipes/:id", deleteRecipe)
-}
-
-func getRecipes(c *gin.Context) {
-
-}
-
-func getRecipe(c *gin.Context) {
-
-}
-
-func createRecipe(c *gin.Context) {
-
-}
-
-func updateRecipe(c *gin.Context) {
-
-}
-
-func deleteRecipe(c *gin.Context) {
-
+	read := middleware.RequireScope(models.ScopeRecipesRead)
+	write := middleware.RequireScope(models.ScopeRecipesWrite)
+	can := func(action string) gin.HandlerFunc { return middleware.Authorize(policy.ResourceRecipes, action) }
+
+	router.GET("/recipes", read, can(policy.ActionRead), func(c *gin.Context) { controllers.GetRecipes(c, db) })
+	router.GET("/recipes/:id", read, can(policy.ActionRead), func(c *gin.Context) { controllers.GetRecipe(c, db) })
+	router.POST("/recipes", write, can(policy.ActionCreate), func(c *gin.Context) { controllers.CreateRecipe(c, db) })
+	router.PUT("/recipes/:id", write, can(policy.ActionUpdate), func(c *gin.Context) { controllers.UpdateRecipe(c, db) })
+	router.PATCH("/recipes/:id", write, can(policy.ActionUpdate), func(c *gin.Context) { controllers.PatchRecipe(c, db) })
+	router.DELETE("/recipes/:id", write, can(policy.ActionDelete), func(c *gin.Context) { controllers.DeleteRecipe(c, db) })
+	router.POST("/recipes/:id/fork", write, can(policy.ActionCreate), func(c *gin.Context) { controllers.ForkRecipe(c, db) })
+	router.POST("/recipes/:id/restore", write, can(policy.ActionDelete), func(c *gin.Context) { controllers.RestoreRecipe(c, db) })
+
+	// Collaborators of shared recipes
+	router.GET("/recipes/:id/collaborators", read, can(policy.ActionRead), func(c *gin.Context) { controllers.GetRecipeCollaborators(c, db) })
+	router.POST("/recipes/:id/collaborators", write, can(policy.ActionUpdate), func(c *gin.Context) { controllers.AddRecipeCollaborator(c, db) })
+	router.DELETE("/recipes/:id/collaborators/:user_id", write, can(policy.ActionUpdate), func(c *gin.Context) { controllers.RemoveRecipeCollaborator(c, db) })
+
+	// Revision history
+	router.GET("/recipes/:id/revisions", read, can(policy.ActionRead), func(c *gin.Context) { controllers.GetRecipeRevisions(c, db) })
+	router.GET("/recipes/:id/revisions/:revision", read, can(policy.ActionRead), func(c *gin.Context) { controllers.GetRecipeRevision(c, db) })
+	router.GET("/recipes/:id/revisions/:revision/diff/:to", read, can(policy.ActionRead), func(c *gin.Context) { controllers.GetRecipeRevisionDiff(c, db) })
+	router.POST("/recipes/:id/revisions/:revision/revert", write, can(policy.ActionUpdate), func(c *gin.Context) { controllers.RevertRecipe(c, db) })
 }
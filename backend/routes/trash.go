@@ -0,0 +1,17 @@
+package routes
+
+import (
+	"backend/controllers"
+	"backend/middleware"
+	"backend/policy"
+	"database/sql"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Define routes:
+func SetupTrashRoutes(router *gin.Engine, db *sql.DB) {
+	interactive := middleware.RejectAPIKeys()
+
+	router.GET("/trash", interactive, middleware.Authorize(policy.ResourceTrash, policy.ActionRead), func(c *gin.Context) { controllers.GetTrash(c, db) })
+}
@@ -0,0 +1,17 @@
+package routes
+
+import (
+	"backend/controllers"
+	"backend/middleware"
+	"backend/policy"
+	"database/sql"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Define routes:
+func SetupUserRoleRoutes(router *gin.Engine, db *sql.DB) {
+	interactive := middleware.RejectAPIKeys()
+
+	router.PUT("/users/:id/role", interactive, middleware.Authorize(policy.ResourceUserRoles, policy.ActionUpdate), func(c *gin.Context) { controllers.UpdateUserRole(c, db) })
+}
@@ -0,0 +1,67 @@
+// Package validation registers the domain rules request models use in their
+// binding tags, such as "unit" or "visibility", on gin's validator. Whether
+// referenced recipes and ingredients exist depends on the caller's tenant, so
+// controllers check that rather than binding.
+package validation
+
+import (
+	"backend/apierror"
+	"backend/models"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+)
+
+// Register installs the custom rules on gin's validator and reports
+// validation failures by JSON field name.
+func Register() error {
+	v, ok := binding.Validator.Engine().(*validator.Validate)
+	if !ok {
+		return fmt.Errorf("unexpected validator engine %T", binding.Validator.Engine())
+	}
+
+	v.RegisterTagNameFunc(jsonName)
+
+	rules := []struct {
+		tag     string
+		fn      validator.Func
+		message string
+	}{
+		{"notblank", notBlank, "must not be blank"},
+		{"unit", oneOf(models.ValidUnit), "must be one of " + strings.Join(models.MeasurementUnits, ", ")},
+		{"visibility", oneOf(models.ValidVisibility), "must be one of private, shared or public"},
+		{"household_role", oneOf(models.ValidHouseholdRole), "must be one of owner, editor or viewer"},
+		{"scope", oneOf(models.ValidScope), "must be one of " + strings.Join(models.APIKeyScopes, ", ")},
+	}
+	for _, rule := range rules {
+		if err := v.RegisterValidation(rule.tag, rule.fn); err != nil {
+			return fmt.Errorf("error registering %s validation: %v", rule.tag, err)
+		}
+		apierror.RegisterMessage(rule.tag, rule.message)
+	}
+	return nil
+}
+
+// jsonName names struct fields by their JSON key in validation errors.
+func jsonName(field reflect.StructField) string {
+	name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+	if name == "" || name == "-" {
+		return field.Name
+	}
+	return name
+}
+
+// notBlank rejects strings consisting only of whitespace.
+func notBlank(fl validator.FieldLevel) bool {
+	return strings.TrimSpace(fl.Field().String()) != ""
+}
+
+// oneOf adapts a model's predicate for known string values into a rule.
+func oneOf(valid func(string) bool) validator.Func {
+	return func(fl validator.FieldLevel) bool {
+		return valid(fl.Field().String())
+	}
+}